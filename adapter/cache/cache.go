@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modular-project/address-service/metrics"
+	"github.com/modular-project/address-service/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const name = "geocode"
+
+var tracer = otel.Tracer("github.com/modular-project/address-service/adapter/cache")
+
+type geoCoder interface {
+	GeoCode(context.Context, string) (model.Location, error)
+}
+
+type entry struct {
+	key     string
+	loc     model.Location
+	expires time.Time
+}
+
+// GeoCache is an in-memory LRU cache of geocoding results, the same address
+// is only sent to the provider once per TTL.
+type GeoCache struct {
+	gc    geoCoder
+	ttl   time.Duration
+	size  int
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+// NewGeoCache wraps gc keeping up to size results for ttl.
+func NewGeoCache(gc geoCoder, size int, ttl time.Duration) *GeoCache {
+	if size <= 0 {
+		size = 1024
+	}
+	return &GeoCache{gc: gc, ttl: ttl, size: size, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func key(add string) string {
+	return strings.Join(strings.Fields(strings.ToLower(add)), " ")
+}
+
+// Get returns the cached location of add without calling the provider.
+func (c *GeoCache) Get(add string) (model.Location, bool) {
+	k := key(add)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[k]
+	if !ok {
+		return model.Location{}, false
+	}
+	e := el.Value.(*entry)
+	if c.ttl > 0 && time.Now().After(e.expires) {
+		c.ll.Remove(el)
+		delete(c.items, k)
+		return model.Location{}, false
+	}
+	c.ll.MoveToFront(el)
+	return e.loc, true
+}
+
+// Set stores loc as the location of add.
+func (c *GeoCache) Set(add string, loc model.Location) {
+	k := key(add)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := &entry{key: k, loc: loc, expires: time.Now().Add(c.ttl)}
+	if el, ok := c.items[k]; ok {
+		el.Value = e
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[k] = c.ll.PushFront(e)
+	if c.ll.Len() > c.size {
+		last := c.ll.Back()
+		c.ll.Remove(last)
+		delete(c.items, last.Value.(*entry).key)
+	}
+}
+
+func (c *GeoCache) GeoCode(ctx context.Context, add string) (model.Location, error) {
+	ctx, span := tracer.Start(ctx, "GeoCache.GeoCode")
+	defer span.End()
+	if loc, ok := c.Get(add); ok {
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		metrics.CacheResult(name, true)
+		return loc, nil
+	}
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+	metrics.CacheResult(name, false)
+	loc, err := c.gc.GeoCode(ctx, add)
+	if err != nil {
+		return model.Location{}, err
+	}
+	c.Set(add, loc)
+	return loc, nil
+}
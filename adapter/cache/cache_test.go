@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/modular-project/address-service/model"
+)
+
+type countGeoCoder struct {
+	calls int
+}
+
+func (c *countGeoCoder) GeoCode(_ context.Context, add string) (model.Location, error) {
+	c.calls++
+	return model.Location{Type: "Point", Coordinates: []float64{float64(len(add)), 0}}, nil
+}
+
+func TestGeoCache_GeoCode(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		ttl       time.Duration
+		adds      []string
+		wantCalls int
+	}{
+		{
+			name:      "same address is geocoded once",
+			size:      10,
+			ttl:       time.Hour,
+			adds:      []string{"test 1, city", "Test 1,  CITY", "test 1, city"},
+			wantCalls: 1,
+		}, {
+			name:      "least recently used is evicted",
+			size:      1,
+			ttl:       time.Hour,
+			adds:      []string{"a", "b", "a"},
+			wantCalls: 3,
+		}, {
+			name:      "expired entry is geocoded again",
+			size:      10,
+			ttl:       time.Nanosecond,
+			adds:      []string{"a", "a"},
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gc := &countGeoCoder{}
+			c := NewGeoCache(gc, tt.size, tt.ttl)
+			for _, add := range tt.adds {
+				got, err := c.GeoCode(context.Background(), add)
+				if err != nil {
+					t.Fatalf("GeoCache.GeoCode() error = %v", err)
+				}
+				want, _ := (&countGeoCoder{}).GeoCode(context.Background(), key(add))
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("GeoCache.GeoCode() = %v, want %v", got, want)
+				}
+			}
+			if gc.calls != tt.wantCalls {
+				t.Errorf("GeoCache.GeoCode() calls = %d, want %d", gc.calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -2,12 +2,43 @@ package gmaps
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"strings"
 
 	"github.com/modular-project/address-service/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
 	"googlemaps.github.io/maps"
 )
 
+var tracer = otel.Tracer("github.com/modular-project/address-service/adapter/gmap")
+
+// Provider is the name of the geocoder stored with its locations.
+const Provider = "google"
+
+// ErrNoResults is returned when the address could not be geocoded.
+var ErrNoResults = errors.New("geocode: no results")
+
+// IsTransient reports whether err may succeed on a retry: timeouts, network
+// errors and the UNKNOWN_ERROR and OVER_QUERY_LIMIT statuses. A canceled
+// request is not, the caller is gone.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, ErrNoResults) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "maps: UNKNOWN_ERROR") || strings.Contains(msg, "maps: OVER_QUERY_LIMIT")
+}
+
 type gMapService struct {
 	c *maps.Client
 }
@@ -21,20 +52,29 @@ func NewGMapService(key string) (gMapService, error) {
 }
 
 func (gm gMapService) GeoCode(ctx context.Context, add string) (model.Location, error) {
+	ctx, span := tracer.Start(ctx, "gmaps.GeoCode")
+	defer span.End()
 	gr := maps.GeocodingRequest{
 		Address: add,
 	}
 	res, err := gm.c.Geocode(ctx, &gr)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "geocode")
 		return model.Location{}, fmt.Errorf("geocode: %w", err)
 	}
-	if res == nil {
-		return model.Location{}, fmt.Errorf("geocode: nil response")
+	if len(res) == 0 {
+		span.SetStatus(codes.Error, "no results")
+		return model.Location{}, ErrNoResults
 	}
 	r := res[0]
 	loc := model.Location{
 		Type:        "Point",
 		Coordinates: []float64{r.Geometry.Location.Lng, r.Geometry.Location.Lat},
+		Matches:     len(res),
+		Partial:     r.PartialMatch,
+		Provider:    Provider,
+		Precision:   r.Geometry.LocationType,
 	}
 	return loc, nil
 }
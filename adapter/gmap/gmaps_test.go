@@ -2,6 +2,9 @@ package gmaps
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
 	"reflect"
 	"testing"
@@ -47,3 +50,32 @@ func Test_gMapService_GeoCode(t *testing.T) {
 		})
 	}
 }
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "no error"},
+		{name: "no results", err: ErrNoResults},
+		{name: "timeout", err: fmt.Errorf("geocode: %w", context.DeadlineExceeded), want: true},
+		{name: "network", err: &url.Error{Op: "Get", URL: "https://maps.googleapis.com", Err: &timeoutError{}}, want: true},
+		{name: "canceled", err: &url.Error{Op: "Get", URL: "https://maps.googleapis.com", Err: context.Canceled}},
+		{name: "over query limit", err: errors.New("maps: OVER_QUERY_LIMIT - "), want: true},
+		{name: "denied", err: errors.New("maps: REQUEST_DENIED - ")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransient(tt.err); got != tt.want {
+				t.Errorf("IsTransient(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
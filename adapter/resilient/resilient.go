@@ -0,0 +1,177 @@
+package resilient
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/modular-project/address-service/controller"
+	"github.com/modular-project/address-service/metrics"
+	"github.com/modular-project/address-service/model"
+)
+
+// Config of GeoCoder, zero values disable each protection.
+type Config struct {
+	// Timeout of each attempt
+	Timeout time.Duration
+	// Retries after the first attempt, only for transient errors
+	Retries int
+	// Backoff before the first retry, doubled after each one with jitter
+	Backoff time.Duration
+	// BreakerFailures is the number of consecutive failures opening the
+	// circuit, 0 disables the breaker
+	BreakerFailures int
+	// BreakerCooldown is how long the circuit stays open before a probe
+	BreakerCooldown time.Duration
+	// DailyBudget is the number of provider requests allowed per day, 0 is
+	// unlimited
+	DailyBudget int
+	// Location defines when a day starts for the budget
+	Location *time.Location
+}
+
+// GeoCoder protects a provider with timeouts, retries, a circuit breaker and
+// a daily budget. When the circuit is open or the budget is exhausted it
+// fails fast with controller.ErrGeoCoderUnavailable, the cache in front of
+// it keeps serving known addresses.
+type GeoCoder struct {
+	gc        controller.GeoCoder
+	provider  string
+	c         Config
+	transient func(error) bool
+	now       func() time.Time
+	sleep     func(context.Context, time.Duration) error
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+	day      string
+	used     int
+}
+
+// NewGeoCoder wraps gc, transient classifies the errors worth a retry.
+func NewGeoCoder(gc controller.GeoCoder, provider string, c Config, transient func(error) bool) *GeoCoder {
+	if c.Location == nil {
+		c.Location = time.UTC
+	}
+	return &GeoCoder{gc: gc, provider: provider, c: c, transient: transient, now: time.Now, sleep: sleep}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// allow reserves a request against the breaker and the budget.
+func (g *GeoCoder) allow() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	if g.c.BreakerFailures > 0 && g.failures >= g.c.BreakerFailures {
+		if now.Sub(g.openedAt) < g.c.BreakerCooldown || g.probing {
+			return fmt.Errorf("%w: circuit open", controller.ErrGeoCoderUnavailable)
+		}
+		// half open, let a single request through
+		g.probing = true
+		metrics.GeocoderCircuit(g.provider, metrics.CircuitHalfOpen)
+	}
+	if g.c.DailyBudget > 0 {
+		day := now.In(g.c.Location).Format("2006-01-02")
+		if day != g.day {
+			g.day, g.used = day, 0
+		}
+		if g.used >= g.c.DailyBudget {
+			g.probing = false
+			return fmt.Errorf("%w: daily budget of %d requests exhausted", controller.ErrGeoCoderUnavailable, g.c.DailyBudget)
+		}
+		g.used++
+		metrics.GeocoderBudgetUsed(g.provider, g.used)
+	}
+	return nil
+}
+
+// Available returns controller.ErrGeoCoderUnavailable while the circuit is
+// open or the budget of today is exhausted, without calling the provider.
+// The circuit stays reported open until a request succeeds.
+func (g *GeoCoder) Available() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.c.BreakerFailures > 0 && g.failures >= g.c.BreakerFailures {
+		return fmt.Errorf("%w: circuit open", controller.ErrGeoCoderUnavailable)
+	}
+	if g.c.DailyBudget > 0 && g.day == g.now().In(g.c.Location).Format("2006-01-02") && g.used >= g.c.DailyBudget {
+		return fmt.Errorf("%w: daily budget of %d requests exhausted", controller.ErrGeoCoderUnavailable, g.c.DailyBudget)
+	}
+	return nil
+}
+
+// record updates the breaker with the result of a request.
+func (g *GeoCoder) record(failed bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.probing = false
+	if !failed {
+		if g.failures >= g.c.BreakerFailures && g.c.BreakerFailures > 0 {
+			metrics.GeocoderCircuit(g.provider, metrics.CircuitClosed)
+		}
+		g.failures = 0
+		return
+	}
+	g.failures++
+	if g.c.BreakerFailures > 0 && g.failures >= g.c.BreakerFailures {
+		g.openedAt = g.now()
+		metrics.GeocoderCircuit(g.provider, metrics.CircuitOpen)
+	}
+}
+
+// abandon releases the half open probe of a request without result.
+func (g *GeoCoder) abandon() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.probing = false
+}
+
+func (g *GeoCoder) attempt(ctx context.Context, add string) (model.Location, error) {
+	if g.c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.c.Timeout)
+		defer cancel()
+	}
+	return g.gc.GeoCode(ctx, add)
+}
+
+func (g *GeoCoder) GeoCode(ctx context.Context, add string) (model.Location, error) {
+	backoff := g.c.Backoff
+	for i := 0; ; i++ {
+		if err := g.allow(); err != nil {
+			return model.Location{}, err
+		}
+		loc, err := g.attempt(ctx, add)
+		if ctx.Err() != nil {
+			// the caller gave up, neither retried nor the provider's failure
+			g.abandon()
+			return loc, err
+		}
+		transient := err != nil && g.transient(err)
+		// only provider failures count for the breaker, an address without
+		// results is a valid answer
+		g.record(transient)
+		if !transient || i >= g.c.Retries {
+			return loc, err
+		}
+		d := backoff/2 + time.Duration(rand.Int63n(int64(backoff)+1))
+		if err := g.sleep(ctx, d); err != nil {
+			return model.Location{}, fmt.Errorf("retry: %w", err)
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,159 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/modular-project/address-service/controller"
+	"github.com/modular-project/address-service/model"
+)
+
+var (
+	errTransient = errors.New("transient")
+	errNoResults = errors.New("no results")
+)
+
+type fakeGeoCoder struct {
+	calls int
+	errs  []error
+}
+
+func (f *fakeGeoCoder) GeoCode(context.Context, string) (model.Location, error) {
+	f.calls++
+	if len(f.errs) != 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		if err != nil {
+			return model.Location{}, err
+		}
+	}
+	return model.Location{Type: "Point", Coordinates: []float64{-103.3266212, 20.6545464}}, nil
+}
+
+func TestGeoCoder_GeoCode(t *testing.T) {
+	type call struct {
+		advance   time.Duration
+		canceled  bool
+		errs      []error
+		wantErr   error
+		wantCalls int
+	}
+	tests := []struct {
+		name  string
+		c     Config
+		calls []call
+	}{
+		{
+			name: "retries transient errors",
+			c:    Config{Retries: 2},
+			calls: []call{
+				{errs: []error{errTransient, errTransient}, wantCalls: 3},
+				{errs: []error{errTransient, errTransient, errTransient}, wantErr: errTransient, wantCalls: 3},
+			},
+		}, {
+			name: "does not retry other errors",
+			c:    Config{Retries: 2},
+			calls: []call{
+				{errs: []error{errNoResults}, wantErr: errNoResults, wantCalls: 1},
+			},
+		}, {
+			name: "circuit opens and closes",
+			c:    Config{BreakerFailures: 2, BreakerCooldown: time.Minute},
+			calls: []call{
+				{errs: []error{errTransient}, wantErr: errTransient, wantCalls: 1},
+				{errs: []error{errTransient}, wantErr: errTransient, wantCalls: 1},
+				{wantErr: controller.ErrGeoCoderUnavailable},
+				{advance: time.Minute, errs: []error{errTransient}, wantErr: errTransient, wantCalls: 1},
+				{wantErr: controller.ErrGeoCoderUnavailable},
+				{advance: time.Minute, wantCalls: 1},
+				{wantCalls: 1},
+			},
+		}, {
+			name: "canceled calls neither retry nor open the circuit",
+			c:    Config{Retries: 2, BreakerFailures: 1, BreakerCooldown: time.Minute},
+			calls: []call{
+				{canceled: true, errs: []error{errTransient}, wantErr: errTransient, wantCalls: 1},
+				{wantCalls: 1},
+			},
+		}, {
+			name: "daily budget",
+			c:    Config{DailyBudget: 2, Retries: 3},
+			calls: []call{
+				{wantCalls: 1},
+				{errs: []error{errTransient}, wantErr: controller.ErrGeoCoderUnavailable, wantCalls: 1},
+				{wantErr: controller.ErrGeoCoderUnavailable},
+				{advance: 24 * time.Hour, wantCalls: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+			fgc := &fakeGeoCoder{}
+			g := NewGeoCoder(fgc, "test", tt.c, func(err error) bool { return errors.Is(err, errTransient) })
+			g.now = func() time.Time { return now }
+			g.sleep = func(context.Context, time.Duration) error { return nil }
+			for i, c := range tt.calls {
+				now = now.Add(c.advance)
+				fgc.calls, fgc.errs = 0, c.errs
+				ctx, cancel := context.WithCancel(context.Background())
+				if c.canceled {
+					cancel()
+				}
+				_, err := g.GeoCode(ctx, "address")
+				cancel()
+				if !errors.Is(err, c.wantErr) {
+					t.Fatalf("call %d: GeoCoder.GeoCode() error = %v, want %v", i, err, c.wantErr)
+				}
+				if fgc.calls != c.wantCalls {
+					t.Errorf("call %d: provider called %d times, want %d", i, fgc.calls, c.wantCalls)
+				}
+			}
+		})
+	}
+}
+
+func TestGeoCoder_Available(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	fgc := &fakeGeoCoder{}
+	g := NewGeoCoder(fgc, "test", Config{BreakerFailures: 1, BreakerCooldown: time.Minute, DailyBudget: 2}, func(err error) bool { return errors.Is(err, errTransient) })
+	g.now = func() time.Time { return now }
+	calls := []struct {
+		advance time.Duration
+		err     error
+		want    error
+	}{
+		{},
+		{err: errTransient, want: controller.ErrGeoCoderUnavailable},
+		// still open after the cooldown until a request succeeds
+		{advance: time.Minute, want: controller.ErrGeoCoderUnavailable},
+		{advance: 24 * time.Hour},
+	}
+	if err := g.Available(); err != nil {
+		t.Fatalf("GeoCoder.Available() before any request = %v", err)
+	}
+	for i, c := range calls {
+		now = now.Add(c.advance)
+		fgc.errs = []error{c.err}
+		g.GeoCode(context.Background(), "address")
+		if err := g.Available(); !errors.Is(err, c.want) {
+			t.Errorf("call %d: GeoCoder.Available() = %v, want %v", i, err, c.want)
+		}
+	}
+	if fgc.calls != 3 {
+		t.Errorf("provider called %d times, Available must not call it", fgc.calls)
+	}
+	g = NewGeoCoder(fgc, "test", Config{DailyBudget: 1}, nil)
+	g.now = func() time.Time { return now }
+	fgc.errs = nil
+	g.GeoCode(context.Background(), "address")
+	if err := g.Available(); !errors.Is(err, controller.ErrGeoCoderUnavailable) {
+		t.Errorf("GeoCoder.Available() with the budget exhausted = %v", err)
+	}
+	now = now.Add(24 * time.Hour)
+	if err := g.Available(); err != nil {
+		t.Errorf("GeoCoder.Available() the next day = %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+// Package audit describes who changed an address, the entries are written
+// with the change itself.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/modular-project/address-service/metrics"
+	"github.com/modular-project/address-service/model"
+	"github.com/modular-project/address-service/tenant"
+)
+
+// Meta is the origin of the changes made in a context.
+type Meta struct {
+	// Actor is the authenticated subject, e.g. forwarded by the gateway
+	Actor     string
+	RPC       string
+	RequestID string
+}
+
+// Unknown is the actor of the changes without Meta.
+const Unknown = "unknown"
+
+type key struct{}
+
+// NewContext returns a copy of ctx whose changes come from m.
+func NewContext(ctx context.Context, m Meta) context.Context {
+	return context.WithValue(ctx, key{}, m)
+}
+
+// FromContext returns the Meta of ctx, its Actor is Unknown when missing.
+func FromContext(ctx context.Context) Meta {
+	m, _ := ctx.Value(key{}).(Meta)
+	if m.Actor == "" {
+		m.Actor = Unknown
+	}
+	return m
+}
+
+func location(l model.Location) string {
+	if len(l.Coordinates) != 2 {
+		return ""
+	}
+	return fmt.Sprintf("%.6f,%.6f", l.Coordinates[0], l.Coordinates[1])
+}
+
+func hours(h *model.OpeningHours) string {
+	if h == nil {
+		return ""
+	}
+	return h.String()
+}
+
+// Diff returns the fields that differ between before and after, a zero
+// address stands for a missing one.
+func Diff(before, after model.Address) []model.FieldChange {
+	var cs []model.FieldChange
+	for _, f := range []struct {
+		name          string
+		before, after string
+	}{
+		{"external_key", before.ExternalKey, after.ExternalKey},
+		{"street", before.Street, after.Street},
+		{"suburb", before.Suburb, after.Suburb},
+		{"city", before.City, after.City},
+		{"pc", before.PostalCode, after.PostalCode},
+		{"state", before.State, after.State},
+		{"country", before.Country, after.Country},
+		{"location", location(before.Location), location(after.Location)},
+		{"is_deleted", strconv.FormatBool(before.IsDeleted), strconv.FormatBool(after.IsDeleted)},
+		{"name", before.Name, after.Name},
+		{"phone", before.Phone, after.Phone},
+		{"hours", hours(before.Hours), hours(after.Hours)},
+		{"suspended", strconv.FormatBool(before.Suspended), strconv.FormatBool(after.Suspended)},
+		{"priority", strconv.Itoa(before.Priority), strconv.Itoa(after.Priority)},
+		{"capacity", strconv.Itoa(before.Capacity), strconv.Itoa(after.Capacity)},
+	} {
+		if f.before != f.after {
+			cs = append(cs, model.FieldChange{Field: f.name, Before: f.before, After: f.after})
+		}
+	}
+	return cs
+}
+
+// personal are the fields of a delivery address whose values are not
+// audited, only that they changed. They are encrypted in the addresses.
+var personal = map[string]bool{"street": true, "suburb": true, "pc": true, "location": true}
+
+// redact removes the values of the personal fields of cs.
+func redact(cs []model.FieldChange) []model.FieldChange {
+	for i := range cs {
+		if personal[cs[i].Field] {
+			cs[i].Before, cs[i].After = "", ""
+		}
+	}
+	return cs
+}
+
+// NewEntry returns the entry of op on the address id of kind, made in ctx.
+// The personal data of the delivery addresses is redacted.
+func NewEntry(ctx context.Context, op, kind, id string, uID uint64, before, after model.Address) model.AuditEntry {
+	m := FromContext(ctx)
+	t, _ := tenant.FromContext(ctx)
+	cs := Diff(before, after)
+	if kind == metrics.Delivery {
+		cs = redact(cs)
+	}
+	return model.AuditEntry{
+		TenantID:  t,
+		Kind:      kind,
+		AddressID: id,
+		UserID:    uID,
+		Op:        op,
+		Actor:     m.Actor,
+		RPC:       m.RPC,
+		RequestID: m.RequestID,
+		At:        time.Now(),
+		Changes:   cs,
+	}
+}
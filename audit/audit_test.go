@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/modular-project/address-service/metrics"
+	"github.com/modular-project/address-service/model"
+)
+
+func TestDiff(t *testing.T) {
+	a := model.Address{
+		Street:   "Av. Vallarta 1",
+		City:     "Guadalajara",
+		Location: model.Location{Type: "Point", Coordinates: []float64{-103.3, 20.6}},
+	}
+	moved := a
+	moved.Street = "Av. Vallarta 2"
+	moved.Location = model.Location{Type: "Point", Coordinates: []float64{-103.31, 20.6}}
+	deleted := a
+	deleted.IsDeleted = true
+	tests := []struct {
+		name          string
+		before, after model.Address
+		want          []model.FieldChange
+	}{
+		{
+			name:  "create",
+			after: a,
+			want: []model.FieldChange{
+				{Field: "street", After: "Av. Vallarta 1"},
+				{Field: "city", After: "Guadalajara"},
+				{Field: "location", After: "-103.300000,20.600000"},
+			},
+		}, {
+			name:   "update",
+			before: a,
+			after:  moved,
+			want: []model.FieldChange{
+				{Field: "street", Before: "Av. Vallarta 1", After: "Av. Vallarta 2"},
+				{Field: "location", Before: "-103.300000,20.600000", After: "-103.310000,20.600000"},
+			},
+		}, {
+			name:   "soft delete",
+			before: a,
+			after:  deleted,
+			want:   []model.FieldChange{{Field: "is_deleted", Before: "false", After: "true"}},
+		}, {
+			name:   "unchanged",
+			before: a,
+			after:  a,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewEntry(t *testing.T) {
+	a := model.Address{
+		Street:     "Av. Vallarta 1",
+		Suburb:     "Americana",
+		PostalCode: "44160",
+		City:       "Guadalajara",
+		Location:   model.Location{Type: "Point", Coordinates: []float64{-103.3, 20.6}},
+	}
+	tests := []struct {
+		name string
+		kind string
+		want []model.FieldChange
+	}{
+		{
+			name: "delivery",
+			kind: metrics.Delivery,
+			want: []model.FieldChange{
+				{Field: "street"},
+				{Field: "suburb"},
+				{Field: "city", After: "Guadalajara"},
+				{Field: "pc"},
+				{Field: "location"},
+			},
+		}, {
+			name: "establishment",
+			kind: metrics.Establishment,
+			want: []model.FieldChange{
+				{Field: "street", After: "Av. Vallarta 1"},
+				{Field: "suburb", After: "Americana"},
+				{Field: "city", After: "Guadalajara"},
+				{Field: "pc", After: "44160"},
+				{Field: "location", After: "-103.300000,20.600000"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewEntry(context.Background(), model.AuditCreate, tt.kind, "id", 1, model.Address{}, a)
+			if !reflect.DeepEqual(got.Changes, tt.want) {
+				t.Errorf("NewEntry() changes = %+v, want %+v", got.Changes, tt.want)
+			}
+		})
+	}
+}
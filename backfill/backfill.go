@@ -0,0 +1,185 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/modular-project/address-service/controller"
+	"github.com/modular-project/address-service/metrics"
+	"github.com/modular-project/address-service/model"
+	"github.com/modular-project/address-service/ratelimit"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// checkpointEvery is the number of addresses between two checkpoints.
+const checkpointEvery = 50
+
+// errLimit stops a job after its limit of addresses.
+var errLimit = errors.New("limit reached")
+
+type Storager interface {
+	EachStale(context.Context, model.StaleFilter, primitive.ObjectID, func(model.Delivery) error) error
+	Geocoded(context.Context, primitive.ObjectID, model.Location) error
+}
+
+type Recorder interface {
+	Checkpoint(context.Context, string) (model.BackfillCheckpoint, error)
+	SaveCheckpoint(context.Context, *model.BackfillCheckpoint) error
+	Record(context.Context, *model.BackfillChange) error
+}
+
+type Options struct {
+	// Job names the checkpoint, a job started again resumes after the last
+	// address it processed
+	Job    string
+	Kind   string
+	Filter model.StaleFilter
+	// Rate of geocoding requests per second
+	Rate float64
+	// Limit of addresses of the run, 0 is unlimited
+	Limit int
+	// DryRun geocodes and records the changes without updating the
+	// addresses, it neither resumes nor saves a checkpoint
+	DryRun bool
+	// Restart ignores the checkpoint of Job
+	Restart bool
+}
+
+// Backfill geocodes again the addresses selected by a filter, its GeoCoder
+// must not be cached or it would return the stale locations.
+type Backfill struct {
+	gc  controller.GeoCoder
+	st  map[string]Storager
+	rec Recorder
+	rl  ratelimit.Store
+	now func() time.Time
+}
+
+// New returns a Backfill of the establishments est and delivery addresses
+// del, rl paces the geocoding requests.
+func New(gc controller.GeoCoder, est, del Storager, rec Recorder, rl ratelimit.Store) Backfill {
+	return Backfill{
+		gc:  gc,
+		st:  map[string]Storager{metrics.Establishment: est, metrics.Delivery: del},
+		rec: rec,
+		rl:  rl,
+		now: time.Now,
+	}
+}
+
+// wait blocks until the rate allows another request.
+func (b Backfill) wait(ctx context.Context, rate float64) error {
+	if rate <= 0 {
+		return nil
+	}
+	for {
+		ok, d, err := b.rl.Take(ctx, "backfill", rate, 1)
+		if err != nil {
+			return fmt.Errorf("take: %w", err)
+		}
+		if ok {
+			return nil
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Run processes the addresses of o.Kind and returns the checkpoint reached.
+// It stops on the first error that is not about a single address, e.g. the
+// geocoder budget is exhausted, the job resumes from the last checkpoint.
+func (b Backfill) Run(ctx context.Context, o Options) (model.BackfillCheckpoint, error) {
+	st, ok := b.st[o.Kind]
+	if !ok {
+		return model.BackfillCheckpoint{}, fmt.Errorf("unknown kind %q", o.Kind)
+	}
+	cp := model.BackfillCheckpoint{ID: o.Job + ":" + o.Kind}
+	if !o.DryRun && !o.Restart {
+		var err error
+		if cp, err = b.rec.Checkpoint(ctx, cp.ID); err != nil {
+			return cp, fmt.Errorf("checkpoint: %w", err)
+		}
+		if cp.Done {
+			return cp, nil
+		}
+	}
+	save := func() error {
+		if o.DryRun {
+			return nil
+		}
+		cp.UpdatedAt = b.now()
+		if err := b.rec.SaveCheckpoint(ctx, &cp); err != nil {
+			return fmt.Errorf("save checkpoint: %w", err)
+		}
+		return nil
+	}
+	n := 0
+	err := st.EachStale(ctx, o.Filter, cp.LastID, func(d model.Delivery) error {
+		if o.Limit > 0 && n >= o.Limit {
+			return errLimit
+		}
+		n++
+		if err := b.address(ctx, st, o, &cp, &d.Address); err != nil {
+			return err
+		}
+		cp.LastID = d.ID
+		cp.Processed++
+		if cp.Processed%checkpointEvery == 0 {
+			return save()
+		}
+		return nil
+	})
+	if errors.Is(err, errLimit) {
+		err = nil
+	} else if err == nil {
+		cp.Done = true
+	}
+	if serr := save(); err == nil {
+		err = serr
+	}
+	return cp, err
+}
+
+// address geocodes a again, a geocoding error of a is recorded and counted
+// in cp, any other error stops the job before a is processed.
+func (b Backfill) address(ctx context.Context, st Storager, o Options, cp *model.BackfillCheckpoint, a *model.Address) error {
+	if err := b.wait(ctx, o.Rate); err != nil {
+		return err
+	}
+	ch := model.BackfillChange{
+		Job:       o.Job,
+		Kind:      o.Kind,
+		AddressID: a.ID,
+		Before:    a.Location.Coordinates,
+		DryRun:    o.DryRun,
+		At:        b.now(),
+	}
+	loc, err := b.gc.GeoCode(ctx, a.String())
+	switch {
+	case err != nil && (errors.Is(err, controller.ErrGeoCoderUnavailable) || ctx.Err() != nil):
+		return fmt.Errorf("geocode: %w", err)
+	case err != nil:
+		ch.Error = err.Error()
+		cp.Failed++
+	default:
+		ch.After, ch.Quality = loc.Coordinates, loc.Precision
+		ch.ShiftMeters = model.Distance(ch.Before, ch.After)
+		if !o.DryRun {
+			if err := st.Geocoded(ctx, a.ID, loc); err != nil {
+				return fmt.Errorf("geocoded: %w", err)
+			}
+			cp.Updated++
+		}
+	}
+	if err := b.rec.Record(ctx, &ch); err != nil {
+		return fmt.Errorf("record: %w", err)
+	}
+	return nil
+}
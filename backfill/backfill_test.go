@@ -0,0 +1,134 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/modular-project/address-service/controller"
+	"github.com/modular-project/address-service/metrics"
+	"github.com/modular-project/address-service/model"
+	"github.com/modular-project/address-service/ratelimit"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type fakeGeoCoder struct{}
+
+func (fakeGeoCoder) GeoCode(_ context.Context, add string) (model.Location, error) {
+	switch {
+	case strings.HasPrefix(add, "unknown"):
+		return model.Location{}, errors.New("no results")
+	case strings.HasPrefix(add, "budget"):
+		return model.Location{}, controller.ErrGeoCoderUnavailable
+	}
+	return model.Location{Type: "Point", Coordinates: []float64{-103.35, 20.67}, Precision: "ROOFTOP"}, nil
+}
+
+type fakeStorage struct {
+	ds      []model.Delivery
+	updated int
+}
+
+func (f *fakeStorage) EachStale(_ context.Context, _ model.StaleFilter, after primitive.ObjectID, fn func(model.Delivery) error) error {
+	for _, d := range f.ds {
+		if d.ID.Hex() <= after.Hex() && !after.IsZero() {
+			continue
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeStorage) Geocoded(context.Context, primitive.ObjectID, model.Location) error {
+	f.updated++
+	return nil
+}
+
+type fakeRecorder struct {
+	cps     map[string]model.BackfillCheckpoint
+	changes []model.BackfillChange
+}
+
+func (f *fakeRecorder) Checkpoint(_ context.Context, id string) (model.BackfillCheckpoint, error) {
+	if cp, ok := f.cps[id]; ok {
+		return cp, nil
+	}
+	return model.BackfillCheckpoint{ID: id}, nil
+}
+
+func (f *fakeRecorder) SaveCheckpoint(_ context.Context, cp *model.BackfillCheckpoint) error {
+	f.cps[cp.ID] = *cp
+	return nil
+}
+
+func (f *fakeRecorder) Record(_ context.Context, ch *model.BackfillChange) error {
+	f.changes = append(f.changes, *ch)
+	return nil
+}
+
+func delivery(i byte, street string) model.Delivery {
+	var id primitive.ObjectID
+	id[11] = i
+	return model.Delivery{Address: model.Address{ID: id, Street: street}}
+}
+
+func TestBackfill_Run(t *testing.T) {
+	type run struct {
+		o           Options
+		wantErr     error
+		want        model.BackfillCheckpoint
+		wantUpdated int
+	}
+	tests := []struct {
+		name string
+		ds   []model.Delivery
+		runs []run
+	}{
+		{
+			name: "resumes after limit",
+			ds:   []model.Delivery{delivery(1, "a"), delivery(2, "unknown"), delivery(3, "c")},
+			runs: []run{
+				{o: Options{Limit: 2}, want: model.BackfillCheckpoint{Processed: 2, Updated: 1, Failed: 1}, wantUpdated: 1},
+				{o: Options{}, want: model.BackfillCheckpoint{Processed: 3, Updated: 2, Failed: 1, Done: true}, wantUpdated: 2},
+				{o: Options{}, want: model.BackfillCheckpoint{Processed: 3, Updated: 2, Failed: 1, Done: true}, wantUpdated: 2},
+			},
+		}, {
+			name: "dry run",
+			ds:   []model.Delivery{delivery(1, "a"), delivery(2, "b")},
+			runs: []run{
+				{o: Options{DryRun: true}, want: model.BackfillCheckpoint{Processed: 2, Done: true}},
+				{o: Options{}, want: model.BackfillCheckpoint{Processed: 2, Updated: 2, Done: true}, wantUpdated: 2},
+			},
+		}, {
+			name: "stops when the geocoder is unavailable",
+			ds:   []model.Delivery{delivery(1, "a"), delivery(2, "budget"), delivery(3, "c")},
+			runs: []run{
+				{o: Options{}, wantErr: controller.ErrGeoCoderUnavailable, want: model.BackfillCheckpoint{Processed: 1, Updated: 1}, wantUpdated: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &fakeStorage{ds: tt.ds}
+			rec := &fakeRecorder{cps: map[string]model.BackfillCheckpoint{}}
+			bf := New(fakeGeoCoder{}, nil, st, rec, ratelimit.NewMemoryStore())
+			for i, r := range tt.runs {
+				r.o.Job, r.o.Kind = "test", metrics.Delivery
+				cp, err := bf.Run(context.Background(), r.o)
+				if !errors.Is(err, r.wantErr) {
+					t.Fatalf("run %d: Backfill.Run() error = %v, wantErr %v", i, err, r.wantErr)
+				}
+				got := model.BackfillCheckpoint{Processed: cp.Processed, Updated: cp.Updated, Failed: cp.Failed, Done: cp.Done}
+				if got != r.want {
+					t.Errorf("run %d: Backfill.Run() = %+v, want %+v", i, got, r.want)
+				}
+				if st.updated != r.wantUpdated {
+					t.Errorf("run %d: Backfill.Run() updated %d addresses, want %d", i, st.updated, r.wantUpdated)
+				}
+			}
+		})
+	}
+}
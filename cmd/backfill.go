@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/modular-project/address-service/backfill"
+	"github.com/modular-project/address-service/config"
+	"github.com/modular-project/address-service/metrics"
+	"github.com/modular-project/address-service/ratelimit"
+	"github.com/modular-project/address-service/storage"
+)
+
+const backfillUsage = `usage: address-service backfill -job NAME [flags]
+
+Geocodes again the addresses matching every given filter, bypassing the
+geocode cache. The old and new coordinates of each address are stored in the
+backfill_change collection. Running a job again resumes it after the last
+checkpoint.
+`
+
+// runBackfill is the backfill command, it returns the exit status.
+func runBackfill(args []string) int {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), backfillUsage)
+		fs.PrintDefaults()
+	}
+	var o backfill.Options
+	fs.StringVar(&o.Job, "job", "", "name of the job, required")
+	kind := fs.String("kind", "all", "establishment, delivery or all")
+	fs.BoolVar(&o.Filter.MissingLocation, "missing", false, "only addresses without coordinates")
+	fs.StringVar(&o.Filter.Provider, "provider", "", "only addresses geocoded by provider, none for those without provider")
+	olderThan := fs.Duration("older-than", 0, "only addresses geocoded longer ago, or never")
+	quality := fs.String("quality", "", "only addresses with one of these comma separated qualities, e.g. APPROXIMATE,GEOMETRIC_CENTER")
+	fs.Float64Var(&o.Rate, "rate", 1, "geocoding requests per second, 0 is unlimited")
+	fs.IntVar(&o.Limit, "limit", 0, "addresses per kind in this run, 0 is unlimited")
+	fs.BoolVar(&o.DryRun, "dry-run", false, "record the changes without updating the addresses")
+	fs.BoolVar(&o.Restart, "restart", false, "ignore the checkpoint of the job")
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if o.Job == "" {
+		fs.Usage()
+		return 2
+	}
+	if *olderThan > 0 {
+		o.Filter.GeocodedBefore = time.Now().Add(-*olderThan)
+	}
+	if *quality != "" {
+		o.Filter.Qualities = strings.Split(*quality, ",")
+	}
+	kinds := []string{metrics.Establishment, metrics.Delivery}
+	if *kind != "all" {
+		kinds = []string{*kind}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	conn := newDBConnection(cfg.DB)
+	mgr, err := storage.Connect(ctx, &conn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer mgr.Close(context.Background())
+	db := mgr.Database("")
+	gc, err := newProvider(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	dst, err := newDeliveryStorage(cfg, db)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	bf := backfill.New(gc,
+		storage.NewAddressStorage(db, cfg.Nearest.MaxDistance, cfg.DB.EstablishmentCollection),
+		dst,
+		storage.NewBackfillStorage(db),
+		ratelimit.NewMemoryStore(),
+	)
+	status := 0
+	for _, k := range kinds {
+		o.Kind = k
+		cp, err := bf.Run(ctx, o)
+		fmt.Fprintf(os.Stderr, "%s: processed %d, updated %d, failed %d, done %t\n",
+			cp.ID, cp.Processed, cp.Updated, cp.Failed, cp.Done)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", cp.ID, err)
+			return 1
+		}
+		if cp.Failed != 0 {
+			status = 1
+		}
+	}
+	return status
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/modular-project/address-service/config"
+	"github.com/modular-project/address-service/exporter"
+	"github.com/modular-project/address-service/logger"
+	"github.com/modular-project/address-service/storage"
+)
+
+const exportUsage = `usage: address-service export [flags]
+
+Writes the establishment or delivery addresses matching the filters as CSV,
+NDJSON or a GeoJSON FeatureCollection.
+`
+
+// runExport is the export command, it returns the exit status.
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), exportUsage)
+		fs.PrintDefaults()
+	}
+	var o exporter.Options
+	fs.StringVar(&o.Kind, "kind", "establishment", "establishment or delivery")
+	fs.StringVar(&o.Format, "format", exporter.CSV, "csv, ndjson or geojson")
+	fs.StringVar(&o.Filter.City, "city", "", "only addresses in city, ignoring case")
+	fs.StringVar(&o.Filter.State, "state", "", "only addresses in state, ignoring case")
+	fs.StringVar(&o.Filter.Country, "country", "", "only addresses in country, ignoring case")
+	fs.StringVar(&o.Filter.PostalCode, "pc", "", "only addresses with postal code")
+	fs.Uint64Var(&o.Filter.UserID, "user", 0, "only delivery addresses of user")
+	fs.StringVar(&o.Filter.GeocodeStatus, "geocode-status", "", "only addresses with status pending, done or failed")
+	fs.BoolVar(&o.Filter.IncludeDeleted, "include-deleted", false, "include the deleted delivery addresses")
+	fs.BoolVar(&o.StripPII, "strip-pii", false, "remove the street and suburb of delivery addresses, round their coordinates and replace their user by its pseudonym keyed by privacy.user_hash_key")
+	since := fs.String("since", "", "only addresses created at or after this RFC 3339 time")
+	until := fs.String("until", "", "only addresses created before this RFC 3339 time")
+	out := fs.String("out", "-", "output file, - writes to stdout")
+	tenantID := fs.String("tenant", "", "tenant of the addresses, by default tenancy.default")
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	for _, t := range []struct {
+		s string
+		t *time.Time
+	}{{*since, &o.Filter.Since}, {*until, &o.Filter.Until}} {
+		if t.s == "" {
+			continue
+		}
+		if *t.t, err = time.Parse(time.RFC3339, t.s); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	ctx, err = cliTenant(ctx, *tenantID, cfg.Tenancy)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	conn := newDBConnection(cfg.DB)
+	mgr, err := storage.Connect(ctx, &conn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer mgr.Close(context.Background())
+	db := mgr.Database("")
+	dst, err := newDeliveryStorage(cfg, db)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	uh, err := logger.NewUserHasher([]byte(cfg.Privacy.UserHashKey))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	ex := exporter.New(
+		storage.NewAddressStorage(db, cfg.Nearest.MaxDistance, cfg.DB.EstablishmentCollection),
+		dst,
+		uh,
+	)
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	n, err := ex.Export(ctx, bw, o)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "exported %d addresses\n", n)
+	return 0
+}
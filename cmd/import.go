@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/modular-project/address-service/audit"
+	"github.com/modular-project/address-service/config"
+	"github.com/modular-project/address-service/importer"
+	"github.com/modular-project/address-service/storage"
+)
+
+const importUsage = `usage: address-service import [flags] FILE
+
+Imports establishments from FILE, CSV with a header or JSON Lines, "-" reads
+stdin. Rows are upserted by their key and a JSON line per row is written to
+stdout. The exit status is 1 when a row is invalid or failed.
+`
+
+// runImport is the import command, it returns the exit status.
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), importUsage)
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "", "csv or jsonl, by default the extension of FILE")
+	dryRun := fs.Bool("dry-run", false, "validate and geocode the rows without writing them")
+	tenantID := fs.String("tenant", "", "tenant of the establishments, by default tenancy.default")
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	name := fs.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(name), ".")
+	}
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		defer f.Close()
+		r = f
+	}
+	src, err := importer.NewSource(r, *format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	ctx, err = cliTenant(ctx, *tenantID, cfg.Tenancy)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	ctx = audit.NewContext(ctx, cliActor("import"))
+	conn := newDBConnection(cfg.DB)
+	mgr, err := storage.Connect(ctx, &conn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer mgr.Close(context.Background())
+	ast := storage.NewAddressStorage(mgr.Database(""), cfg.Nearest.MaxDistance, cfg.DB.EstablishmentCollection)
+	if !*dryRun {
+		if err := ast.EnsureTenantIndexes(ctx); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	ob, err := newOutbox(ctx, cfg.Events, mgr.Database(""))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	aus := storage.NewAuditStorage(mgr.Database(""))
+	if err := aus.EnsureIndexes(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	gc, _, err := newGeoCoder(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	rep, err := importer.New(gc, ast, ob, aus, cfg.Geocoder.Workers).Import(ctx, src, *dryRun)
+	enc := json.NewEncoder(os.Stdout)
+	for _, res := range rep.Results {
+		if eerr := enc.Encode(res); eerr != nil {
+			fmt.Fprintln(os.Stderr, eerr)
+			return 1
+		}
+	}
+	fmt.Fprintf(os.Stderr, "created %d, updated %d, valid %d, invalid %d, failed %d, ambiguous %d\n",
+		rep.Count(importer.Created), rep.Count(importer.Updated), rep.Count(importer.Valid),
+		rep.Count(importer.Invalid), rep.Count(importer.Failed), rep.Ambiguous())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		return 1
+	}
+	if rep.Count(importer.Invalid)+rep.Count(importer.Failed) != 0 {
+		return 1
+	}
+	return 0
+}
@@ -1,18 +1,51 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"os/user"
+	"sync"
+	"syscall"
+	"time"
 
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	grpc_zap "github.com/grpc-ecosystem/go-grpc-middleware/logging/zap"
 	grpc_recovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
+	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/modular-project/address-service/adapter/cache"
 	gmaps "github.com/modular-project/address-service/adapter/gmap"
+	"github.com/modular-project/address-service/adapter/resilient"
+	"github.com/modular-project/address-service/audit"
+	"github.com/modular-project/address-service/config"
 	"github.com/modular-project/address-service/controller"
+	"github.com/modular-project/address-service/encryption"
+	"github.com/modular-project/address-service/exporter"
+	"github.com/modular-project/address-service/healthcheck"
 	"github.com/modular-project/address-service/http/handler"
+	"github.com/modular-project/address-service/http/interceptor"
+	"github.com/modular-project/address-service/importer"
+	"github.com/modular-project/address-service/logger"
+	"github.com/modular-project/address-service/metrics"
+	"github.com/modular-project/address-service/model"
+	"github.com/modular-project/address-service/outbox"
+	pe "github.com/modular-project/address-service/proto/addressext"
+	"github.com/modular-project/address-service/ratelimit"
 	"github.com/modular-project/address-service/storage"
+	"github.com/modular-project/address-service/tenant"
+	"github.com/modular-project/address-service/tracing"
 	pf "github.com/modular-project/protobuffers/address/address"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/health"
@@ -20,87 +53,511 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-func newDBConnection() storage.DBConnection {
-	env := "ADDR_DB_HOST"
-	host, f := os.LookupEnv(env)
-	if !f {
-		log.Fatalf("environment variable (%s) not found", env)
+func newDBConnection(c config.DB) storage.DBConnection {
+	return storage.DBConnection{
+		URI:                    c.URI,
+		User:                   c.User,
+		Host:                   c.Host,
+		Password:               c.Password,
+		Cluster:                c.Cluster,
+		NameDB:                 c.Name,
+		AuthSource:             c.AuthSource,
+		AuthMechanism:          c.AuthMechanism,
+		TLSCAFile:              c.TLSCAFile,
+		MinPoolSize:            c.MinPoolSize,
+		MaxPoolSize:            c.MaxPoolSize,
+		ConnectTimeout:         c.ConnectTimeout,
+		ServerSelectionTimeout: c.ServerSelectionTimeout,
+		ReadPreference:         c.ReadPreference,
+		WriteConcern:           c.WriteConcern,
+		ConnectRetries:         c.ConnectRetries,
+		RetryBackoff:           c.RetryBackoff,
 	}
-	env = "ADDR_DB_USER"
-	user, f := os.LookupEnv(env)
-	if !f {
-		log.Fatalf("environment variable (%s) not found", env)
-	}
-	env = "ADDR_DB_PWD"
-	pwd, f := os.LookupEnv(env)
-	if !f {
-		log.Fatalf("environment variable (%s) not found", env)
-	}
-	env = "ADDR_DB_NAME"
-	cluster, f := os.LookupEnv(env)
-	if !f {
-		log.Fatalf("environment variable (%s) not found", env)
-	}
-	return storage.DBConnection{User: user, Host: host, Password: pwd, Cluster: cluster, NameDB: "modular"}
 }
 
-func startGRPC() *grpc.Server {
+func startGRPC(l *zap.Logger, lm ratelimit.Limiter, defTenant string, adminKey []byte) *grpc.Server {
+	admin := "/" + pe.AddressAdminService_ServiceDesc.ServiceName + "/"
 	opts := []grpc_recovery.Option{
-		grpc_recovery.WithRecoveryHandler(Recovery),
+		grpc_recovery.WithRecoveryHandler(Recovery(l)),
 	}
+	// recovery first, the panics of the other interceptors are recovered too
 	server := grpc.NewServer(
 		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
 			grpc_recovery.UnaryServerInterceptor(opts...),
+			otelgrpc.UnaryServerInterceptor(),
+			grpc_ctxtags.UnaryServerInterceptor(),
+			interceptor.UnaryRequestID(),
+			interceptor.UnaryTenant(defTenant),
+			interceptor.UnaryAuditMeta(),
+			interceptor.UnaryAdmin(admin, adminKey),
+			grpc_zap.UnaryServerInterceptor(l),
+			grpc_prometheus.UnaryServerInterceptor,
+			lm.UnaryServerInterceptor(),
 		)),
 		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(
-			grpc_middleware.ChainStreamServer(),
+			grpc_recovery.StreamServerInterceptor(opts...),
+			otelgrpc.StreamServerInterceptor(),
+			grpc_ctxtags.StreamServerInterceptor(),
+			interceptor.StreamRequestID(),
+			interceptor.StreamTenant(defTenant),
+			interceptor.StreamAuditMeta(),
+			interceptor.StreamAdmin(admin, adminKey),
+			grpc_zap.StreamServerInterceptor(l),
+			grpc_prometheus.StreamServerInterceptor,
+			lm.StreamServerInterceptor(),
 		)),
 	)
 	return server
 }
 
-func Recovery(i interface{}) error {
-	return status.Errorf(codes.Unknown, "panic triggered: %v", i)
+func newTracingConfig(c config.Tracing) tracing.Config {
+	return tracing.Config{Endpoint: c.Endpoint, Insecure: c.Insecure, SampleRatio: c.SampleRatio}
+}
+
+func newResilientConfig(c config.Geocoder) resilient.Config {
+	// already validated by config.Load
+	loc, _ := time.LoadLocation(c.BudgetTimeZone)
+	return resilient.Config{
+		Timeout:         c.Timeout,
+		Retries:         c.Retries,
+		Backoff:         c.RetryBackoff,
+		BreakerFailures: c.BreakerFailures,
+		BreakerCooldown: c.BreakerCooldown,
+		DailyBudget:     c.DailyBudget,
+		Location:        loc,
+	}
+}
+
+// newProvider returns the instrumented provider behind the resilient
+// wrapper, without cache.
+func newProvider(cfg config.Config) (*resilient.GeoCoder, error) {
+	gms, err := gmaps.NewGMapService(cfg.GMaps.APIKey)
+	if err != nil {
+		return nil, fmt.Errorf("NewGMapService: %w", err)
+	}
+	return resilient.NewGeoCoder(metrics.NewGeoCoder(gmaps.Provider, gms), gmaps.Provider, newResilientConfig(cfg.Geocoder), gmaps.IsTransient), nil
+}
+
+// newGeoCoder returns the geocoder chain: the cache in front of the resilient
+// wrapper, so that only misses count against the budget. The wrapper is
+// returned too for its health.
+func newGeoCoder(cfg config.Config) (*cache.GeoCache, *resilient.GeoCoder, error) {
+	rgc, err := newProvider(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cache.NewGeoCache(rgc, cfg.Cache.Size, cfg.Cache.TTL), rgc, nil
+}
+
+// prepareTenancy gives the addresses stored before the tenants to the
+// default one and creates the indexes prefixed by the tenant.
+func prepareTenancy(ctx context.Context, c config.Tenancy, ast storage.AddressStorage, dst storage.DeliveryStorage, l *zap.Logger) error {
+	if c.Default != "" {
+		for _, s := range []struct {
+			kind string
+			st   interface {
+				AssignTenant(context.Context, string) (int64, error)
+			}
+		}{{metrics.Establishment, ast}, {metrics.Delivery, dst}} {
+			n, err := s.st.AssignTenant(ctx, c.Default)
+			if err != nil {
+				return fmt.Errorf("assign %s tenant: %w", s.kind, err)
+			}
+			if n != 0 {
+				l.Info("assigned the default tenant", zap.String("kind", s.kind), zap.Int64("addresses", n))
+			}
+		}
+	}
+	if err := ast.EnsureTenantIndexes(ctx); err != nil {
+		return fmt.Errorf("establishment indexes: %w", err)
+	}
+	if err := dst.EnsureTenantIndexes(ctx); err != nil {
+		return fmt.Errorf("delivery indexes: %w", err)
+	}
+	return nil
+}
+
+// cliTenant binds the context of a command to the tenant t, or to the
+// default one.
+func cliTenant(ctx context.Context, t string, c config.Tenancy) (context.Context, error) {
+	if t == "" {
+		t = c.Default
+	}
+	if !tenant.Valid(t) {
+		return nil, fmt.Errorf("invalid tenant %q, set -tenant", t)
+	}
+	return tenant.NewContext(ctx, t), nil
+}
+
+// cliActor audits the changes of a command under the user running it.
+func cliActor(command string) audit.Meta {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	return audit.Meta{Actor: "cli:" + name, RPC: command}
+}
+
+// newOutbox returns the Mongo outbox when the events are enabled.
+func newOutbox(ctx context.Context, c config.Events, db *mongo.Database) (controller.Outbox, error) {
+	if !c.Enabled {
+		return controller.NoOutbox, nil
+	}
+	ob := storage.NewOutbox(db)
+	if err := ob.EnsureIndexes(ctx, c.Retention); err != nil {
+		return nil, fmt.Errorf("outbox indexes: %w", err)
+	}
+	return ob, nil
+}
+
+// newStrategy returns the assignment strategy of Nearest.
+func newStrategy(c config.Nearest) controller.Strategy {
+	if c.Strategy == controller.StrategyWeighted {
+		return controller.WeightedStrategy{LoadWeight: c.LoadWeight, PriorityWeight: c.PriorityWeight, LoadTTL: c.LoadTTL}
+	}
+	return controller.NearestStrategy{}
+}
+
+// newAuditor returns aus, best effort without events as the changes are
+// not transactional then.
+func newAuditor(c config.Events, aus storage.AuditStorage, l *zap.Logger) controller.Auditor {
+	if c.Enabled {
+		return aus
+	}
+	return controller.BestEffort(aus, l)
+}
+
+// newDeliveryStorage returns the delivery storage, encrypting the personal
+// data when a key file is configured.
+func newDeliveryStorage(cfg config.Config, db *mongo.Database) (storage.DeliveryStorage, error) {
+	var opts []storage.DeliveryOption
+	if cfg.Encryption.KeyFile != "" {
+		kp, err := encryption.NewFileKeyProvider(cfg.Encryption.KeyFile)
+		if err != nil {
+			return storage.DeliveryStorage{}, fmt.Errorf("encryption keys: %w", err)
+		}
+		opts = append(opts, storage.WithEncryption(encryption.New(kp)))
+	}
+	return storage.NewDeliveryStorage(db, cfg.DB.DeliveryCollection, opts...), nil
+}
+
+// newPurgeWorker returns the worker purging the expired deleted delivery
+// addresses.
+func newPurgeWorker(ctx context.Context, c config.Retention, db *mongo.Database, dst storage.DeliveryStorage, bfs storage.BackfillStorage, aus storage.AuditStorage, l *zap.Logger) (*controller.PurgeWorker, error) {
+	if err := dst.EnsureRetentionIndex(ctx); err != nil {
+		return nil, fmt.Errorf("retention index: %w", err)
+	}
+	refs := controller.NoReferences
+	if c.ReferenceCollection != "" {
+		refs = storage.NewReferenceStorage(db, c.ReferenceCollection, c.ReferenceField)
+	}
+	return controller.NewPurgeWorker(dst, refs, bfs, aus, l, controller.PurgeConfig{
+		Retention: c.Deleted,
+		Interval:  c.Interval,
+		BatchSize: c.BatchSize,
+	}), nil
+}
+
+// gracefulStop waits for in-flight RPCs to finish for up to timeout, then
+// closes the remaining connections.
+func gracefulStop(srv *grpc.Server, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-done:
+		return true
+	case <-t.C:
+		srv.Stop()
+		return false
+	}
+}
+
+// stoppingWatcher ends the watches once stop is done, GracefulStop waits
+// for them otherwise. The subscribers resume them on another replica. The
+// watches fail with unavailable when set, rather than miss the deletes.
+type stoppingWatcher struct {
+	storage.AddressStorage
+	stop        context.Context
+	unavailable error
+}
+
+func (sw stoppingWatcher) Watch(ctx context.Context, token []byte, fn func(model.EstablishmentChange) error) error {
+	if sw.unavailable != nil {
+		return sw.unavailable
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-sw.stop.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return sw.AddressStorage.Watch(ctx, token, fn)
+}
+
+// Recovery logs a panic with l, it runs outside of the logging
+// interceptor.
+func Recovery(l *zap.Logger) grpc_recovery.RecoveryHandlerFunc {
+	return func(i interface{}) error {
+		l.Error("panic recovered", zap.Any("panic", i), zap.Stack("stack"))
+		return status.Errorf(codes.Unknown, "panic triggered: %v", i)
+	}
 }
 
 func main() {
-	conn := newDBConnection()
-	db, err := storage.NewDB(&conn)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			os.Exit(runImport(os.Args[2:]))
+		case "export":
+			os.Exit(runExport(os.Args[2:]))
+		case "backfill":
+			os.Exit(runBackfill(os.Args[2:]))
+		case "reencrypt":
+			os.Exit(runReencrypt(os.Args[2:]))
+		}
+	}
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	printConfig := fs.Bool("print-config", false, "print the configuration with secrets redacted and exit")
+	cfg, err := config.Load(fs, os.Args[1:])
+	if *printConfig {
+		if perr := config.Print(os.Stdout, cfg); perr != nil {
+			log.Fatalf("print config: %s", perr)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	uh, err := logger.NewUserHasher([]byte(cfg.Privacy.UserHashKey))
 	if err != nil {
-		log.Fatalf("NewDB: %s", err)
+		log.Fatalf("logger.NewUserHasher: %s", err)
 	}
-	coll, _ := os.LookupEnv("ADDR_COLLECTION")
-	ast := storage.NewAddressStorage(db, 25000, coll)
-	coll, _ = os.LookupEnv("DEL_COLLECTION")
-	dst := storage.NewDeliveryStorage(db, coll)
-	key, ok := os.LookupEnv("GMAP_APIKEY")
-	if !ok {
-		log.Fatal("enviroment variable GMAP_APIKEY not found")
+	l, err := logger.New(cfg.LogLevel, uh)
+	if err != nil {
+		log.Fatalf("logger.New: %s", err)
 	}
-	gms, err := gmaps.NewGMapService(key)
+	defer l.Sync()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	shutdownTracing, err := tracing.New(ctx, newTracingConfig(cfg.Tracing))
 	if err != nil {
-		log.Fatalf("NewGMapService: %s", err)
+		l.Fatal("tracing.New", zap.Error(err))
 	}
-	env := "ADDR_PORT"
-	port, f := os.LookupEnv(env)
-	if !f {
-		log.Fatalf("environment variable (%s) not found", env)
+	conn := newDBConnection(cfg.DB)
+	conn.Monitors = []*event.CommandMonitor{metrics.NewCommandMonitor(), otelmongo.NewMonitor()}
+	mgr, err := storage.Connect(ctx, &conn)
+	if err != nil {
+		l.Fatal("storage.Connect", zap.Error(err))
 	}
+	db := mgr.Database("")
+	ast := storage.NewAddressStorage(db, cfg.Nearest.MaxDistance, cfg.DB.EstablishmentCollection)
+	dst, err := newDeliveryStorage(cfg, db)
+	if err != nil {
+		l.Fatal("newDeliveryStorage", zap.Error(err))
+	}
+	gc, rgc, err := newGeoCoder(cfg)
+	if err != nil {
+		l.Fatal("newGeoCoder", zap.Error(err))
+	}
+	port := cfg.Port
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		l.Fatal("failed to listen", zap.String("port", port), zap.Error(err))
+	}
+	ob, err := newOutbox(ctx, cfg.Events, db)
+	if err != nil {
+		l.Fatal("newOutbox", zap.Error(err))
 	}
-	ads := controller.NewAddressService(ast, dst, gms)
+	aus := storage.NewAuditStorage(db)
+	if err := aus.EnsureIndexes(ctx); err != nil {
+		l.Fatal("audit indexes", zap.Error(err))
+	}
+	bfs := storage.NewBackfillStorage(db)
+	if err := bfs.EnsureIndexes(ctx); err != nil {
+		l.Fatal("backfill change indexes", zap.Error(err))
+	}
+	zs := storage.NewZoneStorage(db)
+	if err := zs.EnsureIndexes(ctx); err != nil {
+		l.Fatal("excluded zone indexes", zap.Error(err))
+	}
+	ads := controller.NewAddressService(ast, dst, gc,
+		controller.WithMaxDeliveries(cfg.Limits.MaxDeliveries),
+		controller.WithMaxBatch(cfg.Limits.MaxBatch),
+		controller.WithDuplicates(cfg.Duplicates.Policy, cfg.Duplicates.Distance),
+		controller.WithAsyncGeocoding(cfg.Geocoder.Async),
+		controller.WithOutbox(ob),
+		controller.WithAuditor(newAuditor(cfg.Events, aus, l)),
+		controller.WithExcludedZones(zs),
+		controller.WithAssignment(newStrategy(cfg.Nearest), cfg.Nearest.Candidates),
+	)
+	for _, ps := range []interface {
+		EnsurePendingIndex(context.Context) error
+	}{ast, dst} {
+		if err := ps.EnsurePendingIndex(ctx); err != nil {
+			l.Fatal("pending geocode index", zap.Error(err))
+		}
+	}
+	// the worker also runs without async geocoding, for the addresses left
+	// pending before it was disabled
+	gw := controller.NewGeocodeWorker(gc, ob, l, controller.WorkerConfig{
+		Workers:      cfg.Geocoder.Workers,
+		PollInterval: cfg.Geocoder.PollInterval,
+		MaxAttempts:  cfg.Geocoder.MaxAttempts,
+		// long enough for every retry of the resilient geocoder
+		Lease: cfg.Geocoder.Timeout*time.Duration(cfg.Geocoder.Retries+1) + time.Minute,
+	}, ast, dst)
 	auc := handler.NewAddressUC(ads)
-	srv := startGRPC()
+	if err := prepareTenancy(ctx, cfg.Tenancy, ast, dst, l); err != nil {
+		l.Fatal("tenancy", zap.Error(err))
+	}
+	// the watches need them to send the deletes, MongoDB 6.0 is optional
+	var unwatchable error
+	if err := ast.EnablePreImages(ctx); err != nil {
+		l.Error("watches are unavailable, they would miss the deletes", zap.Error(err))
+		unwatchable = fmt.Errorf("%w: %s", controller.ErrWatchUnavailable, err)
+	}
+	euc := handler.NewAddressExtUC(importer.New(gc, ast, ob, aus, cfg.Geocoder.Workers), exporter.New(ast, dst, uh), stoppingWatcher{ast, ctx, unwatchable}, ads, ads)
+	var rls ratelimit.Store
+	mrs := ratelimit.NewMemoryStore()
+	rls = mrs
+	if cfg.Limits.Store == "mongo" {
+		ms := ratelimit.NewMongoStore(db, cfg.Limits.Collection)
+		if err := ms.EnsureIndexes(ctx); err != nil {
+			l.Fatal("rate limit indexes", zap.Error(err))
+		}
+		rls = ms
+	}
+	lm := ratelimit.NewLimiter(rls,
+		ratelimit.Bucket{Rate: cfg.Limits.UserRate, Burst: cfg.Limits.UserBurst},
+		ratelimit.Bucket{Rate: cfg.Limits.CallerRate, Burst: cfg.Limits.CallerBurst},
+		"/"+pf.AddressService_ServiceDesc.ServiceName+"/CreateDelivery",
+		"/"+pf.AddressService_ServiceDesc.ServiceName+"/CreateEstablishment",
+		// anonymous, only the caller bucket applies
+		"/"+pe.AddressExtService_ServiceDesc.ServiceName+"/CheckDeliverability",
+	)
+	if cfg.Admin.TokenKey == "" {
+		l.Warn("admin token key not set, every admin call is rejected")
+	}
+	srv := startGRPC(l, lm, cfg.Tenancy.Default, []byte(cfg.Admin.TokenKey))
 	pf.RegisterAddressServiceServer(srv, auc)
+	pe.RegisterAddressExtServiceServer(srv, euc)
+	pe.RegisterAddressAdminServiceServer(srv, handler.NewAdminUC(aus, ads, controller.NewPrivacyService(dst, ob, aus, aus, bfs)))
+	grpc_prometheus.EnableHandlingTimeHistogram()
+	grpc_prometheus.Register(srv)
+	mport := cfg.Metrics.Port
+	ms := metrics.NewServer(fmt.Sprintf(":%s", mport))
+	go func() {
+		if err := ms.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			l.Error("metrics server", zap.String("port", mport), zap.Error(err))
+		}
+	}()
 	healthServer := health.NewServer()
-	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
-	healthServer.SetServingStatus(pf.AddressService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
-	log.Printf("Server started at :%s", port)
+	prober := healthcheck.NewProber(healthServer, l,
+		[]string{
+			pf.AddressService_ServiceDesc.ServiceName,
+			pe.AddressExtService_ServiceDesc.ServiceName,
+			pe.AddressAdminService_ServiceDesc.ServiceName,
+		},
+		healthcheck.Mongo(mgr.Client()),
+		healthcheck.Indexes(ast),
+		healthcheck.GeoCoder(rgc),
+	)
+	workers, stopWorkers := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		prober.Run(workers)
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		gw.Run(workers)
+	}()
+	if cfg.Events.Enabled {
+		host, _ := os.Hostname()
+		relay := outbox.NewRelay(storage.NewOutbox(db), outbox.NewLogPublisher(l), l, outbox.Config{
+			Owner:        fmt.Sprintf("%s-%d", host, os.Getpid()),
+			PollInterval: cfg.Events.PollInterval,
+			BatchSize:    cfg.Events.BatchSize,
+			LeaseTTL:     cfg.Events.LeaseTTL,
+			MaxAttempts:  cfg.Events.MaxAttempts,
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			relay.Run(workers)
+		}()
+	}
+	if cfg.Retention.Deleted > 0 {
+		pw, err := newPurgeWorker(ctx, cfg.Retention, db, dst, bfs, aus, l)
+		if err != nil {
+			l.Fatal("newPurgeWorker", zap.Error(err))
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pw.Run(workers)
+		}()
+	}
+	if cfg.Limits.Store == "memory" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mrs.Run(workers, 10*time.Minute)
+		}()
+	}
 	healthpb.RegisterHealthServer(srv, healthServer)
-	err = srv.Serve(lis)
-	if err != nil {
-		log.Fatalf("failed to server at :%s, got error: %s", port, err)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(lis)
+	}()
+	l.Info("server started", zap.String("port", port))
+	// a serve failure still shuts down gracefully, then exits non-zero
+	var failed error
+	select {
+	case failed = <-serveErr:
+		l.Error("failed to serve", zap.String("port", port), zap.Error(failed))
+	case <-ctx.Done():
+		l.Info("shutting down")
+	}
+	stop()
+
+	// stop receiving traffic before draining, Kubernetes needs a few probes
+	// to take the pod out of the service endpoints
+	prober.Shutdown()
+	time.Sleep(cfg.Shutdown.DrainDelay)
+	if !gracefulStop(srv, cfg.Shutdown.Timeout) {
+		l.Warn("in-flight RPCs cancelled after shutdown timeout")
+	}
+	stopWorkers()
+	wg.Wait()
+
+	sctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := ms.Shutdown(sctx); err != nil {
+		l.Error("metrics server shutdown", zap.Error(err))
+	}
+	if err := shutdownTracing(sctx); err != nil {
+		l.Error("tracing shutdown", zap.Error(err))
+	}
+	if err := mgr.Close(sctx); err != nil {
+		l.Error("mongo close", zap.Error(err))
+	}
+	if failed != nil {
+		cancel()
+		l.Fatal("server stopped after failing to serve", zap.Error(failed))
 	}
+	l.Info("server stopped")
 }
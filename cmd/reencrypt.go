@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/modular-project/address-service/config"
+	"github.com/modular-project/address-service/storage"
+)
+
+const reencryptUsage = `usage: address-service reencrypt [flags]
+
+Encrypts with the current key of encryption.key_file the delivery addresses
+of every tenant stored with another key or in plaintext. Run it after making
+a new key current, the old key can be removed once it finishes.
+`
+
+// runReencrypt is the reencrypt command, it returns the exit status.
+func runReencrypt(args []string) int {
+	fs := flag.NewFlagSet("reencrypt", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), reencryptUsage)
+		fs.PrintDefaults()
+	}
+	batch := fs.Int64("batch", 500, "addresses updated per query")
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if cfg.Encryption.KeyFile == "" || *batch < 1 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	conn := newDBConnection(cfg.DB)
+	mgr, err := storage.Connect(ctx, &conn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer mgr.Close(context.Background())
+	dst, err := newDeliveryStorage(cfg, mgr.Database(""))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	total := 0
+	for {
+		n, err := dst.Reencrypt(ctx, *batch)
+		total += n
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "reencrypted %d: %s\n", total, err)
+			return 1
+		}
+		if n == 0 {
+			break
+		}
+	}
+	fmt.Fprintf(os.Stderr, "reencrypted %d\n", total)
+	return 0
+}
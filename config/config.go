@@ -0,0 +1,454 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/modular-project/address-service/tenant"
+)
+
+// Config is the configuration of the address service. Values are taken, by
+// increasing precedence, from the defaults, the config file, the environment
+// and the command line flags.
+type Config struct {
+	Port       string     `yaml:"port" toml:"port" env:"ADDR_PORT" flag:"port" usage:"gRPC listen port"`
+	LogLevel   string     `yaml:"log_level" toml:"log_level" env:"LOG_LEVEL" flag:"log-level" usage:"debug, info, warn or error"`
+	DB         DB         `yaml:"db" toml:"db"`
+	GMaps      GMaps      `yaml:"gmaps" toml:"gmaps"`
+	Geocoder   Geocoder   `yaml:"geocoder" toml:"geocoder"`
+	Nearest    Nearest    `yaml:"nearest" toml:"nearest"`
+	Limits     Limits     `yaml:"limits" toml:"limits"`
+	Cache      Cache      `yaml:"cache" toml:"cache"`
+	Metrics    Metrics    `yaml:"metrics" toml:"metrics"`
+	Tracing    Tracing    `yaml:"tracing" toml:"tracing"`
+	Shutdown   Shutdown   `yaml:"shutdown" toml:"shutdown"`
+	Events     Events     `yaml:"events" toml:"events"`
+	Tenancy    Tenancy    `yaml:"tenancy" toml:"tenancy"`
+	Encryption Encryption `yaml:"encryption" toml:"encryption"`
+	Retention  Retention  `yaml:"retention" toml:"retention"`
+	Duplicates Duplicates `yaml:"duplicates" toml:"duplicates"`
+	Admin      Admin      `yaml:"admin" toml:"admin"`
+	Privacy    Privacy    `yaml:"privacy" toml:"privacy"`
+}
+
+type DB struct {
+	URI                     string        `yaml:"uri" toml:"uri" env:"ADDR_DB_URI" secret:"true" usage:"full connection string, replaces host, cluster, user and password"`
+	Host                    string        `yaml:"host" toml:"host" env:"ADDR_DB_HOST" flag:"db-host" usage:"URI scheme, mongodb or mongodb+srv"`
+	User                    string        `yaml:"user" toml:"user" env:"ADDR_DB_USER" secret:"true"`
+	Password                string        `yaml:"password" toml:"password" env:"ADDR_DB_PWD" secret:"true"`
+	Cluster                 string        `yaml:"cluster" toml:"cluster" env:"ADDR_DB_NAME" flag:"db-cluster" usage:"cluster address"`
+	Name                    string        `yaml:"name" toml:"name" env:"ADDR_DB_DATABASE" flag:"db-name" usage:"database name"`
+	EstablishmentCollection string        `yaml:"establishment_collection" toml:"establishment_collection" env:"ADDR_COLLECTION" flag:"establishment-collection"`
+	DeliveryCollection      string        `yaml:"delivery_collection" toml:"delivery_collection" env:"DEL_COLLECTION" flag:"delivery-collection"`
+	AuthSource              string        `yaml:"auth_source" toml:"auth_source" env:"ADDR_DB_AUTH_SOURCE" flag:"db-auth-source"`
+	AuthMechanism           string        `yaml:"auth_mechanism" toml:"auth_mechanism" env:"ADDR_DB_AUTH_MECHANISM" flag:"db-auth-mechanism" usage:"SCRAM-SHA-256, MONGODB-X509, ..."`
+	TLSCAFile               string        `yaml:"tls_ca_file" toml:"tls_ca_file" env:"ADDR_DB_TLS_CA_FILE" flag:"db-tls-ca-file"`
+	MinPoolSize             uint64        `yaml:"min_pool_size" toml:"min_pool_size" env:"ADDR_DB_MIN_POOL_SIZE" flag:"db-min-pool-size"`
+	MaxPoolSize             uint64        `yaml:"max_pool_size" toml:"max_pool_size" env:"ADDR_DB_MAX_POOL_SIZE" flag:"db-max-pool-size"`
+	ConnectTimeout          time.Duration `yaml:"connect_timeout" toml:"connect_timeout" env:"ADDR_DB_CONNECT_TIMEOUT" flag:"db-connect-timeout"`
+	ServerSelectionTimeout  time.Duration `yaml:"server_selection_timeout" toml:"server_selection_timeout" env:"ADDR_DB_SERVER_SELECTION_TIMEOUT" flag:"db-server-selection-timeout"`
+	ReadPreference          string        `yaml:"read_preference" toml:"read_preference" env:"ADDR_DB_READ_PREFERENCE" flag:"db-read-preference"`
+	WriteConcern            string        `yaml:"write_concern" toml:"write_concern" env:"ADDR_DB_WRITE_CONCERN" flag:"db-write-concern" usage:"majority or a number"`
+	ConnectRetries          int           `yaml:"connect_retries" toml:"connect_retries" env:"ADDR_DB_CONNECT_RETRIES" flag:"db-connect-retries"`
+	RetryBackoff            time.Duration `yaml:"retry_backoff" toml:"retry_backoff" env:"ADDR_DB_RETRY_BACKOFF" flag:"db-retry-backoff"`
+}
+
+type GMaps struct {
+	APIKey string `yaml:"api_key" toml:"api_key" env:"GMAP_APIKEY" secret:"true"`
+}
+
+// Nearest chooses the establishment of a delivery address among the
+// Candidates closest ones with Strategy, the weighted one adds LoadWeight
+// meters to the distance for each capacity full of orders and subtracts
+// PriorityWeight meters for each priority point.
+type Nearest struct {
+	// MaxDistance in meters between a delivery address and its establishment
+	MaxDistance    int     `yaml:"max_distance" toml:"max_distance" env:"NEAREST_MAX_DISTANCE" flag:"nearest-max-distance" usage:"meters"`
+	Strategy       string  `yaml:"strategy" toml:"strategy" env:"NEAREST_STRATEGY" flag:"nearest-strategy" usage:"nearest or weighted"`
+	Candidates     int     `yaml:"candidates" toml:"candidates" env:"NEAREST_CANDIDATES" flag:"nearest-candidates"`
+	LoadWeight     float64 `yaml:"load_weight" toml:"load_weight" env:"NEAREST_LOAD_WEIGHT" flag:"nearest-load-weight" usage:"meters"`
+	PriorityWeight float64 `yaml:"priority_weight" toml:"priority_weight" env:"NEAREST_PRIORITY_WEIGHT" flag:"nearest-priority-weight" usage:"meters"`
+	// LoadTTL ignores the loads reported before
+	LoadTTL time.Duration `yaml:"load_ttl" toml:"load_ttl" env:"NEAREST_LOAD_TTL" flag:"nearest-load-ttl"`
+}
+
+type Limits struct {
+	// Store of the rate limit buckets, memory or mongo
+	Store         string  `yaml:"store" toml:"store" env:"RATE_LIMIT_STORE" flag:"rate-limit-store" usage:"memory or mongo"`
+	Collection    string  `yaml:"collection" toml:"collection" env:"RATE_LIMIT_COLLECTION" flag:"rate-limit-collection"`
+	UserRate      float64 `yaml:"user_rate" toml:"user_rate" env:"RATE_LIMIT_USER_RATE" flag:"rate-limit-user-rate" usage:"address creations per second per user, 0 disables"`
+	UserBurst     int     `yaml:"user_burst" toml:"user_burst" env:"RATE_LIMIT_USER_BURST" flag:"rate-limit-user-burst"`
+	CallerRate    float64 `yaml:"caller_rate" toml:"caller_rate" env:"RATE_LIMIT_CALLER_RATE" flag:"rate-limit-caller-rate" usage:"address creations per second per caller, by client certificate or IP, 0 disables"`
+	CallerBurst   int     `yaml:"caller_burst" toml:"caller_burst" env:"RATE_LIMIT_CALLER_BURST" flag:"rate-limit-caller-burst"`
+	MaxDeliveries int64   `yaml:"max_deliveries" toml:"max_deliveries" env:"MAX_DELIVERIES_PER_USER" flag:"max-deliveries-per-user" usage:"active delivery addresses per user, 0 is unlimited"`
+	MaxBatch      int     `yaml:"max_batch" toml:"max_batch" env:"MAX_BATCH_IDS" flag:"max-batch-ids" usage:"ids per batch lookup, 0 is unlimited"`
+}
+
+// Geocoder protects the geocoding provider, once the circuit is open or the
+// daily budget is spent only cached addresses can be geocoded.
+type Geocoder struct {
+	Timeout         time.Duration `yaml:"timeout" toml:"timeout" env:"GEOCODER_TIMEOUT" flag:"geocoder-timeout" usage:"timeout of each provider request"`
+	Retries         int           `yaml:"retries" toml:"retries" env:"GEOCODER_RETRIES" flag:"geocoder-retries" usage:"retries of transient provider errors"`
+	RetryBackoff    time.Duration `yaml:"retry_backoff" toml:"retry_backoff" env:"GEOCODER_RETRY_BACKOFF" flag:"geocoder-retry-backoff"`
+	BreakerFailures int           `yaml:"breaker_failures" toml:"breaker_failures" env:"GEOCODER_BREAKER_FAILURES" flag:"geocoder-breaker-failures" usage:"consecutive failures opening the circuit, 0 disables"`
+	BreakerCooldown time.Duration `yaml:"breaker_cooldown" toml:"breaker_cooldown" env:"GEOCODER_BREAKER_COOLDOWN" flag:"geocoder-breaker-cooldown"`
+	DailyBudget     int           `yaml:"daily_budget" toml:"daily_budget" env:"GEOCODER_DAILY_BUDGET" flag:"geocoder-daily-budget" usage:"provider requests per day, 0 is unlimited"`
+	BudgetTimeZone  string        `yaml:"budget_time_zone" toml:"budget_time_zone" env:"GEOCODER_BUDGET_TZ" flag:"geocoder-budget-tz" usage:"time zone where the daily budget resets"`
+	// Async stores new addresses as pending, the workers geocode them
+	Async        bool          `yaml:"async" toml:"async" env:"GEOCODE_ASYNC" flag:"geocode-async" usage:"create addresses before geocoding them"`
+	Workers      int           `yaml:"workers" toml:"workers" env:"GEOCODE_WORKERS" flag:"geocode-workers" usage:"concurrent geocoding of pending addresses"`
+	PollInterval time.Duration `yaml:"poll_interval" toml:"poll_interval" env:"GEOCODE_POLL_INTERVAL" flag:"geocode-poll-interval"`
+	MaxAttempts  int           `yaml:"max_attempts" toml:"max_attempts" env:"GEOCODE_MAX_ATTEMPTS" flag:"geocode-max-attempts" usage:"attempts before a pending address is marked as failed"`
+}
+
+type Cache struct {
+	Size int           `yaml:"size" toml:"size" env:"GEOCODE_CACHE_SIZE" flag:"geocode-cache-size"`
+	TTL  time.Duration `yaml:"ttl" toml:"ttl" env:"GEOCODE_CACHE_TTL" flag:"geocode-cache-ttl"`
+}
+
+type Metrics struct {
+	Port string `yaml:"port" toml:"port" env:"METRICS_PORT" flag:"metrics-port"`
+}
+
+type Tracing struct {
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" flag:"otlp-endpoint" usage:"OTLP gRPC collector, tracing is disabled when empty"`
+	Insecure    bool    `yaml:"insecure" toml:"insecure" env:"OTEL_EXPORTER_OTLP_INSECURE" flag:"otlp-insecure"`
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio" env:"OTEL_TRACES_SAMPLER_ARG" flag:"trace-sample-ratio"`
+}
+
+// Events are written to an outbox with each change, which needs Mongo
+// transactions, and relayed to Publisher.
+type Events struct {
+	Enabled      bool          `yaml:"enabled" toml:"enabled" env:"EVENTS_ENABLED" flag:"events" usage:"publish the domain events, needs a replica set"`
+	Publisher    string        `yaml:"publisher" toml:"publisher" env:"EVENTS_PUBLISHER" flag:"events-publisher" usage:"log"`
+	PollInterval time.Duration `yaml:"poll_interval" toml:"poll_interval" env:"EVENTS_POLL_INTERVAL" flag:"events-poll-interval"`
+	BatchSize    int           `yaml:"batch_size" toml:"batch_size" env:"EVENTS_BATCH_SIZE" flag:"events-batch-size"`
+	Retention    time.Duration `yaml:"retention" toml:"retention" env:"EVENTS_RETENTION" flag:"events-retention" usage:"time the published events are kept"`
+	LeaseTTL     time.Duration `yaml:"lease_ttl" toml:"lease_ttl" env:"EVENTS_LEASE_TTL" flag:"events-lease-ttl" usage:"lease of the relaying replica, each publication must take less"`
+	MaxAttempts  int           `yaml:"max_attempts" toml:"max_attempts" env:"EVENTS_MAX_ATTEMPTS" flag:"events-max-attempts" usage:"failed publications before an event is dead-lettered, 0 retries forever"`
+}
+
+// Tenancy isolates the brands sharing the service, each request belongs to
+// the tenant of its x-tenant-id metadata.
+type Tenancy struct {
+	// Default is the tenant of the requests without x-tenant-id and of the
+	// addresses stored before the tenants, empty makes the header mandatory
+	Default string `yaml:"default" toml:"default" env:"DEFAULT_TENANT" flag:"default-tenant"`
+}
+
+// Encryption of the personal data of the delivery addresses.
+type Encryption struct {
+	// KeyFile holds the keys, the addresses are stored in plaintext when
+	// empty. Rotating adds a key, makes it current and runs reencrypt.
+	KeyFile string `yaml:"key_file" toml:"key_file" env:"ENCRYPTION_KEY_FILE" flag:"encryption-key-file" usage:"JSON file of the encryption keys, disabled when empty"`
+}
+
+// Retention of the deleted delivery addresses, a background job purges
+// them once expired.
+type Retention struct {
+	Deleted   time.Duration `yaml:"deleted" toml:"deleted" env:"RETENTION_DELETED" flag:"retention-deleted" usage:"time the deleted delivery addresses are kept, 0 keeps them forever"`
+	Interval  time.Duration `yaml:"interval" toml:"interval" env:"RETENTION_INTERVAL" flag:"retention-interval"`
+	BatchSize int           `yaml:"batch_size" toml:"batch_size" env:"RETENTION_BATCH_SIZE" flag:"retention-batch-size"`
+	// ReferenceCollection of another service in the same database, e.g. the
+	// orders, whose ReferenceField holds the id of a delivery address. The
+	// referenced addresses are anonymized instead of purged.
+	ReferenceCollection string `yaml:"reference_collection" toml:"reference_collection" env:"RETENTION_REFERENCE_COLLECTION" flag:"retention-reference-collection"`
+	ReferenceField      string `yaml:"reference_field" toml:"reference_field" env:"RETENTION_REFERENCE_FIELD" flag:"retention-reference-field"`
+}
+
+// Duplicates decides whether CreateDelivery stores again an address the user
+// already has, the calls can choose with the x-duplicate-policy header.
+type Duplicates struct {
+	Policy string `yaml:"policy" toml:"policy" env:"DUPLICATE_POLICY" flag:"duplicate-policy" usage:"allow, reuse or reject"`
+	// Distance in meters within which two geocoded addresses are the same,
+	// 0 only compares their normalized components
+	Distance float64 `yaml:"distance" toml:"distance" env:"DUPLICATE_DISTANCE" flag:"duplicate-distance"`
+}
+
+// Admin restricts the AddressAdminService to the administrators
+// authenticated by the gateway, which signs their x-admin-token with
+// TokenKey.
+type Admin struct {
+	TokenKey string `yaml:"token_key" toml:"token_key" env:"ADMIN_TOKEN_KEY" secret:"true" usage:"HMAC key of the admin tokens, every admin call is rejected when empty"`
+}
+
+// Privacy of the users in the logs and the exports without PII.
+type Privacy struct {
+	// UserHashKey keys the pseudonyms of the user IDs, rotating it changes
+	// them. A random key is used when empty, the pseudonyms of two
+	// replicas or two exports then differ.
+	UserHashKey string `yaml:"user_hash_key" toml:"user_hash_key" env:"USER_HASH_KEY" secret:"true"`
+}
+
+type Shutdown struct {
+	DrainDelay time.Duration `yaml:"drain_delay" toml:"drain_delay" env:"SHUTDOWN_DRAIN_DELAY" flag:"shutdown-drain-delay"`
+	Timeout    time.Duration `yaml:"timeout" toml:"timeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout"`
+}
+
+// Default returns the configuration used for every value not set elsewhere.
+func Default() Config {
+	return Config{
+		LogLevel: "info",
+		DB: DB{
+			Host:           "mongodb+srv",
+			Name:           "modular",
+			ConnectRetries: 5,
+			RetryBackoff:   time.Second,
+		},
+		Nearest: Nearest{
+			MaxDistance:    25000,
+			Strategy:       "nearest",
+			Candidates:     10,
+			LoadWeight:     2000,
+			PriorityWeight: 500,
+			LoadTTL:        10 * time.Minute,
+		},
+		Geocoder: Geocoder{
+			Timeout:         5 * time.Second,
+			Retries:         2,
+			RetryBackoff:    200 * time.Millisecond,
+			BreakerFailures: 5,
+			BreakerCooldown: 30 * time.Second,
+			BudgetTimeZone:  "America/Mexico_City",
+			Workers:         4,
+			PollInterval:    5 * time.Second,
+			MaxAttempts:     10,
+		},
+		Limits: Limits{
+			Store:         "memory",
+			UserRate:      0.1,
+			UserBurst:     5,
+			CallerRate:    50,
+			CallerBurst:   100,
+			MaxDeliveries: 20,
+			MaxBatch:      100,
+		},
+		Cache:    Cache{Size: 10000, TTL: 24 * time.Hour},
+		Metrics:  Metrics{Port: "9090"},
+		Tracing:  Tracing{SampleRatio: 1},
+		Shutdown: Shutdown{DrainDelay: 5 * time.Second, Timeout: 20 * time.Second},
+		Events: Events{
+			Publisher:    "log",
+			PollInterval: time.Second,
+			BatchSize:    100,
+			Retention:    7 * 24 * time.Hour,
+			LeaseTTL:     30 * time.Second,
+			MaxAttempts:  20,
+		},
+		// the brand served before the tenants
+		Tenancy:    Tenancy{Default: "punto-y-coma"},
+		Retention:  Retention{Interval: time.Hour, BatchSize: 500},
+		Duplicates: Duplicates{Policy: "allow", Distance: 15},
+	}
+}
+
+// Load builds the configuration from the defaults, the file given by
+// --config or ADDR_CONFIG_FILE, the environment and the flags in args. One
+// flag is registered on fs for each configurable value. Every stage runs
+// and the problems of all of them are returned in one ValidationError, but
+// for --help. The returned Config is filled even when it is invalid so it
+// can still be printed.
+func Load(fs *flag.FlagSet, args []string) (Config, error) {
+	c := Default()
+	path := fs.String("config", os.Getenv("ADDR_CONFIG_FILE"), "YAML or TOML configuration file")
+	fl := registerFlags(fs, &c)
+	var p []string
+	add := func(err error) {
+		var ve ValidationError
+		if errors.As(err, &ve) {
+			p = append(p, ve.Problems...)
+		} else if err != nil {
+			p = append(p, err.Error())
+		}
+	}
+	if err := fs.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return c, err
+	} else if err != nil {
+		add(fmt.Errorf("parse flags: %w", err))
+	}
+	if *path != "" {
+		add(LoadFile(&c, *path))
+	}
+	add(LoadEnv(&c))
+	add(fl.apply(fs))
+	add(c.Validate())
+	if len(p) != 0 {
+		return c, ValidationError{Problems: p}
+	}
+	return c, nil
+}
+
+// ValidationError lists every invalid value found.
+type ValidationError struct {
+	Problems []string
+}
+
+func (ve ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(ve.Problems, "; "))
+}
+
+// Validate reports all the invalid values of c at once.
+func (c Config) Validate() error {
+	var p []string
+	if c.Port == "" {
+		p = append(p, "port is required (ADDR_PORT)")
+	}
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		p = append(p, fmt.Sprintf("log_level %q must be debug, info, warn or error", c.LogLevel))
+	}
+	p = append(p, c.DB.problems()...)
+	if c.GMaps.APIKey == "" {
+		p = append(p, "gmaps.api_key is required (GMAP_APIKEY or GMAP_APIKEY_FILE)")
+	}
+	p = append(p, c.Geocoder.problems()...)
+	p = append(p, c.Nearest.problems()...)
+	p = append(p, c.Limits.problems()...)
+	if c.Cache.Size <= 0 {
+		p = append(p, "cache.size must be positive")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		p = append(p, "tracing.sample_ratio must be between 0 and 1")
+	}
+	if c.Shutdown.Timeout <= 0 {
+		p = append(p, "shutdown.timeout must be positive")
+	}
+	if c.Events.Enabled {
+		if c.Events.Publisher != "log" {
+			p = append(p, fmt.Sprintf("events.publisher %q must be log", c.Events.Publisher))
+		}
+		if c.Events.PollInterval <= 0 || c.Events.BatchSize < 1 || c.Events.Retention <= 0 {
+			p = append(p, "events poll_interval, batch_size and retention must be positive")
+		}
+		if c.Events.LeaseTTL <= 0 || c.Events.MaxAttempts < 0 {
+			p = append(p, "events lease_ttl must be positive and max_attempts not negative")
+		}
+	}
+	if c.Tenancy.Default != "" && !tenant.Valid(c.Tenancy.Default) {
+		p = append(p, fmt.Sprintf("tenancy.default %q must be lowercase letters, digits and dashes", c.Tenancy.Default))
+	}
+	p = append(p, c.Retention.problems()...)
+	if c.Duplicates.Policy != "allow" && c.Duplicates.Policy != "reuse" && c.Duplicates.Policy != "reject" {
+		p = append(p, fmt.Sprintf("duplicates.policy %q must be allow, reuse or reject", c.Duplicates.Policy))
+	}
+	if c.Duplicates.Distance < 0 {
+		p = append(p, "duplicates.distance must not be negative")
+	}
+	if len(p) != 0 {
+		return ValidationError{Problems: p}
+	}
+	return nil
+}
+
+func (n Nearest) problems() []string {
+	var p []string
+	if n.MaxDistance <= 0 {
+		p = append(p, "nearest.max_distance must be positive")
+	}
+	if n.Strategy != "nearest" && n.Strategy != "weighted" {
+		p = append(p, fmt.Sprintf("nearest.strategy %q must be nearest or weighted", n.Strategy))
+	}
+	if n.Candidates < 1 {
+		p = append(p, "nearest.candidates must be positive")
+	}
+	if n.LoadWeight < 0 || n.PriorityWeight < 0 || n.LoadTTL < 0 {
+		p = append(p, "nearest load_weight, priority_weight and load_ttl must not be negative")
+	}
+	return p
+}
+
+func (g Geocoder) problems() []string {
+	var p []string
+	if g.Timeout < 0 || g.RetryBackoff < 0 || g.BreakerCooldown < 0 {
+		p = append(p, "geocoder durations must not be negative")
+	}
+	if g.Retries < 0 || g.BreakerFailures < 0 || g.DailyBudget < 0 {
+		p = append(p, "geocoder retries, breaker_failures and daily_budget must not be negative")
+	}
+	if g.Workers < 1 || g.MaxAttempts < 1 || g.PollInterval <= 0 {
+		p = append(p, "geocoder workers, max_attempts and poll_interval must be positive")
+	}
+	if _, err := time.LoadLocation(g.BudgetTimeZone); err != nil {
+		p = append(p, fmt.Sprintf("geocoder.budget_time_zone %q is unknown", g.BudgetTimeZone))
+	}
+	return p
+}
+
+func (r Retention) problems() []string {
+	var p []string
+	if r.Deleted < 0 {
+		p = append(p, "retention.deleted must not be negative")
+	}
+	if r.Deleted > 0 && (r.Interval <= 0 || r.BatchSize < 1) {
+		p = append(p, "retention interval and batch_size must be positive")
+	}
+	if (r.ReferenceCollection == "") != (r.ReferenceField == "") {
+		p = append(p, "retention reference_collection and reference_field must be set together")
+	}
+	return p
+}
+
+func (l Limits) problems() []string {
+	var p []string
+	if l.Store != "memory" && l.Store != "mongo" {
+		p = append(p, fmt.Sprintf("limits.store %q must be memory or mongo", l.Store))
+	}
+	if l.UserRate < 0 || l.CallerRate < 0 {
+		p = append(p, "limits rates must not be negative")
+	}
+	if (l.UserRate > 0 && l.UserBurst < 1) || (l.CallerRate > 0 && l.CallerBurst < 1) {
+		p = append(p, "limits bursts must be at least 1 when their rate is set")
+	}
+	if l.MaxDeliveries < 0 {
+		p = append(p, "limits.max_deliveries must not be negative")
+	}
+	if l.MaxBatch < 0 {
+		p = append(p, "limits.max_batch must not be negative")
+	}
+	return p
+}
+
+func (d DB) problems() []string {
+	var p []string
+	if d.Name == "" {
+		p = append(p, "db.name is required (ADDR_DB_DATABASE)")
+	}
+	if d.ConnectRetries < 0 {
+		p = append(p, "db.connect_retries must not be negative")
+	}
+	if d.MinPoolSize > d.MaxPoolSize && d.MaxPoolSize != 0 {
+		p = append(p, "db.min_pool_size must not exceed db.max_pool_size")
+	}
+	if d.URI != "" {
+		return p
+	}
+	if d.Host == "" {
+		p = append(p, "db.host is required (ADDR_DB_HOST)")
+	}
+	if d.User == "" && d.AuthMechanism != "MONGODB-X509" {
+		p = append(p, "db.user is required (ADDR_DB_USER or ADDR_DB_USER_FILE)")
+	}
+	if d.Password == "" && d.AuthMechanism != "MONGODB-X509" {
+		p = append(p, "db.password is required (ADDR_DB_PWD or ADDR_DB_PWD_FILE)")
+	}
+	if d.Cluster == "" {
+		p = append(p, "db.cluster is required (ADDR_DB_NAME)")
+	}
+	return p
+}
+
+// Validate reports the invalid database values, for programs that only
+// need a connection.
+func (d DB) Validate() error {
+	if p := d.problems(); len(p) != 0 {
+		return ValidationError{Problems: p}
+	}
+	return nil
+}
+
+var errUnsupported = errors.New("unsupported field type")
@@ -0,0 +1,202 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %s", name, err)
+	}
+	return p
+}
+
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for k, v := range env {
+		old, ok := os.LookupEnv(k)
+		os.Setenv(k, v)
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(k, old)
+			} else {
+				os.Unsetenv(k)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	yml := writeFile(t, "addr.yaml", `
+port: "3000"
+log_level: debug
+db:
+  user: file-user
+  password: file-pwd
+  cluster: file-cluster
+nearest:
+  max_distance: 1000
+cache:
+  ttl: 1h
+gmaps:
+  api_key: file-key
+`)
+	tml := writeFile(t, "addr.toml", `
+port = "3001"
+[db]
+user = "toml-user"
+password = "toml-pwd"
+cluster = "toml-cluster"
+[gmaps]
+api_key = "toml-key"
+[shutdown]
+timeout = "3s"
+`)
+	pwd := writeFile(t, "pwd", "secret-pwd\n")
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		check   func(t *testing.T, c Config)
+		wantErr bool
+	}{
+		{
+			name: "yaml file",
+			args: []string{"--config", yml},
+			check: func(t *testing.T, c Config) {
+				if c.Port != "3000" || c.LogLevel != "debug" || c.Nearest.MaxDistance != 1000 || c.Cache.TTL != time.Hour {
+					t.Errorf("Load() = %+v", c)
+				}
+				if c.DB.Name != "modular" {
+					t.Errorf("DB.Name = %q, want default modular", c.DB.Name)
+				}
+			},
+		}, {
+			name: "toml file",
+			args: []string{"--config", tml},
+			check: func(t *testing.T, c Config) {
+				if c.Port != "3001" || c.DB.User != "toml-user" || c.Shutdown.Timeout != 3*time.Second {
+					t.Errorf("Load() = %+v", c)
+				}
+			},
+		}, {
+			name: "env overrides file, flag overrides env",
+			args: []string{"--config", yml, "--port", "3003"},
+			env:  map[string]string{"ADDR_PORT": "3002", "NEAREST_MAX_DISTANCE": "2000"},
+			check: func(t *testing.T, c Config) {
+				if c.Port != "3003" {
+					t.Errorf("Port = %q, want 3003", c.Port)
+				}
+				if c.Nearest.MaxDistance != 2000 {
+					t.Errorf("Nearest.MaxDistance = %d, want 2000", c.Nearest.MaxDistance)
+				}
+			},
+		}, {
+			name: "secret from file",
+			args: []string{"--config", yml},
+			env:  map[string]string{"ADDR_DB_PWD_FILE": pwd},
+			check: func(t *testing.T, c Config) {
+				if c.DB.Password != "secret-pwd" {
+					t.Errorf("DB.Password = %q, want secret-pwd", c.DB.Password)
+				}
+			},
+		}, {
+			name:    "unknown file key",
+			args:    []string{"--config", writeFile(t, "bad.yaml", "prot: 1\n")},
+			wantErr: true,
+		}, {
+			name:    "invalid env value",
+			args:    []string{"--config", yml},
+			env:     map[string]string{"GEOCODE_CACHE_TTL": "forever"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			c, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, c)
+			}
+		})
+	}
+}
+
+func TestLoad_everyProblem(t *testing.T) {
+	setEnv(t, map[string]string{"GEOCODE_CACHE_TTL": "forever"})
+	args := []string{"--port", "3000", "--events-batch-size", "many", "--nearest-strategy", "farthest"}
+	_, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), args)
+	var ve ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("Load() error = %v, want ValidationError", err)
+	}
+	for _, want := range []string{"GEOCODE_CACHE_TTL", "-events-batch-size", "nearest.strategy"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Load() error = %v, want %s reported", err, want)
+		}
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	setEnv(t, map[string]string{
+		"GEOCODE_CACHE_TTL":    "forever",
+		"NEAREST_MAX_DISTANCE": "far",
+		"ADDR_DB_PWD_FILE":     filepath.Join(t.TempDir(), "missing"),
+	})
+	c := Default()
+	err := LoadEnv(&c)
+	var ve ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("LoadEnv() error = %v, want ValidationError", err)
+	}
+	if len(ve.Problems) != 3 {
+		t.Errorf("LoadEnv() problems = %q, want every invalid variable", ve.Problems)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	c := Default()
+	c.LogLevel = "verbose"
+	c.Nearest.MaxDistance = 0
+	err := c.Validate()
+	var ve ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("Validate() error = %v, want ValidationError", err)
+	}
+	// port, log level, db user, password and cluster, api key and distance
+	if len(ve.Problems) != 7 {
+		t.Errorf("Validate() problems = %d, want 7: %v", len(ve.Problems), ve.Problems)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	c := Default()
+	c.DB.Password = "pwd-value"
+	c.GMaps.APIKey = "key-value"
+	var b bytes.Buffer
+	if err := Print(&b, c); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+	out := b.String()
+	if strings.Contains(out, "pwd-value") || strings.Contains(out, "key-value") {
+		t.Errorf("Print() leaked a secret:\n%s", out)
+	}
+	if !strings.Contains(out, redacted) {
+		t.Errorf("Print() = %s, want %s", out, redacted)
+	}
+	if c.DB.Password != "pwd-value" {
+		t.Errorf("Print() modified its argument")
+	}
+}
@@ -0,0 +1,220 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// LoadFile overrides c with the values of the YAML (.yaml, .yml) or TOML
+// (.toml) file at path.
+func LoadFile(c *Config, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && err != io.EOF {
+			return fmt.Errorf("decode %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(b), c)
+		if err != nil {
+			return fmt.Errorf("decode %s: %w", path, err)
+		}
+		if u := md.Undecoded(); len(u) != 0 {
+			return fmt.Errorf("decode %s: unknown keys %v", path, u)
+		}
+	default:
+		return fmt.Errorf("config file %s: unknown format, use .yaml or .toml", path)
+	}
+	return nil
+}
+
+// LoadEnv overrides c with the environment variables named by the env tags.
+// Secret values can also be read from the file named by <ENV>_FILE, as
+// mounted by Kubernetes secrets. Every invalid variable is reported at once.
+func LoadEnv(c *Config) error {
+	var p []string
+	walk(reflect.ValueOf(c).Elem(), func(f reflect.StructField, v reflect.Value) {
+		env := f.Tag.Get("env")
+		if env == "" {
+			return
+		}
+		val, ok := os.LookupEnv(env)
+		if file, fok := os.LookupEnv(env + "_FILE"); fok && f.Tag.Get("secret") == "true" {
+			b, err := os.ReadFile(file)
+			if err != nil {
+				p = append(p, fmt.Sprintf("%s_FILE: %s", env, err))
+				return
+			}
+			val, ok = strings.TrimSpace(string(b)), true
+		}
+		if !ok {
+			return
+		}
+		if err := set(v, val); err != nil {
+			p = append(p, fmt.Sprintf("%s: %s", env, err))
+		}
+	})
+	if len(p) != 0 {
+		return ValidationError{Problems: p}
+	}
+	return nil
+}
+
+// walk calls fn for every leaf field of v.
+func walk(v reflect.Value, fn func(reflect.StructField, reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type.Kind() == reflect.Struct {
+			walk(v.Field(i), fn)
+			continue
+		}
+		fn(f, v.Field(i))
+	}
+}
+
+func set(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint64, reflect.Uint32:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return errUnsupported
+	}
+	return nil
+}
+
+// flagValue parses a flag into a config field, only once the file and the
+// environment were applied.
+type flagValue struct {
+	v   reflect.Value
+	def string
+	raw string
+}
+
+func (fv *flagValue) String() string {
+	if fv == nil {
+		return ""
+	}
+	return fv.def
+}
+
+func (fv *flagValue) Set(s string) error {
+	fv.raw = s
+	return nil
+}
+
+// IsBoolFlag allows -otlp-insecure without a value.
+func (fv *flagValue) IsBoolFlag() bool {
+	return fv.v.Kind() == reflect.Bool
+}
+
+type flags map[string]*flagValue
+
+func registerFlags(fs *flag.FlagSet, c *Config) flags {
+	fl := make(flags)
+	walk(reflect.ValueOf(c).Elem(), func(f reflect.StructField, v reflect.Value) {
+		name := f.Tag.Get("flag")
+		if name == "" {
+			return
+		}
+		fv := &flagValue{v: v, def: fmt.Sprint(v.Interface())}
+		usage := f.Tag.Get("usage")
+		if env := f.Tag.Get("env"); env != "" {
+			usage = strings.TrimSpace(usage + " (env " + env + ")")
+		}
+		fs.Var(fv, name, usage)
+		fl[name] = fv
+	})
+	return fl
+}
+
+// apply sets the fields of the flags given on the command line, it reports
+// every invalid flag at once.
+func (fl flags) apply(fs *flag.FlagSet) error {
+	var p []string
+	fs.Visit(func(f *flag.Flag) {
+		fv, ok := fl[f.Name]
+		if !ok {
+			return
+		}
+		if err := set(fv.v, fv.raw); err != nil {
+			p = append(p, fmt.Sprintf("flag -%s: %s", f.Name, err))
+		}
+	})
+	if len(p) != 0 {
+		return ValidationError{Problems: p}
+	}
+	return nil
+}
+
+// Redacted returns a copy of c with every secret replaced.
+func (c Config) Redacted() Config {
+	walk(reflect.ValueOf(&c).Elem(), func(f reflect.StructField, v reflect.Value) {
+		if f.Tag.Get("secret") == "true" && v.Kind() == reflect.String && v.String() != "" {
+			v.SetString(redacted)
+		}
+	})
+	return c
+}
+
+// Print writes c as YAML with its secrets redacted.
+func Print(w io.Writer, c Config) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	return enc.Close()
+}
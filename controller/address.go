@@ -2,11 +2,44 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/modular-project/address-service/audit"
+	"github.com/modular-project/address-service/metrics"
 	"github.com/modular-project/address-service/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
 )
 
+var tracer = otel.Tracer("github.com/modular-project/address-service/controller")
+
+// ErrDeliveryLimit is returned when a user already has the maximum number
+// of active delivery addresses.
+var ErrDeliveryLimit = errors.New("maximum number of delivery addresses reached")
+
+// ErrGeoCoderUnavailable is returned by a GeoCoder that refuses to call its
+// provider, e.g. while its circuit is open or its daily budget is exhausted.
+var ErrGeoCoderUnavailable = errors.New("geocoder unavailable")
+
+// ErrNotGeocoded is returned by Nearest when the location of the address is
+// not known yet.
+var ErrNotGeocoded = errors.New("address is not geocoded yet")
+
+// ErrResumeToken is returned by a watch resumed after a change that is not
+// in the history anymore, the subscriber must read the establishments again.
+var ErrResumeToken = errors.New("invalid or expired resume token")
+
+// ErrWatchUnavailable is returned by the watches when the deletes can not
+// be sent, their tenant is only known from the pre-images of MongoDB 6.0.
+var ErrWatchUnavailable = errors.New("watch unavailable without pre-images")
+
+// ErrNoEstablishment is returned by Nearest when no establishment is within
+// the maximum distance.
+var ErrNoEstablishment = errors.New("no establishment within range")
+
 type GeoCoder interface {
 	GeoCode(context.Context, string) (model.Location, error)
 }
@@ -16,40 +49,215 @@ type AddressStorager interface {
 	DeleteByID(context.Context, string) (int64, error)
 	GetByID(context.Context, string) (model.Address, error)
 	Search(context.Context, *model.Search) ([]model.Address, error)
-	Nearest(context.Context, []float64) (string, error)
+	Candidates(ctx context.Context, loc []float64, av model.Availability, limit int) ([]model.Candidate, error)
+	GetMany(context.Context, []string) ([]model.Address, error)
+	SetInfo(context.Context, string, model.EstablishmentInfo) (model.Address, error)
+	ReportLoad(ctx context.Context, aID string, active int, at time.Time) (int64, error)
 }
 
 type DeliveryStorager interface {
 	Create(context.Context, *model.Delivery) (string, error)
 	GetAll(context.Context, uint64) ([]model.Address, error)
+	GetActive(context.Context, uint64) ([]model.Address, error)
 	GetByID(context.Context, uint64, string) (model.Address, error)
 	DeleteByID(context.Context, uint64, string) (int64, error)
+	CountActive(context.Context, uint64) (int64, error)
+	GetMany(context.Context, []string) ([]model.Delivery, error)
+	Restore(context.Context, uint64, string) (int64, error)
+}
+
+// Outbox runs a change and stores the events it returns atomically.
+type Outbox interface {
+	Atomically(ctx context.Context, fn func(context.Context) ([]model.Event, error)) error
+}
+
+type noOutbox struct{}
+
+func (noOutbox) Atomically(ctx context.Context, fn func(context.Context) ([]model.Event, error)) error {
+	_, err := fn(ctx)
+	return err
+}
+
+// NoOutbox runs the changes and drops their events.
+var NoOutbox Outbox = noOutbox{}
+
+// Auditor appends the audit entries, with the change when it runs within
+// an Outbox transaction.
+type Auditor interface {
+	Record(context.Context, model.AuditEntry) error
+}
+
+type noAuditor struct{}
+
+func (noAuditor) Record(context.Context, model.AuditEntry) error { return nil }
+
+// NoAuditor drops the audit entries.
+var NoAuditor Auditor = noAuditor{}
+
+type bestEffortAuditor struct {
+	au Auditor
+	l  *zap.Logger
+}
+
+// BestEffort records the entries with au but only logs and counts its
+// errors. It is meant for an Outbox without transactions, where the change
+// is already stored when its entry fails and an error would make the caller
+// retry it.
+func BestEffort(au Auditor, l *zap.Logger) Auditor {
+	return bestEffortAuditor{au: au, l: l}
+}
+
+func (b bestEffortAuditor) Record(ctx context.Context, e model.AuditEntry) error {
+	if err := b.au.Record(ctx, e); err != nil {
+		metrics.AuditDropped(e.Kind)
+		b.l.Error("audit entry dropped", zap.String("kind", e.Kind), zap.String("op", e.Op),
+			zap.String("id", e.AddressID), zap.Error(err))
+	}
+	return nil
 }
 
 type AddressService struct {
 	ast AddressStorager
 	dst DeliveryStorager
 	gc  GeoCoder
+	// maxDeliveries per user, 0 is unlimited
+	maxDeliveries int64
+	// async stores the addresses as pending, a GeocodeWorker finds their
+	// location later
+	async bool
+	ob    Outbox
+	au    Auditor
+	// maxBatch ids per batch lookup, 0 is unlimited
+	maxBatch int
+	// dupPolicy of CreateDelivery when none is given, duplicates are
+	// allowed when empty
+	dupPolicy   string
+	dupDistance float64
+	zones       ZoneChecker
+	// strategy scores the candidates closest establishments in Nearest
+	strategy   Strategy
+	candidates int
 }
 
-func NewAddressService(as AddressStorager, ds DeliveryStorager, gc GeoCoder) AddressService {
-	return AddressService{ast: as, dst: ds, gc: gc}
+type Option func(*AddressService)
+
+// WithAsyncGeocoding creates the addresses without waiting for the geocoder.
+func WithAsyncGeocoding(async bool) Option {
+	return func(as *AddressService) {
+		as.async = async
+	}
+}
+
+// WithOutbox publishes the domain events through ob.
+func WithOutbox(ob Outbox) Option {
+	return func(as *AddressService) {
+		as.ob = ob
+	}
 }
 
-func (as AddressService) CreateDelivery(ctx context.Context, d *model.Delivery) (string, error) {
-	loc, err := as.gc.GeoCode(ctx, d.Address.String())
+// WithAuditor records who changes the addresses in au.
+func WithAuditor(au Auditor) Option {
+	return func(as *AddressService) {
+		as.au = au
+	}
+}
+
+// WithMaxDeliveries limits the active delivery addresses of each user. The
+// limit is checked before the change, not enforced atomically with it.
+func WithMaxDeliveries(n int64) Option {
+	return func(as *AddressService) {
+		as.maxDeliveries = n
+	}
+}
+
+func NewAddressService(as AddressStorager, ds DeliveryStorager, gc GeoCoder, opts ...Option) AddressService {
+	s := AddressService{ast: as, dst: ds, gc: gc, ob: NoOutbox, au: NoAuditor, zones: noZones{},
+		strategy: NearestStrategy{}, candidates: 1}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// CreateDelivery stores d, geocoded unless geocoding is asynchronous. When
+// the user already has the address, policy decides whether it is created
+// again, the existing id is returned with duplicate true or it is rejected
+// with a DuplicateError. An empty policy is the one of WithDuplicates.
+func (as AddressService) CreateDelivery(ctx context.Context, d *model.Delivery, policy string) (id string, duplicate bool, err error) {
+	ctx, span := tracer.Start(ctx, "AddressService.CreateDelivery")
+	defer span.End()
+	span.SetAttributes(attribute.String("address.city", d.City))
+	if policy == "" {
+		policy = as.dupPolicy
+	}
+	if policy == "" {
+		policy = DuplicateAllow
+	}
+	if !ValidDuplicatePolicy(policy) {
+		return "", false, fmt.Errorf("unknown duplicate policy %q", policy)
+	}
+	existing, err := as.existing(ctx, d.UserID, policy)
 	if err != nil {
-		return "", fmt.Errorf("gc.GeoCode: %w", err)
+		return "", false, err
+	}
+	// the fingerprint is compared before geocoding to spare the request
+	if e, ok := as.duplicate(d.Address, existing); ok {
+		return as.reuse(ctx, d, e, policy)
+	}
+	// the count and the insert are not atomic, concurrent creations of a
+	// user may go a few over the limit, as many as the rate limit lets in
+	if as.maxDeliveries > 0 {
+		n, err := as.dst.CountActive(ctx, d.UserID)
+		if err != nil {
+			return "", false, fmt.Errorf("dst.CountActive: %w", err)
+		}
+		if n >= as.maxDeliveries {
+			return "", false, ErrDeliveryLimit
+		}
+	}
+	if err := as.geocode(ctx, &d.Address); err != nil {
+		return "", false, err
 	}
-	d.Location = loc
-	id, err := as.dst.Create(ctx, d)
+	if e, ok := as.duplicate(d.Address, existing); ok {
+		return as.reuse(ctx, d, e, policy)
+	}
+	err = as.ob.Atomically(ctx, func(ctx context.Context) ([]model.Event, error) {
+		var err error
+		if id, err = as.dst.Create(ctx, d); err != nil {
+			return nil, fmt.Errorf("dst.Create: %w", err)
+		}
+		e := audit.NewEntry(ctx, model.AuditCreate, metrics.Delivery, id, d.UserID, model.Address{}, d.Address)
+		if err := as.au.Record(ctx, e); err != nil {
+			return nil, fmt.Errorf("au.Record: %w", err)
+		}
+		return []model.Event{model.NewEvent(model.DeliveryCreated, id, d.UserID)}, nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("dst.Create: %w", err)
+		return "", false, err
 	}
-	return id, nil
+	metrics.AddressCreated(metrics.Delivery)
+	return id, false, nil
+}
+
+// geocode sets the location of a, or marks it as pending when geocoding is
+// asynchronous.
+func (as AddressService) geocode(ctx context.Context, a *model.Address) error {
+	if as.async {
+		a.GeocodeStatus = model.GeocodePending
+		a.GeocodeRetryAt = time.Now()
+		return nil
+	}
+	loc, err := as.gc.GeoCode(ctx, a.String())
+	if err != nil {
+		return fmt.Errorf("gc.GeoCode: %w", err)
+	}
+	a.SetLocation(loc, time.Now())
+	return nil
 }
 
 func (as AddressService) User(ctx context.Context, uID uint64) ([]model.Address, error) {
+	ctx, span := tracer.Start(ctx, "AddressService.User")
+	defer span.End()
 	a, err := as.dst.GetAll(ctx, uID)
 	if err != nil {
 		return nil, fmt.Errorf("dst.getAll: %w", err)
@@ -58,6 +266,8 @@ func (as AddressService) User(ctx context.Context, uID uint64) ([]model.Address,
 }
 
 func (as AddressService) GetAddByID(ctx context.Context, aID string) (model.Address, error) {
+	ctx, span := tracer.Start(ctx, "AddressService.GetAddByID")
+	defer span.End()
 	a, err := as.ast.GetByID(ctx, aID)
 	if err != nil {
 		return model.Address{}, fmt.Errorf("ast.GetByID: %w", err)
@@ -66,47 +276,135 @@ func (as AddressService) GetAddByID(ctx context.Context, aID string) (model.Addr
 }
 
 func (as AddressService) DeleteByUser(ctx context.Context, uID uint64, aID string) (int64, error) {
-	d, err := as.dst.DeleteByID(ctx, uID, aID)
+	ctx, span := tracer.Start(ctx, "AddressService.DeleteByUser")
+	defer span.End()
+	var d int64
+	err := as.ob.Atomically(ctx, func(ctx context.Context) ([]model.Event, error) {
+		var err error
+		if d, err = as.dst.DeleteByID(ctx, uID, aID); err != nil {
+			return nil, fmt.Errorf("dst.DeleteByID: %w", err)
+		}
+		if d == 0 {
+			return nil, nil
+		}
+		e := audit.NewEntry(ctx, model.AuditDelete, metrics.Delivery, aID, uID,
+			model.Address{}, model.Address{IsDeleted: true})
+		if err := as.au.Record(ctx, e); err != nil {
+			return nil, fmt.Errorf("au.Record: %w", err)
+		}
+		return []model.Event{model.NewEvent(model.DeliveryDeleted, aID, uID)}, nil
+	})
 	if err != nil {
-		return 0, fmt.Errorf("dst.DeleteByID: %w", err)
+		return 0, err
 	}
+	metrics.AddressDeleted(metrics.Delivery, d)
 	return d, nil
 }
 
-func (as AddressService) Nearest(ctx context.Context, uID uint64, aID string) (string, error) {
-	add, err := as.dst.GetByID(ctx, uID, aID)
+// RestoreDelivery undeletes the delivery address aID of uID, it returns 0
+// when it is not deleted. The restored address counts against the limit.
+func (as AddressService) RestoreDelivery(ctx context.Context, uID uint64, aID string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "AddressService.RestoreDelivery")
+	defer span.End()
+	if as.maxDeliveries > 0 {
+		n, err := as.dst.CountActive(ctx, uID)
+		if err != nil {
+			return 0, fmt.Errorf("dst.CountActive: %w", err)
+		}
+		if n >= as.maxDeliveries {
+			return 0, ErrDeliveryLimit
+		}
+	}
+	var r int64
+	err := as.ob.Atomically(ctx, func(ctx context.Context) ([]model.Event, error) {
+		var err error
+		if r, err = as.dst.Restore(ctx, uID, aID); err != nil {
+			return nil, fmt.Errorf("dst.Restore: %w", err)
+		}
+		if r == 0 {
+			return nil, nil
+		}
+		e := audit.NewEntry(ctx, model.AuditRestore, metrics.Delivery, aID, uID,
+			model.Address{IsDeleted: true}, model.Address{})
+		if err := as.au.Record(ctx, e); err != nil {
+			return nil, fmt.Errorf("au.Record: %w", err)
+		}
+		return []model.Event{model.NewEvent(model.DeliveryRestored, aID, uID)}, nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("dst.GetByID: %w", err)
+		return 0, err
 	}
-	id, err := as.ast.Nearest(ctx, add.Location.Coordinates)
+	return r, nil
+}
+
+// Nearest returns the id of the establishment of av assigned to the
+// delivery address aID of uID, the closest one unless WithAssignment.
+func (as AddressService) Nearest(ctx context.Context, uID uint64, aID string, av model.Availability) (string, error) {
+	a, err := as.Assign(ctx, uID, aID, av)
 	if err != nil {
-		return "", fmt.Errorf("ast.Nearest: %w", err)
+		return "", err
 	}
-	return id, nil
+	return a.EstablishmentID(), nil
 }
 
 func (as AddressService) Create(ctx context.Context, a *model.Address) (string, error) {
-	loc, err := as.gc.GeoCode(ctx, a.String())
-	if err != nil {
-		return "", fmt.Errorf("gc.GeoCode: %w", err)
+	ctx, span := tracer.Start(ctx, "AddressService.Create")
+	defer span.End()
+	span.SetAttributes(attribute.String("address.city", a.City))
+	if err := as.geocode(ctx, a); err != nil {
+		return "", err
 	}
-	a.Location = loc
-	id, err := as.ast.Create(ctx, a)
+	var id string
+	err := as.ob.Atomically(ctx, func(ctx context.Context) ([]model.Event, error) {
+		var err error
+		if id, err = as.ast.Create(ctx, a); err != nil {
+			return nil, fmt.Errorf("ast.Create: %w", err)
+		}
+		e := audit.NewEntry(ctx, model.AuditCreate, metrics.Establishment, id, 0, model.Address{}, *a)
+		if err := as.au.Record(ctx, e); err != nil {
+			return nil, fmt.Errorf("au.Record: %w", err)
+		}
+		return []model.Event{model.NewEvent(model.EstablishmentCreated, id, 0)}, nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("ast.Create: %w", err)
+		return "", err
 	}
+	metrics.AddressCreated(metrics.Establishment)
 	return id, nil
 }
 
 func (as AddressService) DeleteByID(ctx context.Context, aID string) (int64, error) {
-	d, err := as.ast.DeleteByID(ctx, aID)
+	ctx, span := tracer.Start(ctx, "AddressService.DeleteByID")
+	defer span.End()
+	var d int64
+	err := as.ob.Atomically(ctx, func(ctx context.Context) ([]model.Event, error) {
+		// the establishment is removed, the entry keeps its last fields
+		before, err := as.ast.GetMany(ctx, []string{aID})
+		if err != nil {
+			return nil, fmt.Errorf("ast.GetMany: %w", err)
+		}
+		if d, err = as.ast.DeleteByID(ctx, aID); err != nil {
+			return nil, fmt.Errorf("ast.DeleteByID: %w", err)
+		}
+		if d == 0 || len(before) == 0 {
+			return nil, nil
+		}
+		e := audit.NewEntry(ctx, model.AuditDelete, metrics.Establishment, aID, 0, before[0], model.Address{})
+		if err := as.au.Record(ctx, e); err != nil {
+			return nil, fmt.Errorf("au.Record: %w", err)
+		}
+		return []model.Event{model.NewEvent(model.EstablishmentRemoved, aID, 0)}, nil
+	})
 	if err != nil {
-		return 0, fmt.Errorf("ast.DeleteByID: %w", err)
+		return 0, err
 	}
+	metrics.AddressDeleted(metrics.Establishment, d)
 	return d, nil
 }
 
 func (as AddressService) GetByID(ctx context.Context, uID uint64, aID string) (model.Address, error) {
+	ctx, span := tracer.Start(ctx, "AddressService.GetByID")
+	defer span.End()
 	a, err := as.dst.GetByID(ctx, uID, aID)
 	if err != nil {
 		return model.Address{}, fmt.Errorf("dst.GetByID: %w", err)
@@ -115,6 +413,8 @@ func (as AddressService) GetByID(ctx context.Context, uID uint64, aID string) (m
 }
 
 func (as AddressService) Search(ctx context.Context, s *model.Search) ([]model.Address, error) {
+	ctx, span := tracer.Start(ctx, "AddressService.Search")
+	defer span.End()
 	a, err := as.ast.Search(ctx, s)
 	if err != nil {
 		return nil, fmt.Errorf("ast.Search: %w", err)
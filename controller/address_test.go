@@ -0,0 +1,274 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/modular-project/address-service/audit"
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type fakeGeoCoder struct {
+	calls int
+	err   error
+}
+
+func (f *fakeGeoCoder) GeoCode(context.Context, string) (model.Location, error) {
+	f.calls++
+	if f.err != nil {
+		return model.Location{}, f.err
+	}
+	return model.Location{Type: "Point", Coordinates: []float64{-103.3266212, 20.6545464}}, nil
+}
+
+type fakeDeliveryStorage struct {
+	ds []model.Delivery
+}
+
+func (f *fakeDeliveryStorage) Create(_ context.Context, d *model.Delivery) (string, error) {
+	d.ID = primitive.NewObjectID()
+	f.ds = append(f.ds, *d)
+	return d.ID.Hex(), nil
+}
+
+func (f *fakeDeliveryStorage) GetAll(_ context.Context, uID uint64) ([]model.Address, error) {
+	var as []model.Address
+	for _, d := range f.ds {
+		if d.UserID == uID {
+			as = append(as, d.Address)
+		}
+	}
+	return as, nil
+}
+
+func (f *fakeDeliveryStorage) GetActive(_ context.Context, uID uint64) ([]model.Address, error) {
+	var as []model.Address
+	for _, d := range f.ds {
+		if d.UserID == uID && !d.IsDeleted {
+			as = append(as, d.Address)
+		}
+	}
+	return as, nil
+}
+
+func (f *fakeDeliveryStorage) GetByID(_ context.Context, uID uint64, aID string) (model.Address, error) {
+	for _, d := range f.ds {
+		if d.UserID == uID && d.ID.Hex() == aID {
+			return d.Address, nil
+		}
+	}
+	return model.Address{}, errors.New("not found")
+}
+
+func (f *fakeDeliveryStorage) DeleteByID(_ context.Context, uID uint64, aID string) (int64, error) {
+	for i, d := range f.ds {
+		if d.UserID == uID && d.ID.Hex() == aID && !d.IsDeleted {
+			f.ds[i].IsDeleted = true
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (f *fakeDeliveryStorage) CountActive(_ context.Context, uID uint64) (int64, error) {
+	var n int64
+	for _, d := range f.ds {
+		if d.UserID == uID && !d.IsDeleted {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (f *fakeDeliveryStorage) Restore(_ context.Context, uID uint64, aID string) (int64, error) {
+	for i, d := range f.ds {
+		if d.UserID == uID && d.ID.Hex() == aID && d.IsDeleted {
+			f.ds[i].IsDeleted = false
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (f *fakeDeliveryStorage) GetMany(_ context.Context, ids []string) ([]model.Delivery, error) {
+	var ds []model.Delivery
+	for _, d := range f.ds {
+		for _, id := range ids {
+			if d.ID.Hex() == id {
+				ds = append(ds, d)
+			}
+		}
+	}
+	return ds, nil
+}
+
+func TestAddressService_CreateDelivery(t *testing.T) {
+	tests := []struct {
+		name      string
+		max       int64
+		existing  []model.Delivery
+		wantErr   error
+		wantCalls int
+	}{
+		{
+			name:      "unlimited",
+			existing:  []model.Delivery{{UserID: 1}, {UserID: 1}},
+			wantCalls: 1,
+		}, {
+			name:      "under the limit",
+			max:       2,
+			existing:  []model.Delivery{{UserID: 1}, {UserID: 2}, {UserID: 2}},
+			wantCalls: 1,
+		}, {
+			name:     "limit reached",
+			max:      2,
+			existing: []model.Delivery{{UserID: 1}, {UserID: 1}},
+			wantErr:  ErrDeliveryLimit,
+		}, {
+			name:      "deleted addresses do not count",
+			max:       2,
+			existing:  []model.Delivery{{UserID: 1}, {UserID: 1, Address: model.Address{IsDeleted: true}}},
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gc := &fakeGeoCoder{}
+			dst := &fakeDeliveryStorage{ds: tt.existing}
+			as := NewAddressService(nil, dst, gc, WithMaxDeliveries(tt.max))
+			_, _, err := as.CreateDelivery(context.Background(), &model.Delivery{UserID: 1}, "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AddressService.CreateDelivery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gc.calls != tt.wantCalls {
+				t.Errorf("AddressService.CreateDelivery() geocoded %d times, want %d", gc.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+type fakeAuditor struct {
+	es []model.AuditEntry
+}
+
+func (f *fakeAuditor) Record(_ context.Context, e model.AuditEntry) error {
+	f.es = append(f.es, e)
+	return nil
+}
+
+func TestAddressService_CreateDelivery_audit(t *testing.T) {
+	au := &fakeAuditor{}
+	as := NewAddressService(nil, &fakeDeliveryStorage{}, &fakeGeoCoder{}, WithAuditor(au))
+	d := model.Delivery{UserID: 1, Address: model.Address{Street: "Av. Vallarta 1", Suburb: "Americana", PostalCode: "44160"}}
+	if _, _, err := as.CreateDelivery(context.Background(), &d, ""); err != nil {
+		t.Fatalf("AddressService.CreateDelivery() error = %v", err)
+	}
+	if len(au.es) != 1 {
+		t.Fatalf("AddressService.CreateDelivery() audited %d entries, want 1", len(au.es))
+	}
+	fields := map[string]bool{}
+	for _, c := range au.es[0].Changes {
+		fields[c.Field] = true
+		for _, plain := range []string{"Vallarta", "Americana", "44160", "20.654546"} {
+			if strings.Contains(c.Before+c.After, plain) {
+				t.Errorf("AddressService.CreateDelivery() audited %s = %q", c.Field, c.After)
+			}
+		}
+	}
+	for _, f := range []string{"street", "suburb", "pc", "location"} {
+		if !fields[f] {
+			t.Errorf("AddressService.CreateDelivery() did not audit the change of %s", f)
+		}
+	}
+}
+
+type failingAuditor struct{}
+
+func (failingAuditor) Record(context.Context, model.AuditEntry) error {
+	return errors.New("audit unavailable")
+}
+
+func TestAddressService_DeleteByUser_bestEffortAudit(t *testing.T) {
+	d := model.Delivery{UserID: 1, Address: model.Address{ID: primitive.NewObjectID()}}
+	tests := []struct {
+		name    string
+		au      Auditor
+		wantErr bool
+	}{
+		{name: "strict", au: failingAuditor{}, wantErr: true},
+		{name: "best effort", au: BestEffort(failingAuditor{}, zap.NewNop())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := &fakeDeliveryStorage{ds: []model.Delivery{d}}
+			as := NewAddressService(nil, dst, nil, WithAuditor(tt.au))
+			_, err := as.DeleteByUser(context.Background(), 1, d.ID.Hex())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AddressService.DeleteByUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !dst.ds[0].IsDeleted {
+				t.Errorf("AddressService.DeleteByUser() did not delete the address")
+			}
+		})
+	}
+}
+
+func TestAddressService_RestoreDelivery(t *testing.T) {
+	deleted := model.Delivery{UserID: 1, Address: model.Address{ID: primitive.NewObjectID(), IsDeleted: true}}
+	tests := []struct {
+		name      string
+		max       int64
+		uID       uint64
+		existing  []model.Delivery
+		want      int64
+		wantErr   error
+		wantAudit []string
+	}{
+		{
+			name:      "restored",
+			uID:       1,
+			existing:  []model.Delivery{deleted},
+			want:      1,
+			wantAudit: []string{model.AuditRestore},
+		}, {
+			name:     "other user",
+			uID:      2,
+			existing: []model.Delivery{deleted},
+		}, {
+			name:     "limit reached",
+			max:      1,
+			uID:      1,
+			existing: []model.Delivery{deleted, {UserID: 1}},
+			wantErr:  ErrDeliveryLimit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			au := &fakeAuditor{}
+			dst := &fakeDeliveryStorage{ds: append([]model.Delivery(nil), tt.existing...)}
+			as := NewAddressService(nil, dst, nil, WithMaxDeliveries(tt.max), WithAuditor(au))
+			got, err := as.RestoreDelivery(context.Background(), tt.uID, deleted.ID.Hex())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AddressService.RestoreDelivery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("AddressService.RestoreDelivery() = %d, want %d", got, tt.want)
+			}
+			var ops []string
+			for _, e := range au.es {
+				ops = append(ops, e.Op)
+				if e.Actor != audit.Unknown || e.UserID != tt.uID {
+					t.Errorf("AddressService.RestoreDelivery() audited %+v", e)
+				}
+			}
+			if !reflect.DeepEqual(ops, tt.wantAudit) {
+				t.Errorf("AddressService.RestoreDelivery() audited %v, want %v", ops, tt.wantAudit)
+			}
+		})
+	}
+}
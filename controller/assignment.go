@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/modular-project/address-service/model"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Assignment strategies.
+const (
+	StrategyNearest  = "nearest"
+	StrategyWeighted = "weighted"
+)
+
+// Score of a candidate in meters, the lowest Total is assigned. Load and
+// Priority are what the strategy adds to the Distance.
+type Score struct {
+	EstablishmentID string
+	Distance        float64
+	Load            float64
+	Priority        float64
+	Total           float64
+}
+
+// Strategy scores the candidates of Nearest at now.
+type Strategy interface {
+	Name() string
+	Score(c model.Candidate, now time.Time) Score
+}
+
+// NearestStrategy assigns the closest establishment.
+type NearestStrategy struct{}
+
+func (NearestStrategy) Name() string { return StrategyNearest }
+
+func (NearestStrategy) Score(c model.Candidate, _ time.Time) Score {
+	return Score{EstablishmentID: c.ID.Hex(), Distance: c.Distance, Total: c.Distance}
+}
+
+// WeightedStrategy adds to the distance LoadWeight meters for each capacity
+// full of active orders and subtracts PriorityWeight meters for each
+// priority point. The load of an establishment without capacity, or not
+// reported within LoadTTL, is ignored.
+type WeightedStrategy struct {
+	LoadWeight     float64
+	PriorityWeight float64
+	LoadTTL        time.Duration
+}
+
+func (WeightedStrategy) Name() string { return StrategyWeighted }
+
+func (ws WeightedStrategy) Score(c model.Candidate, now time.Time) Score {
+	s := Score{EstablishmentID: c.ID.Hex(), Distance: c.Distance}
+	if c.Capacity > 0 && c.Load != nil && (ws.LoadTTL <= 0 || now.Sub(c.Load.ReportedAt) <= ws.LoadTTL) {
+		s.Load = ws.LoadWeight * float64(c.Load.Active) / float64(c.Capacity)
+	}
+	s.Priority = -ws.PriorityWeight * float64(c.Priority)
+	s.Total = s.Distance + s.Load + s.Priority
+	return s
+}
+
+// WithAssignment scores the n closest establishments with s in Nearest.
+func WithAssignment(s Strategy, n int) Option {
+	return func(as *AddressService) {
+		as.strategy, as.candidates = s, n
+	}
+}
+
+// Assignment is the establishment of Nearest with the scores of every
+// candidate, the first one is the assigned.
+type Assignment struct {
+	Strategy string
+	Scores   []Score
+}
+
+// EstablishmentID returns the assigned establishment.
+func (a Assignment) EstablishmentID() string {
+	return a.Scores[0].EstablishmentID
+}
+
+// assign scores the establishments of av close to loc, it returns
+// ErrNoEstablishment when there is none.
+func (as AddressService) assign(ctx context.Context, loc []float64, av model.Availability) (Assignment, error) {
+	n := as.candidates
+	if n < 1 {
+		n = 1
+	}
+	cs, err := as.ast.Candidates(ctx, loc, av, n)
+	if err != nil {
+		return Assignment{}, fmt.Errorf("ast.Candidates: %w", err)
+	}
+	a := Assignment{Strategy: as.strategy.Name(), Scores: make([]Score, len(cs))}
+	now := time.Now()
+	for i, c := range cs {
+		a.Scores[i] = as.strategy.Score(c, now)
+	}
+	// the candidates come by distance, the closest wins a tie
+	sort.SliceStable(a.Scores, func(i, j int) bool {
+		return a.Scores[i].Total < a.Scores[j].Total
+	})
+	return a, nil
+}
+
+// Assign returns the establishment of av for the delivery address aID of
+// uID, by the strategy of WithAssignment.
+func (as AddressService) Assign(ctx context.Context, uID uint64, aID string, av model.Availability) (Assignment, error) {
+	ctx, span := tracer.Start(ctx, "AddressService.Assign")
+	defer span.End()
+	add, err := as.dst.GetByID(ctx, uID, aID)
+	if err != nil {
+		return Assignment{}, fmt.Errorf("dst.GetByID: %w", err)
+	}
+	if add.Status() != model.GeocodeDone {
+		return Assignment{}, fmt.Errorf("%w: status %s", ErrNotGeocoded, add.Status())
+	}
+	a, err := as.assign(ctx, add.Location.Coordinates, av)
+	if err != nil {
+		return Assignment{}, err
+	}
+	span.SetAttributes(attribute.String("strategy", a.Strategy), attribute.Int("candidates", len(a.Scores)))
+	return a, nil
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type fakeCandidates struct {
+	AddressStorager
+	cs []model.Candidate
+}
+
+func (f fakeCandidates) Candidates(_ context.Context, _ []float64, _ model.Availability, limit int) ([]model.Candidate, error) {
+	if len(f.cs) == 0 {
+		return nil, ErrNoEstablishment
+	}
+	if limit < len(f.cs) {
+		return f.cs[:limit], nil
+	}
+	return f.cs, nil
+}
+
+func candidate(distance float64, active, capacity, priority int, reported time.Time) model.Candidate {
+	return model.Candidate{
+		Address: model.Address{
+			ID:       primitive.NewObjectID(),
+			Priority: priority,
+			Capacity: capacity,
+			Load:     &model.Load{Active: active, ReportedAt: reported},
+		},
+		Distance: distance,
+	}
+}
+
+func TestAddressService_assign(t *testing.T) {
+	now := time.Now()
+	weighted := WeightedStrategy{LoadWeight: 2000, PriorityWeight: 500, LoadTTL: 10 * time.Minute}
+	busy := candidate(400, 30, 30, 0, now)
+	idle := candidate(1200, 0, 20, 0, now)
+	stale := candidate(400, 30, 30, 0, now.Add(-time.Hour))
+	favored := candidate(1200, 0, 0, 2, now)
+	tests := []struct {
+		name       string
+		strategy   Strategy
+		n          int
+		cs         []model.Candidate
+		want       string
+		wantScores int
+	}{
+		{name: "nearest", strategy: NearestStrategy{}, n: 5, cs: []model.Candidate{busy, idle}, want: busy.ID.Hex(), wantScores: 2},
+		{name: "idle further", strategy: weighted, n: 5, cs: []model.Candidate{busy, idle}, want: idle.ID.Hex(), wantScores: 2},
+		{name: "stale load", strategy: weighted, n: 5, cs: []model.Candidate{stale, idle}, want: stale.ID.Hex(), wantScores: 2},
+		{name: "priority", strategy: weighted, n: 5, cs: []model.Candidate{candidate(400, 0, 0, 0, now), favored}, want: favored.ID.Hex(), wantScores: 2},
+		{name: "only the closest", strategy: weighted, n: 1, cs: []model.Candidate{busy, idle}, want: busy.ID.Hex(), wantScores: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			as := NewAddressService(fakeCandidates{cs: tt.cs}, nil, nil, WithAssignment(tt.strategy, tt.n))
+			got, err := as.assign(context.Background(), []float64{-103.3474, 20.6767}, model.Availability{})
+			if err != nil {
+				t.Fatalf("AddressService.assign() error = %v", err)
+			}
+			if got.EstablishmentID() != tt.want {
+				t.Errorf("AddressService.assign() = %+v, want %s", got.Scores, tt.want)
+			}
+			if got.Strategy != tt.strategy.Name() || len(got.Scores) != tt.wantScores {
+				t.Errorf("AddressService.assign() strategy %s with %d scores", got.Strategy, len(got.Scores))
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrBatchSize is returned when a batch asks for more ids than allowed.
+var ErrBatchSize = errors.New("too many ids in the batch")
+
+// Statuses of a BatchResult.
+const (
+	BatchFound     = "found"
+	BatchNotFound  = "not_found"
+	BatchForbidden = "forbidden"
+	BatchInvalidID = "invalid_id"
+)
+
+// BatchResult is the lookup of an id of a batch, Address is set when it
+// was found.
+type BatchResult struct {
+	ID      string
+	Status  string
+	Address model.Address
+}
+
+// WithMaxBatch limits the ids of a batch lookup, 0 is unlimited.
+func WithMaxBatch(n int) Option {
+	return func(as *AddressService) {
+		as.maxBatch = n
+	}
+}
+
+// batch returns the results of ids with the status of the invalid ones and
+// the distinct valid ids to look up.
+func (as AddressService) batch(ids []string) ([]BatchResult, []string, error) {
+	if as.maxBatch > 0 && len(ids) > as.maxBatch {
+		return nil, nil, fmt.Errorf("%w: %d, the maximum is %d", ErrBatchSize, len(ids), as.maxBatch)
+	}
+	res := make([]BatchResult, len(ids))
+	seen := make(map[string]bool, len(ids))
+	var valid []string
+	for i, id := range ids {
+		res[i].ID = id
+		if !primitive.IsValidObjectID(id) {
+			res[i].Status = BatchInvalidID
+			continue
+		}
+		if !seen[id] {
+			seen[id] = true
+			valid = append(valid, id)
+		}
+	}
+	return res, valid, nil
+}
+
+// BatchGetEstablishments looks the establishments up in a single query,
+// the results follow the order of ids.
+func (as AddressService) BatchGetEstablishments(ctx context.Context, ids []string) ([]BatchResult, error) {
+	ctx, span := tracer.Start(ctx, "AddressService.BatchGetEstablishments")
+	defer span.End()
+	span.SetAttributes(attribute.Int("batch.size", len(ids)))
+	res, valid, err := as.batch(ids)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]model.Address, len(valid))
+	if len(valid) != 0 {
+		ads, err := as.ast.GetMany(ctx, valid)
+		if err != nil {
+			return nil, fmt.Errorf("ast.GetMany: %w", err)
+		}
+		for _, a := range ads {
+			found[a.ID.Hex()] = a
+		}
+	}
+	for i := range res {
+		if res[i].Status != "" {
+			continue
+		}
+		a, ok := found[res[i].ID]
+		if !ok {
+			res[i].Status = BatchNotFound
+			continue
+		}
+		res[i].Status, res[i].Address = BatchFound, a
+	}
+	return res, nil
+}
+
+// BatchGetDeliveries looks the delivery addresses up in a single query,
+// the ones of another user than uID are forbidden and the anonymized ones
+// not found. The results follow the order of ids.
+func (as AddressService) BatchGetDeliveries(ctx context.Context, uID uint64, ids []string) ([]BatchResult, error) {
+	ctx, span := tracer.Start(ctx, "AddressService.BatchGetDeliveries")
+	defer span.End()
+	span.SetAttributes(attribute.Int("batch.size", len(ids)))
+	res, valid, err := as.batch(ids)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]model.Delivery, len(valid))
+	if len(valid) != 0 {
+		ds, err := as.dst.GetMany(ctx, valid)
+		if err != nil {
+			return nil, fmt.Errorf("dst.GetMany: %w", err)
+		}
+		for _, d := range ds {
+			found[d.ID.Hex()] = d
+		}
+	}
+	for i := range res {
+		if res[i].Status != "" {
+			continue
+		}
+		d, ok := found[res[i].ID]
+		switch {
+		case !ok, d.Anonymized:
+			res[i].Status = BatchNotFound
+		case d.UserID != uID:
+			res[i].Status = BatchForbidden
+		default:
+			res[i].Status, res[i].Address = BatchFound, d.Address
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAddressService_BatchGetDeliveries(t *testing.T) {
+	own, other, anonymized := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	missing := primitive.NewObjectID().Hex()
+	dst := &fakeDeliveryStorage{ds: []model.Delivery{
+		{Address: model.Address{ID: own}, UserID: 1},
+		{Address: model.Address{ID: other}, UserID: 2},
+		{Address: model.Address{ID: anonymized, City: "Guadalajara", Anonymized: true}},
+	}}
+	tests := []struct {
+		name    string
+		max     int
+		uID     uint64
+		ids     []string
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "request order",
+			max:  5,
+			uID:  1,
+			ids:  []string{missing, other.Hex(), "nope", own.Hex(), own.Hex()},
+			want: []string{BatchNotFound, BatchForbidden, BatchInvalidID, BatchFound, BatchFound},
+		}, {
+			name: "anonymized without user",
+			max:  5,
+			ids:  []string{anonymized.Hex()},
+			want: []string{BatchNotFound},
+		}, {
+			name: "anonymized",
+			max:  5,
+			uID:  1,
+			ids:  []string{anonymized.Hex()},
+			want: []string{BatchNotFound},
+		}, {
+			name: "empty",
+			want: []string{},
+		}, {
+			name:    "too many ids",
+			max:     3,
+			ids:     []string{own.Hex(), own.Hex(), own.Hex(), own.Hex()},
+			wantErr: ErrBatchSize,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			as := NewAddressService(nil, dst, nil, WithMaxBatch(tt.max))
+			res, err := as.BatchGetDeliveries(context.Background(), tt.uID, tt.ids)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AddressService.BatchGetDeliveries() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := make([]string, len(res))
+			for i, r := range res {
+				got[i] = r.Status
+				if r.ID != tt.ids[i] {
+					t.Errorf("AddressService.BatchGetDeliveries() result %d = %s, want %s", i, r.ID, tt.ids[i])
+				}
+				if (r.Status == BatchFound) != (r.Address.ID == own) {
+					t.Errorf("AddressService.BatchGetDeliveries() result %d address = %s", i, r.Address.ID.Hex())
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AddressService.BatchGetDeliveries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
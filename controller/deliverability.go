@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/modular-project/address-service/model"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Reasons an address is not deliverable.
+const (
+	ReasonOutOfRange   = "out_of_range"
+	ReasonZoneExcluded = "zone_excluded"
+	ReasonClosed       = "store_closed"
+)
+
+// ErrInvalidLocation is returned for coordinates out of their range.
+var ErrInvalidLocation = errors.New("invalid coordinates")
+
+// ZoneChecker finds the excluded zone of the tenant containing a point.
+type ZoneChecker interface {
+	ExcludedZone(ctx context.Context, loc []float64) (string, bool, error)
+}
+
+type noZones struct{}
+
+func (noZones) ExcludedZone(context.Context, []float64) (string, bool, error) {
+	return "", false, nil
+}
+
+// WithExcludedZones refuses to deliver to the zones of zc.
+func WithExcludedZones(zc ZoneChecker) Option {
+	return func(as *AddressService) {
+		as.zones = zc
+	}
+}
+
+// Deliverability tells whether an establishment delivers to Location.
+type Deliverability struct {
+	Deliverable bool
+	// EstablishmentID is the active establishment in range Nearest would
+	// assign, the closed one for ReasonClosed
+	EstablishmentID string
+	// Distance in meters to the establishment
+	Distance float64
+	// Reason is empty when deliverable
+	Reason   string
+	Zone     string
+	Location model.Location
+}
+
+// CheckDeliverability tells whether the address a, or the [lng, lat] point
+// loc when set, can be delivered at t without storing anything. The address
+// is geocoded when there is no point.
+func (as AddressService) CheckDeliverability(ctx context.Context, a model.Address, loc []float64, t time.Time) (Deliverability, error) {
+	ctx, span := tracer.Start(ctx, "AddressService.CheckDeliverability")
+	defer span.End()
+	var d Deliverability
+	if loc != nil {
+		if len(loc) != 2 || loc[0] < -180 || loc[0] > 180 || loc[1] < -90 || loc[1] > 90 {
+			return d, fmt.Errorf("%w: %v", ErrInvalidLocation, loc)
+		}
+		d.Location = model.Location{Type: "Point", Coordinates: loc}
+	} else {
+		var err error
+		if d.Location, err = as.gc.GeoCode(ctx, a.String()); err != nil {
+			return d, fmt.Errorf("gc.GeoCode: %w", err)
+		}
+		if d.Location.IsZero() {
+			return d, fmt.Errorf("gc.GeoCode: no location for %q", a.String())
+		}
+	}
+	defer func() {
+		span.SetAttributes(attribute.Bool("deliverable", d.Deliverable), attribute.String("reason", d.Reason))
+	}()
+	zone, ok, err := as.zones.ExcludedZone(ctx, d.Location.Coordinates)
+	if err != nil {
+		return d, fmt.Errorf("zones.ExcludedZone: %w", err)
+	}
+	if ok {
+		d.Reason, d.Zone = ReasonZoneExcluded, zone
+		return d, nil
+	}
+	asg, err := as.assign(ctx, d.Location.Coordinates, model.Availability{OpenAt: t})
+	if errors.Is(err, ErrNoEstablishment) {
+		// in range but closed, or out of range
+		d.Reason = ReasonClosed
+		asg, err = as.assign(ctx, d.Location.Coordinates, model.Availability{})
+	}
+	if errors.Is(err, ErrNoEstablishment) {
+		d.Reason = ReasonOutOfRange
+		return d, nil
+	}
+	if err != nil {
+		return d, err
+	}
+	d.EstablishmentID = asg.EstablishmentID()
+	d.Distance = asg.Scores[0].Distance
+	d.Deliverable = d.Reason == ""
+	return d, nil
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeEstablishments finds the first establishment, open or not, when it is
+// within range.
+type fakeEstablishments struct {
+	AddressStorager
+	e       model.Address
+	inRange bool
+}
+
+func (f fakeEstablishments) Candidates(_ context.Context, loc []float64, av model.Availability, _ int) ([]model.Candidate, error) {
+	if !f.inRange || f.e.Suspended && !av.IncludeSuspended || !av.OpenAt.IsZero() && !f.e.OpenAt(av.OpenAt) {
+		return nil, ErrNoEstablishment
+	}
+	return []model.Candidate{{Address: f.e, Distance: model.Distance(loc, f.e.Location.Coordinates)}}, nil
+}
+
+type fakeZones map[string]bool
+
+func (f fakeZones) ExcludedZone(context.Context, []float64) (string, bool, error) {
+	for z := range f {
+		return z, true, nil
+	}
+	return "", false, nil
+}
+
+func TestAddressService_CheckDeliverability(t *testing.T) {
+	at := time.Date(2022, 9, 12, 15, 0, 0, 0, time.UTC)
+	e := model.Address{
+		ID:       primitive.NewObjectID(),
+		Location: model.Location{Type: "Point", Coordinates: []float64{-103.3474, 20.6767}},
+	}
+	closed := e
+	closed.Hours = &model.OpeningHours{TimeZone: "UTC"}
+	tests := []struct {
+		name      string
+		e         model.Address
+		inRange   bool
+		zones     fakeZones
+		loc       []float64
+		want      string
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "deliverable", e: e, inRange: true, want: "", wantCalls: 1},
+		{name: "point is not geocoded", e: e, inRange: true, loc: []float64{-103.3750, 20.6742}},
+		{name: "out of range", e: e, want: ReasonOutOfRange, wantCalls: 1},
+		{name: "closed", e: closed, inRange: true, want: ReasonClosed, wantCalls: 1},
+		{name: "excluded zone", e: e, inRange: true, zones: fakeZones{"centro": true}, want: ReasonZoneExcluded, wantCalls: 1},
+		{name: "invalid point", loc: []float64{200, 20}, wantErr: ErrInvalidLocation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gc := &fakeGeoCoder{}
+			as := NewAddressService(fakeEstablishments{e: tt.e, inRange: tt.inRange}, nil, gc, WithExcludedZones(tt.zones))
+			got, err := as.CheckDeliverability(context.Background(), model.Address{Street: "Av. Hidalgo 1421"}, tt.loc, at)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AddressService.CheckDeliverability() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Reason != tt.want || got.Deliverable != (tt.want == "") {
+				t.Errorf("AddressService.CheckDeliverability() = %+v, want reason %q", got, tt.want)
+			}
+			if gc.calls != tt.wantCalls {
+				t.Errorf("AddressService.CheckDeliverability() geocoded %d times, want %d", gc.calls, tt.wantCalls)
+			}
+			if tt.want != ReasonOutOfRange && tt.want != ReasonZoneExcluded && (got.EstablishmentID != tt.e.ID.Hex() || got.Distance <= 0) {
+				t.Errorf("AddressService.CheckDeliverability() establishment = %s at %f m", got.EstablishmentID, got.Distance)
+			}
+		})
+	}
+}
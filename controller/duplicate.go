@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/modular-project/address-service/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Policies of CreateDelivery when the user already has the address.
+const (
+	// DuplicateAllow creates the address anyway
+	DuplicateAllow = "allow"
+	// DuplicateReuse returns the id of the existing address
+	DuplicateReuse = "reuse"
+	// DuplicateReject fails with a DuplicateError
+	DuplicateReject = "reject"
+)
+
+// ErrDuplicate is matched by the DuplicateError of a rejected address.
+var ErrDuplicate = errors.New("the user already has the delivery address")
+
+// DuplicateError is returned by CreateDelivery when it rejects a duplicate,
+// Existing is the address of the user it matched.
+type DuplicateError struct {
+	Existing model.Address
+}
+
+func (e DuplicateError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrDuplicate, e.Existing.ID.Hex())
+}
+
+func (e DuplicateError) Is(target error) bool {
+	return target == ErrDuplicate
+}
+
+// ValidDuplicatePolicy reports whether p is a known policy.
+func ValidDuplicatePolicy(p string) bool {
+	return p == DuplicateAllow || p == DuplicateReuse || p == DuplicateReject
+}
+
+// WithDuplicates sets the policy of CreateDelivery when it is not given and
+// the meters within which two geocoded addresses are the same, 0 only
+// compares their fingerprints.
+func WithDuplicates(policy string, distance float64) Option {
+	return func(as *AddressService) {
+		as.dupPolicy = policy
+		as.dupDistance = distance
+	}
+}
+
+// abbreviations of the words of the Mexican addresses, after removing the
+// accents and punctuation.
+var abbreviations = map[string]string{
+	"avenida":         "av",
+	"ave":             "av",
+	"calle":           "c",
+	"calzada":         "calz",
+	"boulevard":       "blvd",
+	"bulevar":         "blvd",
+	"colonia":         "col",
+	"fraccionamiento": "fracc",
+	"general":         "gral",
+	"numero":          "",
+	"num":             "",
+	"no":              "",
+	"interior":        "int",
+	"exterior":        "ext",
+	"norte":           "nte",
+	"oriente":         "ote",
+	"poniente":        "pte",
+}
+
+var unaccented = strings.NewReplacer(
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n",
+)
+
+// normalize lowercases s without accents, punctuation nor abbreviations.
+func normalize(s string) string {
+	s = unaccented.Replace(strings.ToLower(s))
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	out := words[:0]
+	for _, w := range words {
+		if a, ok := abbreviations[w]; ok {
+			w = a
+		}
+		if w != "" {
+			out = append(out, w)
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+// Fingerprint identifies the components of a regardless of their case,
+// accents, punctuation and common abbreviations.
+func Fingerprint(a model.Address) string {
+	parts := []string{a.Street, a.Suburb, a.PostalCode, a.City, a.State, a.Country}
+	for i := range parts {
+		parts[i] = normalize(parts[i])
+	}
+	h := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(h[:16])
+}
+
+// duplicate returns the address of existing matching a, by fingerprint or,
+// when both are geocoded, by distance.
+func (as AddressService) duplicate(a model.Address, existing []model.Address) (model.Address, bool) {
+	fp := Fingerprint(a)
+	for _, e := range existing {
+		if Fingerprint(e) == fp {
+			return e, true
+		}
+	}
+	if as.dupDistance <= 0 || a.Location.IsZero() {
+		return model.Address{}, false
+	}
+	for _, e := range existing {
+		if !e.Location.IsZero() && model.Distance(a.Location.Coordinates, e.Location.Coordinates) <= as.dupDistance {
+			return e, true
+		}
+	}
+	return model.Address{}, false
+}
+
+// reuse returns the existing address e instead of creating d, or rejects d.
+func (as AddressService) reuse(ctx context.Context, d *model.Delivery, e model.Address, policy string) (string, bool, error) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("address.duplicate", policy))
+	if policy == DuplicateReject {
+		return "", false, DuplicateError{Existing: e}
+	}
+	d.Address = e
+	return e.ID.Hex(), true, nil
+}
+
+// existing returns the active addresses of uID to compare with a new one,
+// none when duplicates are allowed. The deleted ones are left out as they
+// can not be reused and will be purged.
+func (as AddressService) existing(ctx context.Context, uID uint64, policy string) ([]model.Address, error) {
+	if policy == DuplicateAllow {
+		return nil, nil
+	}
+	ads, err := as.dst.GetActive(ctx, uID)
+	if err != nil {
+		return nil, fmt.Errorf("dst.GetActive: %w", err)
+	}
+	return ads, nil
+}
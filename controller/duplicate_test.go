@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/modular-project/address-service/model"
+)
+
+func TestFingerprint(t *testing.T) {
+	a := model.Address{Street: "Avenida Hidalgo No. 1421", Suburb: "Colonia Olímpica", PostalCode: "44430", City: "Guadalajara", State: "Jalisco", Country: "México"}
+	tests := []struct {
+		name string
+		b    model.Address
+		want bool
+	}{
+		{
+			name: "case, accents and abbreviations",
+			b:    model.Address{Street: "av hidalgo #1421", Suburb: "col. olimpica", PostalCode: "44430", City: "GUADALAJARA", State: "jalisco", Country: "Mexico"},
+			want: true,
+		}, {
+			name: "another number",
+			b:    model.Address{Street: "Av. Hidalgo 1423", Suburb: "Col. Olímpica", PostalCode: "44430", City: "Guadalajara", State: "Jalisco", Country: "México"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Fingerprint(a) == Fingerprint(tt.b); got != tt.want {
+				t.Errorf("Fingerprint() equal = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddressService_CreateDelivery_duplicate(t *testing.T) {
+	saved := model.Address{Street: "Av. Hidalgo 1421", City: "Guadalajara"}
+	near := model.Location{Type: "Point", Coordinates: []float64{-103.3267, 20.6546}}
+	tests := []struct {
+		name      string
+		existing  model.Address
+		policy    string
+		street    string
+		wantDup   bool
+		wantErr   error
+		wantCalls int
+	}{
+		{name: "allowed", existing: saved, policy: DuplicateAllow, street: "avenida hidalgo 1421", wantCalls: 1},
+		{name: "reused before geocoding", existing: saved, policy: DuplicateReuse, street: "avenida hidalgo 1421", wantDup: true},
+		{name: "rejected", existing: saved, policy: DuplicateReject, street: "avenida hidalgo 1421", wantErr: ErrDuplicate},
+		{name: "reused by distance", existing: model.Address{Street: "Hidalgo", Location: near}, policy: DuplicateReuse, street: "Av. Hidalgo 1421", wantDup: true, wantCalls: 1},
+		{name: "deleted address is not reused", existing: model.Address{Street: saved.Street, City: saved.City, IsDeleted: true}, policy: DuplicateReuse, street: "avenida hidalgo 1421", wantCalls: 1},
+		{name: "deleted address is not rejected", existing: model.Address{Street: saved.Street, City: saved.City, IsDeleted: true}, policy: DuplicateReject, street: "avenida hidalgo 1421", wantCalls: 1},
+		{name: "another address", existing: saved, policy: DuplicateReuse, street: "Av. Juárez 10", wantCalls: 1},
+		{name: "default policy", existing: saved, street: "avenida hidalgo 1421", wantErr: ErrDuplicate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gc := &fakeGeoCoder{}
+			dst := &fakeDeliveryStorage{}
+			id, _ := dst.Create(context.Background(), &model.Delivery{UserID: 1, Address: tt.existing})
+			as := NewAddressService(nil, dst, gc, WithDuplicates(DuplicateReject, 50))
+			d := model.Delivery{UserID: 1, Address: model.Address{Street: tt.street, City: "Guadalajara"}}
+			got, dup, err := as.CreateDelivery(context.Background(), &d, tt.policy)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AddressService.CreateDelivery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if dup != tt.wantDup || (dup && got != id) {
+				t.Errorf("AddressService.CreateDelivery() = %s, %t, want %s, %t", got, dup, id, tt.wantDup)
+			}
+			if gc.calls != tt.wantCalls {
+				t.Errorf("AddressService.CreateDelivery() geocoded %d times, want %d", gc.calls, tt.wantCalls)
+			}
+		})
+	}
+}
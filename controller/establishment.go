@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/modular-project/address-service/audit"
+	"github.com/modular-project/address-service/metrics"
+	"github.com/modular-project/address-service/model"
+)
+
+// ErrInvalidInfo is returned for an establishment description that can not
+// be stored, e.g. with an unknown time zone or an empty range.
+var ErrInvalidInfo = errors.New("invalid establishment info")
+
+// maxNameLength bounds the name and phone of an establishment.
+const maxNameLength = 200
+
+func validInfo(info model.EstablishmentInfo) error {
+	if len(info.Name) > maxNameLength || len(info.Phone) > maxNameLength {
+		return fmt.Errorf("%w: name and phone must have at most %d bytes", ErrInvalidInfo, maxNameLength)
+	}
+	if info.Capacity < 0 {
+		return fmt.Errorf("%w: capacity must not be negative", ErrInvalidInfo)
+	}
+	if info.Hours == nil {
+		return nil
+	}
+	if err := info.Hours.Validate(); err != nil {
+		return fmt.Errorf("%w: hours: %s", ErrInvalidInfo, err)
+	}
+	return nil
+}
+
+// UpdateEstablishmentInfo replaces the name, phone, hours, status, priority
+// and capacity of the establishment aID, it returns the establishment after
+// the change.
+func (as AddressService) UpdateEstablishmentInfo(ctx context.Context, aID string, info model.EstablishmentInfo) (model.Address, error) {
+	ctx, span := tracer.Start(ctx, "AddressService.UpdateEstablishmentInfo")
+	defer span.End()
+	if err := validInfo(info); err != nil {
+		return model.Address{}, err
+	}
+	var after model.Address
+	err := as.ob.Atomically(ctx, func(ctx context.Context) ([]model.Event, error) {
+		before, err := as.ast.SetInfo(ctx, aID, info)
+		if err != nil {
+			return nil, fmt.Errorf("ast.SetInfo: %w", err)
+		}
+		after = before
+		after.Name, after.Phone, after.Hours, after.Suspended = info.Name, info.Phone, info.Hours, info.Suspended
+		after.Priority, after.Capacity = info.Priority, info.Capacity
+		e := audit.NewEntry(ctx, model.AuditUpdate, metrics.Establishment, aID, 0, before, after)
+		if len(e.Changes) == 0 {
+			return nil, nil
+		}
+		if err := as.au.Record(ctx, e); err != nil {
+			return nil, fmt.Errorf("au.Record: %w", err)
+		}
+		return []model.Event{model.NewEvent(model.EstablishmentUpdated, aID, 0)}, nil
+	})
+	if err != nil {
+		return model.Address{}, err
+	}
+	return after, nil
+}
+
+// ReportLoad stores the orders the establishment aID is preparing now, used
+// by WeightedStrategy. It is not audited as it changes every few minutes,
+// it returns 0 when there is no such establishment.
+func (as AddressService) ReportLoad(ctx context.Context, aID string, active int) (int64, error) {
+	ctx, span := tracer.Start(ctx, "AddressService.ReportLoad")
+	defer span.End()
+	n, err := as.ast.ReportLoad(ctx, aID, active, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("ast.ReportLoad: %w", err)
+	}
+	return n, nil
+}
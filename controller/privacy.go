@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/modular-project/address-service/audit"
+	"github.com/modular-project/address-service/metrics"
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// erasePrecision is the number of decimals kept of the coordinates of an
+// anonymized address, about 1 km.
+const erasePrecision = 2
+
+// Modes of EraseUser.
+const (
+	EraseAnonymize = "anonymize"
+	EraseDelete    = "delete"
+)
+
+// UserData is everything stored about the addresses of a user.
+type UserData struct {
+	UserID     uint64
+	ExportedAt time.Time
+	// Deliveries include the deleted ones
+	Deliveries []model.Delivery
+}
+
+type PrivacyStorager interface {
+	Each(context.Context, model.Filter, func(model.Delivery) error) error
+	EraseUser(ctx context.Context, uID uint64, anonymize bool, precision int) (int64, error)
+}
+
+// AuditRedactor removes the values of the audited changes of a user.
+type AuditRedactor interface {
+	RedactUser(context.Context, uint64) (int64, error)
+}
+
+// ChangeEraser removes the location changes recorded by the backfill jobs
+// of some addresses.
+type ChangeEraser interface {
+	EraseChanges(context.Context, []primitive.ObjectID) (int64, error)
+}
+
+// PrivacyService answers the requests of users about their personal data.
+type PrivacyService struct {
+	st PrivacyStorager
+	ob Outbox
+	au Auditor
+	ar AuditRedactor
+	ce ChangeEraser
+}
+
+func NewPrivacyService(st PrivacyStorager, ob Outbox, au Auditor, ar AuditRedactor, ce ChangeEraser) PrivacyService {
+	return PrivacyService{st: st, ob: ob, au: au, ar: ar, ce: ce}
+}
+
+func (ps PrivacyService) deliveries(ctx context.Context, uID uint64) ([]model.Delivery, error) {
+	var ds []model.Delivery
+	err := ps.st.Each(ctx, model.Filter{UserID: uID, IncludeDeleted: true}, func(d model.Delivery) error {
+		ds = append(ds, d)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("st.Each: %w", err)
+	}
+	return ds, nil
+}
+
+// ExportUserData returns every delivery address of uID, the deleted ones
+// too.
+func (ps PrivacyService) ExportUserData(ctx context.Context, uID uint64) (UserData, error) {
+	ctx, span := tracer.Start(ctx, "PrivacyService.ExportUserData")
+	defer span.End()
+	ds, err := ps.deliveries(ctx, uID)
+	if err != nil {
+		return UserData{}, err
+	}
+	return UserData{UserID: uID, ExportedAt: time.Now(), Deliveries: ds}, nil
+}
+
+// EraseUser deletes or anonymizes every delivery address of uID, redacts
+// the audited changes of the user and removes the backfill changes of the
+// addresses, it returns how many addresses were erased. The erasure is
+// audited without changes.
+func (ps PrivacyService) EraseUser(ctx context.Context, uID uint64, mode string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "PrivacyService.EraseUser")
+	defer span.End()
+	span.SetAttributes(attribute.String("erase.mode", mode))
+	if mode != EraseAnonymize && mode != EraseDelete {
+		return 0, fmt.Errorf("unknown erase mode %q", mode)
+	}
+	var n int64
+	err := ps.ob.Atomically(ctx, func(ctx context.Context) ([]model.Event, error) {
+		ds, err := ps.deliveries(ctx, uID)
+		if err != nil {
+			return nil, err
+		}
+		if n, err = ps.st.EraseUser(ctx, uID, mode == EraseAnonymize, erasePrecision); err != nil {
+			return nil, fmt.Errorf("st.EraseUser: %w", err)
+		}
+		if _, err := ps.ar.RedactUser(ctx, uID); err != nil {
+			return nil, fmt.Errorf("ar.RedactUser: %w", err)
+		}
+		ids := make([]primitive.ObjectID, len(ds))
+		for i := range ds {
+			ids[i] = ds[i].ID
+		}
+		if _, err := ps.ce.EraseChanges(ctx, ids); err != nil {
+			return nil, fmt.Errorf("ce.EraseChanges: %w", err)
+		}
+		for _, d := range ds {
+			e := audit.NewEntry(ctx, model.AuditErase, metrics.Delivery, d.ID.Hex(), uID, model.Address{}, model.Address{})
+			if err := ps.au.Record(ctx, e); err != nil {
+				return nil, fmt.Errorf("au.Record: %w", err)
+			}
+		}
+		return []model.Event{model.NewEvent(model.UserErased, strconv.FormatUint(uID, 10), uID)}, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if mode == EraseDelete {
+		metrics.AddressDeleted(metrics.Delivery, n)
+	}
+	return n, nil
+}
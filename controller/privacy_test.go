@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type fakePrivacyStorage struct {
+	ds        []model.Delivery
+	anonymize bool
+	redacted  uint64
+}
+
+func (f *fakePrivacyStorage) Each(_ context.Context, q model.Filter, fn func(model.Delivery) error) error {
+	for _, d := range f.ds {
+		if d.UserID == q.UserID && (q.IncludeDeleted || !d.IsDeleted) {
+			if err := fn(d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *fakePrivacyStorage) EraseUser(_ context.Context, uID uint64, anonymize bool, _ int) (int64, error) {
+	f.anonymize = anonymize
+	var n int64
+	for _, d := range f.ds {
+		if d.UserID == uID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (f *fakePrivacyStorage) RedactUser(_ context.Context, uID uint64) (int64, error) {
+	f.redacted = uID
+	return 0, nil
+}
+
+type fakeChangeEraser struct {
+	ids []primitive.ObjectID
+}
+
+func (f *fakeChangeEraser) EraseChanges(_ context.Context, ids []primitive.ObjectID) (int64, error) {
+	f.ids = append(f.ids, ids...)
+	return int64(len(ids)), nil
+}
+
+func TestPrivacyService_EraseUser(t *testing.T) {
+	ds := []model.Delivery{
+		{UserID: 1, Address: model.Address{ID: primitive.NewObjectID(), Street: "Av. Vallarta 1"}},
+		{UserID: 1, Address: model.Address{ID: primitive.NewObjectID(), Street: "Calle 5", IsDeleted: true}},
+		{UserID: 2, Address: model.Address{ID: primitive.NewObjectID()}},
+	}
+	tests := []struct {
+		name    string
+		mode    string
+		want    int64
+		wantErr bool
+	}{
+		{name: "anonymize", mode: EraseAnonymize, want: 2},
+		{name: "delete", mode: EraseDelete, want: 2},
+		{name: "unknown mode", mode: "shred", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &fakePrivacyStorage{ds: ds}
+			au := &fakeAuditor{}
+			ce := &fakeChangeEraser{}
+			ps := NewPrivacyService(st, NoOutbox, au, st, ce)
+			got, err := ps.EraseUser(context.Background(), 1, tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PrivacyService.EraseUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got != tt.want || st.anonymize != (tt.mode == EraseAnonymize) || st.redacted != 1 {
+				t.Errorf("PrivacyService.EraseUser() = %d, anonymize %t, redacted %d", got, st.anonymize, st.redacted)
+			}
+			if len(ce.ids) != 2 || ce.ids[0] != ds[0].ID || ce.ids[1] != ds[1].ID {
+				t.Errorf("PrivacyService.EraseUser() erased the backfill changes of %v", ce.ids)
+			}
+			if len(au.es) != 2 {
+				t.Fatalf("PrivacyService.EraseUser() audited %d entries, want 2", len(au.es))
+			}
+			for _, e := range au.es {
+				if e.Op != model.AuditErase || len(e.Changes) != 0 {
+					t.Errorf("PrivacyService.EraseUser() audited %+v", e)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/modular-project/address-service/metrics"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// RetentionStorager holds the deleted delivery addresses of every tenant.
+type RetentionStorager interface {
+	StampDeleted(ctx context.Context, at time.Time) (int64, error)
+	Expired(ctx context.Context, before time.Time, limit int64) ([]primitive.ObjectID, error)
+	Purge(ctx context.Context, ids []primitive.ObjectID, before time.Time) (int64, error)
+	AnonymizeDeleted(ctx context.Context, ids []primitive.ObjectID, before time.Time, precision int) (int64, error)
+}
+
+// Referencer tells which delivery addresses are still referenced by other
+// services, e.g. by their orders.
+type Referencer interface {
+	Referenced(context.Context, []primitive.ObjectID) (map[primitive.ObjectID]bool, error)
+}
+
+// HistoryRedactor removes the values of the audited changes of some
+// addresses of every tenant.
+type HistoryRedactor interface {
+	RedactAddresses(context.Context, []primitive.ObjectID) (int64, error)
+}
+
+type noReferences struct{}
+
+func (noReferences) Referenced(context.Context, []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	return nil, nil
+}
+
+// NoReferences purges every expired address.
+var NoReferences Referencer = noReferences{}
+
+type PurgeConfig struct {
+	// Retention of a deleted address before it is purged
+	Retention time.Duration
+	// Interval between two runs
+	Interval time.Duration
+	// BatchSize of the addresses purged at once
+	BatchSize int
+}
+
+// PurgeResult counts the addresses of a run.
+type PurgeResult struct {
+	Deleted    int64
+	Anonymized int64
+}
+
+// PurgeWorker removes the delivery addresses deleted longer than the
+// retention ago, the referenced ones are anonymized instead. Either way
+// their backfill changes are erased and their audit history redacted.
+// Replicas can run it at the same time, an address is only purged once.
+type PurgeWorker struct {
+	st   RetentionStorager
+	refs Referencer
+	ce   ChangeEraser
+	hr   HistoryRedactor
+	c    PurgeConfig
+	l    *zap.Logger
+	now  func() time.Time
+}
+
+func NewPurgeWorker(st RetentionStorager, refs Referencer, ce ChangeEraser, hr HistoryRedactor, l *zap.Logger, c PurgeConfig) *PurgeWorker {
+	if c.BatchSize < 1 {
+		c.BatchSize = 1
+	}
+	return &PurgeWorker{st: st, refs: refs, ce: ce, hr: hr, c: c, l: l, now: time.Now}
+}
+
+// Run purges every interval until ctx is done.
+func (w *PurgeWorker) Run(ctx context.Context) {
+	t := time.NewTimer(0)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		start := time.Now()
+		r, err := w.Purge(ctx)
+		metrics.PurgeRun(time.Since(start), err)
+		if err != nil && ctx.Err() == nil {
+			w.l.Error("purge deleted delivery addresses", zap.Error(err))
+		}
+		if r.Deleted+r.Anonymized != 0 {
+			w.l.Info("purged deleted delivery addresses",
+				zap.Int64("deleted", r.Deleted), zap.Int64("anonymized", r.Anonymized))
+		}
+		t.Reset(w.c.Interval)
+	}
+}
+
+// Purge purges the expired addresses in batches until there is none left.
+func (w *PurgeWorker) Purge(ctx context.Context) (PurgeResult, error) {
+	ctx, span := tracer.Start(ctx, "PurgeWorker.Purge")
+	defer span.End()
+	var r PurgeResult
+	now := w.now()
+	// the addresses deleted before deleted_at was stored expire a
+	// retention from now
+	if _, err := w.st.StampDeleted(ctx, now); err != nil {
+		return r, fmt.Errorf("st.StampDeleted: %w", err)
+	}
+	before := now.Add(-w.c.Retention)
+	for {
+		ids, err := w.st.Expired(ctx, before, int64(w.c.BatchSize))
+		if err != nil {
+			return r, fmt.Errorf("st.Expired: %w", err)
+		}
+		if len(ids) == 0 {
+			break
+		}
+		d, a, err := w.batch(ctx, ids, before)
+		r.Deleted += d
+		r.Anonymized += a
+		if err != nil {
+			return r, err
+		}
+		if len(ids) < w.c.BatchSize {
+			break
+		}
+	}
+	span.SetAttributes(attribute.Int64("purge.deleted", r.Deleted), attribute.Int64("purge.anonymized", r.Anonymized))
+	return r, nil
+}
+
+// batch erases the backfill changes and redacts the history of ids, then
+// deletes the ones not referenced and anonymizes the others.
+func (w *PurgeWorker) batch(ctx context.Context, ids []primitive.ObjectID, before time.Time) (int64, int64, error) {
+	refs, err := w.refs.Referenced(ctx, ids)
+	if err != nil {
+		return 0, 0, fmt.Errorf("refs.Referenced: %w", err)
+	}
+	// before the purge, an address already purged is not expired anymore
+	// and would not be retried
+	if _, err := w.ce.EraseChanges(ctx, ids); err != nil {
+		return 0, 0, fmt.Errorf("ce.EraseChanges: %w", err)
+	}
+	if _, err := w.hr.RedactAddresses(ctx, ids); err != nil {
+		return 0, 0, fmt.Errorf("hr.RedactAddresses: %w", err)
+	}
+	var del, anon []primitive.ObjectID
+	for _, id := range ids {
+		if refs[id] {
+			anon = append(anon, id)
+		} else {
+			del = append(del, id)
+		}
+	}
+	var d, a int64
+	if len(del) != 0 {
+		if d, err = w.st.Purge(ctx, del, before); err != nil {
+			return 0, 0, fmt.Errorf("st.Purge: %w", err)
+		}
+		metrics.AddressPurged(metrics.PurgeDeleted, d)
+	}
+	if len(anon) != 0 {
+		if a, err = w.st.AnonymizeDeleted(ctx, anon, before, erasePrecision); err != nil {
+			return d, 0, fmt.Errorf("st.AnonymizeDeleted: %w", err)
+		}
+		metrics.AddressPurged(metrics.PurgeAnonymized, a)
+	}
+	return d, a, nil
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// fakeRetention holds deleted addresses by deletion time.
+type fakeRetention struct {
+	deleted    map[primitive.ObjectID]time.Time
+	anonymized map[primitive.ObjectID]bool
+}
+
+func (f *fakeRetention) StampDeleted(context.Context, time.Time) (int64, error) { return 0, nil }
+
+func (f *fakeRetention) Expired(_ context.Context, before time.Time, limit int64) ([]primitive.ObjectID, error) {
+	var ids []primitive.ObjectID
+	for id, at := range f.deleted {
+		if at.Before(before) && !f.anonymized[id] && int64(len(ids)) < limit {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (f *fakeRetention) Purge(_ context.Context, ids []primitive.ObjectID, _ time.Time) (int64, error) {
+	for _, id := range ids {
+		delete(f.deleted, id)
+	}
+	return int64(len(ids)), nil
+}
+
+func (f *fakeRetention) AnonymizeDeleted(_ context.Context, ids []primitive.ObjectID, _ time.Time, _ int) (int64, error) {
+	for _, id := range ids {
+		f.anonymized[id] = true
+	}
+	return int64(len(ids)), nil
+}
+
+type fakeReferencer struct {
+	refs map[primitive.ObjectID]bool
+	err  error
+}
+
+func (f fakeReferencer) Referenced(context.Context, []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	return f.refs, f.err
+}
+
+// fakeErased records the addresses whose backfill changes were erased and
+// history redacted.
+type fakeErased struct {
+	changes, history map[primitive.ObjectID]bool
+}
+
+func (f fakeErased) EraseChanges(_ context.Context, ids []primitive.ObjectID) (int64, error) {
+	for _, id := range ids {
+		f.changes[id] = true
+	}
+	return int64(len(ids)), nil
+}
+
+func (f fakeErased) RedactAddresses(_ context.Context, ids []primitive.ObjectID) (int64, error) {
+	for _, id := range ids {
+		f.history[id] = true
+	}
+	return int64(len(ids)), nil
+}
+
+func TestPurgeWorker_Purge(t *testing.T) {
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	old, recent, ref := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	tests := []struct {
+		name    string
+		refs    fakeReferencer
+		want    PurgeResult
+		wantErr bool
+	}{
+		{name: "no references", refs: fakeReferencer{}, want: PurgeResult{Deleted: 2}},
+		{name: "referenced are anonymized", refs: fakeReferencer{refs: map[primitive.ObjectID]bool{ref: true}}, want: PurgeResult{Deleted: 1, Anonymized: 1}},
+		{name: "references unavailable", refs: fakeReferencer{err: errors.New("timeout")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &fakeRetention{
+				deleted: map[primitive.ObjectID]time.Time{
+					old:    now.Add(-48 * time.Hour),
+					ref:    now.Add(-48 * time.Hour),
+					recent: now.Add(-time.Hour),
+				},
+				anonymized: map[primitive.ObjectID]bool{},
+			}
+			er := fakeErased{changes: map[primitive.ObjectID]bool{}, history: map[primitive.ObjectID]bool{}}
+			// a batch of one runs a batch per address
+			w := NewPurgeWorker(st, tt.refs, er, er, zap.NewNop(), PurgeConfig{Retention: 24 * time.Hour, BatchSize: 1})
+			w.now = func() time.Time { return now }
+			got, err := w.Purge(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PurgeWorker.Purge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("PurgeWorker.Purge() = %+v, want %+v", got, tt.want)
+			}
+			if _, ok := st.deleted[recent]; !ok {
+				t.Errorf("PurgeWorker.Purge() purged an address within the retention")
+			}
+			if er.changes[recent] || er.history[recent] {
+				t.Errorf("PurgeWorker.Purge() erased an address within the retention")
+			}
+			if !tt.wantErr && !(er.changes[old] && er.history[old] && er.changes[ref] && er.history[ref]) {
+				t.Errorf("PurgeWorker.Purge() erased the changes of %v and history of %v", er.changes, er.history)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/modular-project/address-service/model"
+	"github.com/modular-project/address-service/tenant"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// maxRetryDelay caps the backoff between two attempts of an address.
+const maxRetryDelay = time.Hour
+
+// PendingStorager is a queue of addresses waiting for their location.
+type PendingStorager interface {
+	ClaimPending(ctx context.Context, lease time.Duration) (model.Address, bool, error)
+	Geocoded(ctx context.Context, id primitive.ObjectID, loc model.Location) error
+	RetryGeocode(ctx context.Context, id primitive.ObjectID, attempts int, at time.Time) error
+	GeocodeFailed(ctx context.Context, id primitive.ObjectID, attempts int) error
+}
+
+type WorkerConfig struct {
+	// Workers geocoding at the same time
+	Workers int
+	// PollInterval between two claims when the queues are empty, also the
+	// first retry delay, doubled after each failed attempt
+	PollInterval time.Duration
+	// MaxAttempts before an address is marked as failed
+	MaxAttempts int
+	// Lease hides a claimed address from the other workers and replicas
+	Lease time.Duration
+}
+
+// GeocodeWorker finds the location of the pending addresses of its queues.
+type GeocodeWorker struct {
+	gc     GeoCoder
+	ob     Outbox
+	queues []PendingStorager
+	c      WorkerConfig
+	l      *zap.Logger
+	now    func() time.Time
+}
+
+func NewGeocodeWorker(gc GeoCoder, ob Outbox, l *zap.Logger, c WorkerConfig, queues ...PendingStorager) *GeocodeWorker {
+	if c.Workers < 1 {
+		c.Workers = 1
+	}
+	return &GeocodeWorker{gc: gc, ob: ob, queues: queues, c: c, l: l, now: time.Now}
+}
+
+// Run starts the workers and blocks until ctx is done and they return.
+func (w *GeocodeWorker) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < w.c.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *GeocodeWorker) loop(ctx context.Context) {
+	t := time.NewTimer(0)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		// keep draining while there is work, wait otherwise
+		busy := false
+		for _, q := range w.queues {
+			if ctx.Err() != nil {
+				return
+			}
+			ok, err := w.Process(ctx, q)
+			if err != nil {
+				w.l.Error("geocode pending address", zap.Error(err))
+			}
+			busy = busy || ok
+		}
+		if busy {
+			t.Reset(0)
+		} else {
+			t.Reset(w.c.PollInterval)
+		}
+	}
+}
+
+// Process geocodes the next pending address of q, it returns false when
+// there is none.
+func (w *GeocodeWorker) Process(ctx context.Context, q PendingStorager) (bool, error) {
+	a, ok, err := q.ClaimPending(ctx, w.c.Lease)
+	if err != nil || !ok {
+		return false, err
+	}
+	ctx, span := tracer.Start(ctx, "GeocodeWorker.Process")
+	defer span.End()
+	// the queues hold the addresses of every tenant, the events belong to
+	// the one of a
+	ctx = tenant.NewContext(ctx, a.TenantID)
+	loc, err := w.gc.GeoCode(ctx, a.String())
+	if err == nil {
+		return true, w.ob.Atomically(ctx, func(ctx context.Context) ([]model.Event, error) {
+			if err := q.Geocoded(ctx, a.ID, loc); err != nil {
+				return nil, err
+			}
+			return []model.Event{model.NewEvent(model.AddressGeocoded, a.ID.Hex(), 0)}, nil
+		})
+	}
+	attempts := a.GeocodeAttempts
+	// an unavailable geocoder never tried, the attempt is not counted
+	if !errors.Is(err, ErrGeoCoderUnavailable) {
+		attempts++
+	}
+	if attempts >= w.c.MaxAttempts {
+		w.l.Warn("geocoding failed", zap.String("id", a.ID.Hex()), zap.Int("attempts", attempts), zap.Error(err))
+		return true, q.GeocodeFailed(ctx, a.ID, attempts)
+	}
+	return true, q.RetryGeocode(ctx, a.ID, attempts, w.now().Add(w.retryDelay(attempts)))
+}
+
+func (w *GeocodeWorker) retryDelay(attempts int) time.Duration {
+	d := w.c.PollInterval
+	for i := 0; i < attempts && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	if d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	return d
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type fakeQueue struct {
+	pending []model.Address
+	result  string
+}
+
+func (f *fakeQueue) ClaimPending(context.Context, time.Duration) (model.Address, bool, error) {
+	if len(f.pending) == 0 {
+		return model.Address{}, false, nil
+	}
+	a := f.pending[0]
+	f.pending = f.pending[1:]
+	return a, true, nil
+}
+
+func (f *fakeQueue) Geocoded(context.Context, primitive.ObjectID, model.Location) error {
+	f.result = model.GeocodeDone
+	return nil
+}
+
+func (f *fakeQueue) RetryGeocode(_ context.Context, _ primitive.ObjectID, attempts int, _ time.Time) error {
+	f.result = fmt.Sprintf("retry %d", attempts)
+	return nil
+}
+
+func (f *fakeQueue) GeocodeFailed(context.Context, primitive.ObjectID, int) error {
+	f.result = model.GeocodeFailed
+	return nil
+}
+
+func TestGeocodeWorker_Process(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		err      error
+		want     string
+	}{
+		{name: "geocoded", want: model.GeocodeDone},
+		{name: "retried", attempts: 1, err: errors.New("timeout"), want: "retry 2"},
+		{name: "unavailable does not count", attempts: 1, err: fmt.Errorf("%w: circuit open", ErrGeoCoderUnavailable), want: "retry 1"},
+		{name: "failed after max attempts", attempts: 2, err: errors.New("timeout"), want: model.GeocodeFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQueue{pending: []model.Address{{GeocodeStatus: model.GeocodePending, GeocodeAttempts: tt.attempts}}}
+			w := NewGeocodeWorker(&fakeGeoCoder{err: tt.err}, NoOutbox, zap.NewNop(), WorkerConfig{PollInterval: time.Second, MaxAttempts: 3}, q)
+			ok, err := w.Process(context.Background(), q)
+			if err != nil || !ok {
+				t.Fatalf("GeocodeWorker.Process() = %v, %v", ok, err)
+			}
+			if q.result != tt.want {
+				t.Errorf("GeocodeWorker.Process() result = %q, want %q", q.result, tt.want)
+			}
+			if ok, _ := w.Process(context.Background(), q); ok {
+				t.Errorf("GeocodeWorker.Process() on an empty queue = true")
+			}
+		})
+	}
+}
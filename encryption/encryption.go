@@ -0,0 +1,188 @@
+// Package encryption encrypts the fields holding personal data before they
+// are stored. A randomized value has its own data key, encrypted with the
+// key of the provider. A deterministic value is the same for the same
+// plaintext and key, so that it can be looked up by equality.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Prefixes of the encrypted values, followed by the key id and the parts
+// in base64 separated by ':'.
+const (
+	randomized    = "enc:v1:"
+	deterministic = "det:v1:"
+)
+
+var b64 = base64.RawURLEncoding
+
+// ErrMalformed is returned when decrypting a value that was altered.
+var ErrMalformed = errors.New("malformed encrypted value")
+
+func validKeyID(id string) bool {
+	return id != "" && !strings.Contains(id, ":")
+}
+
+// Encryptor encrypts the values of a field, the field name is authenticated
+// with them so that they can not be moved to another field.
+type Encryptor struct {
+	kp KeyProvider
+}
+
+func New(kp KeyProvider) Encryptor {
+	return Encryptor{kp: kp}
+}
+
+// CurrentKeyID is the id of the key of the new values.
+func (e Encryptor) CurrentKeyID() string {
+	return e.kp.Current().ID
+}
+
+func seal(key, nonce, plain, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if nonce == nil {
+		nonce = make([]byte, gcm.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+	}
+	return gcm.Seal(nonce, nonce, plain, aad), nil
+}
+
+func open(key, sealed, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrMalformed
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], aad)
+}
+
+func derive(k Key, purpose string) []byte {
+	m := hmac.New(sha256.New, k.Material)
+	m.Write([]byte(purpose))
+	return m.Sum(nil)
+}
+
+// Encrypt returns plain encrypted with a new data key, empty stays empty.
+func (e Encryptor) Encrypt(field, plain string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+	k := e.kp.Current()
+	dek := make([]byte, KeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", fmt.Errorf("data key: %w", err)
+	}
+	wrapped, err := seal(k.Material, nil, dek, []byte(k.ID))
+	if err != nil {
+		return "", fmt.Errorf("wrap data key: %w", err)
+	}
+	ct, err := seal(dek, nil, []byte(plain), []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("encrypt: %w", err)
+	}
+	return randomized + k.ID + ":" + b64.EncodeToString(wrapped) + ":" + b64.EncodeToString(ct), nil
+}
+
+func (e Encryptor) deterministic(k Key, field, plain string) (string, error) {
+	// the nonce depends on the plaintext only, like in SIV
+	m := hmac.New(sha256.New, derive(k, "nonce"))
+	m.Write([]byte(field))
+	m.Write([]byte{0})
+	m.Write([]byte(plain))
+	ct, err := seal(derive(k, "deterministic"), m.Sum(nil)[:12], []byte(plain), []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("encrypt: %w", err)
+	}
+	return deterministic + k.ID + ":" + b64.EncodeToString(ct), nil
+}
+
+// EncryptDeterministic returns plain encrypted so that the same plaintext
+// of the same field gives the same value, empty stays empty.
+func (e Encryptor) EncryptDeterministic(field, plain string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+	return e.deterministic(e.kp.Current(), field, plain)
+}
+
+// Lookup returns the deterministic values of plain with every key, the
+// stored value is one of them whatever key encrypted it.
+func (e Encryptor) Lookup(field, plain string) ([]string, error) {
+	ks := e.kp.Keys()
+	vs := make([]string, len(ks))
+	for i, k := range ks {
+		var err error
+		if vs[i], err = e.deterministic(k, field, plain); err != nil {
+			return nil, err
+		}
+	}
+	return vs, nil
+}
+
+// Decrypt returns the plaintext of v, a value that is not encrypted, e.g.
+// stored before the encryption, is returned as is.
+func (e Encryptor) Decrypt(field, v string) (string, error) {
+	var parts []string
+	switch {
+	case strings.HasPrefix(v, randomized):
+		parts = strings.Split(strings.TrimPrefix(v, randomized), ":")
+		if len(parts) != 3 {
+			return "", ErrMalformed
+		}
+	case strings.HasPrefix(v, deterministic):
+		parts = strings.Split(strings.TrimPrefix(v, deterministic), ":")
+		if len(parts) != 2 {
+			return "", ErrMalformed
+		}
+	default:
+		return v, nil
+	}
+	k, err := e.kp.Key(parts[0])
+	if err != nil {
+		return "", err
+	}
+	raw := make([][]byte, len(parts)-1)
+	for i, p := range parts[1:] {
+		if raw[i], err = b64.DecodeString(p); err != nil {
+			return "", ErrMalformed
+		}
+	}
+	var plain []byte
+	if len(raw) == 1 {
+		plain, err = open(derive(k, "deterministic"), raw[0], []byte(field))
+	} else {
+		var dek []byte
+		if dek, err = open(k.Material, raw[0], []byte(k.ID)); err != nil {
+			return "", fmt.Errorf("unwrap data key: %w", err)
+		}
+		plain, err = open(dek, raw[1], []byte(field))
+	}
+	if err != nil {
+		return "", fmt.Errorf("decrypt: %w", err)
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,131 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func keyProvider(t *testing.T, current string, ids ...string) FileKeyProvider {
+	t.Helper()
+	var keys []string
+	for i, id := range ids {
+		m := base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(rune('a'+i)), KeySize)))
+		keys = append(keys, `"`+id+`":"`+m+`"`)
+	}
+	name := filepath.Join(t.TempDir(), "keys.json")
+	doc := `{"current":"` + current + `","keys":{` + strings.Join(keys, ",") + `}}`
+	if err := os.WriteFile(name, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	kp, err := NewFileKeyProvider(name)
+	if err != nil {
+		t.Fatalf("NewFileKeyProvider() error = %v", err)
+	}
+	return kp
+}
+
+func TestEncryptor_Decrypt(t *testing.T) {
+	old := New(keyProvider(t, "k1", "k1"))
+	rotated := New(keyProvider(t, "k2", "k1", "k2"))
+	enc := func(e Encryptor, det bool, field, plain string) string {
+		f := e.Encrypt
+		if det {
+			f = e.EncryptDeterministic
+		}
+		v, err := f(field, plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		name    string
+		e       Encryptor
+		field   string
+		v       string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "randomized",
+			e:     old,
+			field: "street",
+			v:     enc(old, false, "street", "Av. Vallarta 1"),
+			want:  "Av. Vallarta 1",
+		}, {
+			name:  "deterministic",
+			e:     old,
+			field: "pc",
+			v:     enc(old, true, "pc", "44100"),
+			want:  "44100",
+		}, {
+			name:  "old key after rotation",
+			e:     rotated,
+			field: "street",
+			v:     enc(old, false, "street", "Av. Vallarta 1"),
+			want:  "Av. Vallarta 1",
+		}, {
+			name:  "plaintext stored before the encryption",
+			e:     old,
+			field: "street",
+			v:     "Av. Vallarta 1",
+			want:  "Av. Vallarta 1",
+		}, {
+			name:    "moved to another field",
+			e:       old,
+			field:   "suburb",
+			v:       enc(old, false, "street", "Av. Vallarta 1"),
+			wantErr: true,
+		}, {
+			name:    "unknown key",
+			e:       old,
+			field:   "street",
+			v:       enc(rotated, false, "street", "Av. Vallarta 1"),
+			wantErr: true,
+		}, {
+			name:    "malformed",
+			e:       old,
+			field:   "street",
+			v:       "enc:v1:k1:AAAA",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.e.Decrypt(tt.field, tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Encryptor.Decrypt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Encryptor.Decrypt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncryptor_Lookup(t *testing.T) {
+	old := New(keyProvider(t, "k1", "k1"))
+	rotated := New(keyProvider(t, "k2", "k1", "k2"))
+	a, _ := old.EncryptDeterministic("pc", "44100")
+	b, _ := old.EncryptDeterministic("pc", "44100")
+	r1, _ := old.Encrypt("street", "Av. Vallarta 1")
+	r2, _ := old.Encrypt("street", "Av. Vallarta 1")
+	if a != b || r1 == r2 {
+		t.Fatalf("deterministic values differ or randomized ones are equal")
+	}
+	c, _ := rotated.EncryptDeterministic("pc", "44100")
+	vs, err := rotated.Lookup("pc", "44100")
+	if err != nil {
+		t.Fatalf("Encryptor.Lookup() error = %v", err)
+	}
+	if len(vs) != 2 || vs[0] != a || vs[1] != c {
+		t.Errorf("Encryptor.Lookup() = %v, want [%s %s]", vs, a, c)
+	}
+	if _, err := old.Decrypt("pc", c); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Encryptor.Decrypt() error = %v, want ErrUnknownKey", err)
+	}
+}
@@ -0,0 +1,99 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// KeySize is the size of the keys, AES-256.
+const KeySize = 32
+
+// ErrUnknownKey is returned for a value encrypted with a key the provider
+// does not have.
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// Key encrypts the data keys of the values, it never leaves the service.
+type Key struct {
+	ID       string
+	Material []byte
+}
+
+// KeyProvider gives the keys, e.g. read from a file or unwrapped by a KMS.
+type KeyProvider interface {
+	// Current is the key encrypting the new values
+	Current() Key
+	// Key returns the key id to decrypt a value
+	Key(id string) (Key, error)
+	// Keys returns every key, the deterministic lookups try them all
+	Keys() []Key
+}
+
+type keyFile struct {
+	Current string            `json:"current"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// FileKeyProvider reads the keys from a JSON file, meant for development:
+//
+//	{"current": "2024-01", "keys": {"2024-01": "<32 bytes in base64>"}}
+//
+// Rotating adds a key and makes it current, the old one must stay until
+// the reencrypt command has run.
+type FileKeyProvider struct {
+	current string
+	keys    map[string]Key
+}
+
+func NewFileKeyProvider(name string) (FileKeyProvider, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return FileKeyProvider{}, fmt.Errorf("read key file: %w", err)
+	}
+	var kf keyFile
+	if err := json.Unmarshal(b, &kf); err != nil {
+		return FileKeyProvider{}, fmt.Errorf("decode key file: %w", err)
+	}
+	fp := FileKeyProvider{current: kf.Current, keys: make(map[string]Key, len(kf.Keys))}
+	for id, v := range kf.Keys {
+		m, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return FileKeyProvider{}, fmt.Errorf("key %q: %w", id, err)
+		}
+		if len(m) != KeySize {
+			return FileKeyProvider{}, fmt.Errorf("key %q has %d bytes, want %d", id, len(m), KeySize)
+		}
+		if !validKeyID(id) {
+			return FileKeyProvider{}, fmt.Errorf("key id %q must not be empty nor contain ':'", id)
+		}
+		fp.keys[id] = Key{ID: id, Material: m}
+	}
+	if _, ok := fp.keys[kf.Current]; !ok {
+		return FileKeyProvider{}, fmt.Errorf("current key %q is not in the file", kf.Current)
+	}
+	return fp, nil
+}
+
+func (fp FileKeyProvider) Current() Key {
+	return fp.keys[fp.current]
+}
+
+func (fp FileKeyProvider) Key(id string) (Key, error) {
+	k, ok := fp.keys[id]
+	if !ok {
+		return Key{}, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	return k, nil
+}
+
+func (fp FileKeyProvider) Keys() []Key {
+	ks := make([]Key, 0, len(fp.keys))
+	for _, k := range fp.keys {
+		ks = append(ks, k)
+	}
+	sort.Slice(ks, func(i, j int) bool { return ks[i].ID < ks[j].ID })
+	return ks
+}
@@ -0,0 +1,123 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/modular-project/address-service/logger"
+	"github.com/modular-project/address-service/metrics"
+	"github.com/modular-project/address-service/model"
+)
+
+// piiPrecision is the number of decimals kept of the coordinates of a
+// delivery address without PII, about 100 meters.
+const piiPrecision = 3
+
+type Storager interface {
+	Each(context.Context, model.Filter, func(model.Delivery) error) error
+}
+
+type Options struct {
+	// Kind of the addresses, metrics.Establishment or metrics.Delivery
+	Kind   string
+	Format string
+	Filter model.Filter
+	// StripPII removes the street and suburb of delivery addresses and
+	// rounds their coordinates. Their user is replaced by its HMAC keyed by
+	// privacy.user_hash_key, the export is pseudonymous: the holders of
+	// the key can still tell the users apart and find them back.
+	StripPII bool
+}
+
+// Exporter writes the addresses of both collections.
+type Exporter struct {
+	est Storager
+	del Storager
+	h   logger.UserHasher
+}
+
+// New returns the exporter of est and del, h hashes the users of the
+// exports without PII.
+func New(est, del Storager, h logger.UserHasher) Exporter {
+	return Exporter{est: est, del: del, h: h}
+}
+
+// Export writes the addresses selected by o to w and returns how many.
+func (e Exporter) Export(ctx context.Context, w io.Writer, o Options) (int, error) {
+	var st Storager
+	switch o.Kind {
+	case metrics.Establishment:
+		st = e.est
+	case metrics.Delivery:
+		st = e.del
+	default:
+		return 0, fmt.Errorf("unknown kind %q, want %s or %s", o.Kind, metrics.Establishment, metrics.Delivery)
+	}
+	ew, err := NewWriter(w, o.Format)
+	if err != nil {
+		return 0, err
+	}
+	strip := o.StripPII && o.Kind == metrics.Delivery
+	n := 0
+	err = st.Each(ctx, o.Filter, func(d model.Delivery) error {
+		n++
+		r := NewRecord(&d)
+		if strip {
+			r = r.stripped(e.h)
+		}
+		return ew.Write(r)
+	})
+	if err != nil {
+		return n, fmt.Errorf("export: %w", err)
+	}
+	if err := ew.Close(); err != nil {
+		return n, fmt.Errorf("close: %w", err)
+	}
+	return n, nil
+}
+
+func round(f float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(f*p) / p
+}
+
+// NewRecord returns the record of d.
+func NewRecord(d *model.Delivery) Record {
+	r := Record{
+		ID:            d.ID.Hex(),
+		Key:           d.ExternalKey,
+		Street:        d.Street,
+		Suburb:        d.Suburb,
+		City:          d.City,
+		PostalCode:    d.PostalCode,
+		State:         d.State,
+		Country:       d.Country,
+		GeocodeStatus: d.Status(),
+		Deleted:       d.IsDeleted,
+	}
+	if d.UserID != 0 {
+		r.UserID = strconv.FormatUint(d.UserID, 10)
+	}
+	if c := d.Location.Coordinates; len(c) == 2 {
+		lng, lat := c[0], c[1]
+		r.Lng, r.Lat = &lng, &lat
+	}
+	return r
+}
+
+// stripped returns r without its street and suburb, with its coordinates
+// rounded and its user replaced by the pseudonym of h.
+func (r Record) stripped(h logger.UserHasher) Record {
+	r.Street, r.Suburb = "", ""
+	if r.UserID != "" {
+		r.UserID = h.Hash(r.UserID)
+	}
+	if r.Lng != nil && r.Lat != nil {
+		lng, lat := round(*r.Lng, piiPrecision), round(*r.Lat, piiPrecision)
+		r.Lng, r.Lat = &lng, &lat
+	}
+	return r
+}
@@ -0,0 +1,98 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/modular-project/address-service/logger"
+	"github.com/modular-project/address-service/metrics"
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var testHasher, _ = logger.NewUserHasher([]byte("test"))
+
+type fakeStorage []model.Delivery
+
+func (f fakeStorage) Each(_ context.Context, _ model.Filter, fn func(model.Delivery) error) error {
+	for _, d := range f {
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExporter_Export(t *testing.T) {
+	id, _ := primitive.ObjectIDFromHex("62f0a1b2c3d4e5f601234567")
+	del := fakeStorage{
+		{
+			UserID: 7,
+			Address: model.Address{
+				ID: id, Street: "Av. Vallarta 1", Suburb: "Centro", City: "Guadalajara", State: "Jalisco", Country: "México",
+				Location: model.Location{Type: "Point", Coordinates: []float64{-103.3266212, 20.6545464}},
+			},
+		},
+		{UserID: 7, Address: model.Address{ID: id, City: "Zapopan", GeocodeStatus: model.GeocodePending}},
+	}
+	tests := []struct {
+		name string
+		o    Options
+		want string
+	}{
+		{
+			name: "csv",
+			o:    Options{Kind: metrics.Delivery, Format: CSV},
+			want: "id,key,user_id,street,suburb,city,pc,state,country,lng,lat,geocode_status,deleted\n" +
+				"62f0a1b2c3d4e5f601234567,,7,Av. Vallarta 1,Centro,Guadalajara,,Jalisco,México,-103.3266212,20.6545464,done,false\n" +
+				"62f0a1b2c3d4e5f601234567,,7,,,Zapopan,,,,,,pending,false\n",
+		}, {
+			name: "ndjson without pii",
+			o:    Options{Kind: metrics.Delivery, Format: NDJSON, StripPII: true},
+			want: `{"id":"62f0a1b2c3d4e5f601234567","user_id":"u:d51712fd6bd95a92","city":"Guadalajara","state":"Jalisco","country":"México","lng":-103.327,"lat":20.655,"geocode_status":"done"}` + "\n" +
+				`{"id":"62f0a1b2c3d4e5f601234567","user_id":"u:d51712fd6bd95a92","city":"Zapopan","geocode_status":"pending"}` + "\n",
+		}, {
+			name: "geojson",
+			o:    Options{Kind: metrics.Delivery, Format: GeoJSON},
+			want: `{"type":"FeatureCollection","features":[` + "\n" +
+				`{"type":"Feature","id":"62f0a1b2c3d4e5f601234567","geometry":{"type":"Point","coordinates":[-103.3266212,20.6545464]},"properties":{"id":"62f0a1b2c3d4e5f601234567","user_id":"7","street":"Av. Vallarta 1","suburb":"Centro","city":"Guadalajara","state":"Jalisco","country":"México","geocode_status":"done"}},` + "\n" +
+				`{"type":"Feature","id":"62f0a1b2c3d4e5f601234567","geometry":null,"properties":{"id":"62f0a1b2c3d4e5f601234567","user_id":"7","city":"Zapopan","geocode_status":"pending"}}` + "\n]}\n",
+		}, {
+			name: "empty geojson",
+			o:    Options{Kind: metrics.Establishment, Format: GeoJSON},
+			want: `{"type":"FeatureCollection","features":[]}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if _, err := New(fakeStorage{}, del, testHasher).Export(context.Background(), &b, tt.o); err != nil {
+				t.Fatalf("Exporter.Export() error = %v", err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("Exporter.Export() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExporter_Export_keyedUser(t *testing.T) {
+	del := fakeStorage{{UserID: 7, Address: model.Address{City: "Guadalajara"}}}
+	rotated, _ := logger.NewUserHasher([]byte("rotated"))
+	o := Options{Kind: metrics.Delivery, Format: CSV, StripPII: true}
+	var a, b bytes.Buffer
+	if _, err := New(fakeStorage{}, del, testHasher).Export(context.Background(), &a, o); err != nil {
+		t.Fatalf("Exporter.Export() error = %v", err)
+	}
+	if _, err := New(fakeStorage{}, del, rotated).Export(context.Background(), &b, o); err != nil {
+		t.Fatalf("Exporter.Export() error = %v", err)
+	}
+	if a.String() == b.String() {
+		t.Errorf("Exporter.Export() with another key = %s, want another pseudonym", b.String())
+	}
+	if strings.Contains(a.String(), ",7,") {
+		t.Errorf("Exporter.Export() = %s, want the user hashed", a.String())
+	}
+}
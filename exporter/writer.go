@@ -0,0 +1,164 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Record is an exported address, Lng and Lat are nil when it is not
+// geocoded.
+type Record struct {
+	ID            string   `json:"id"`
+	Key           string   `json:"key,omitempty"`
+	UserID        string   `json:"user_id,omitempty"`
+	Street        string   `json:"street,omitempty"`
+	Suburb        string   `json:"suburb,omitempty"`
+	City          string   `json:"city,omitempty"`
+	PostalCode    string   `json:"pc,omitempty"`
+	State         string   `json:"state,omitempty"`
+	Country       string   `json:"country,omitempty"`
+	Lng           *float64 `json:"lng,omitempty"`
+	Lat           *float64 `json:"lat,omitempty"`
+	GeocodeStatus string   `json:"geocode_status"`
+	Deleted       bool     `json:"deleted,omitempty"`
+}
+
+// Writer encodes the records in a format, Close writes what the format needs
+// after the last record but does not close the underlying io.Writer.
+type Writer interface {
+	Write(Record) error
+	Close() error
+}
+
+// Formats supported by NewWriter.
+const (
+	CSV     = "csv"
+	NDJSON  = "ndjson"
+	GeoJSON = "geojson"
+)
+
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case CSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case NDJSON, "jsonl":
+		return ndjsonWriter{json.NewEncoder(w)}, nil
+	case GeoJSON:
+		return &geojsonWriter{w: w}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, want csv, ndjson or geojson", format)
+}
+
+var csvHeader = []string{"id", "key", "user_id", "street", "suburb", "city", "pc", "state", "country", "lng", "lat", "geocode_status", "deleted"}
+
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func formatCoord(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
+
+func (cw *csvWriter) Write(r Record) error {
+	if !cw.header {
+		cw.header = true
+		if err := cw.w.Write(csvHeader); err != nil {
+			return fmt.Errorf("write header: %w", err)
+		}
+	}
+	err := cw.w.Write([]string{
+		r.ID, r.Key, r.UserID, r.Street, r.Suburb, r.City, r.PostalCode, r.State, r.Country,
+		formatCoord(r.Lng), formatCoord(r.Lat), r.GeocodeStatus, strconv.FormatBool(r.Deleted),
+	})
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+func (cw *csvWriter) Close() error {
+	if !cw.header {
+		if err := cw.w.Write(csvHeader); err != nil {
+			return fmt.Errorf("write header: %w", err)
+		}
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (nw ndjsonWriter) Write(r Record) error {
+	if err := nw.enc.Encode(r); err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	return nil
+}
+
+func (ndjsonWriter) Close() error {
+	return nil
+}
+
+type geometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+type feature struct {
+	Type       string    `json:"type"`
+	ID         string    `json:"id"`
+	Geometry   *geometry `json:"geometry"`
+	Properties Record    `json:"properties"`
+}
+
+// geojsonWriter streams a FeatureCollection, the addresses without location
+// have a null geometry.
+type geojsonWriter struct {
+	w io.Writer
+	n int
+}
+
+func (gw *geojsonWriter) Write(r Record) error {
+	f := feature{Type: "Feature", ID: r.ID, Properties: r}
+	if r.Lng != nil && r.Lat != nil {
+		f.Geometry = &geometry{Type: "Point", Coordinates: []float64{*r.Lng, *r.Lat}}
+	}
+	// the coordinates are in the geometry only
+	f.Properties.Lng, f.Properties.Lat = nil, nil
+	b, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	sep := ",\n"
+	if gw.n == 0 {
+		sep = `{"type":"FeatureCollection","features":[` + "\n"
+	}
+	gw.n++
+	if _, err := io.WriteString(gw.w, sep); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	if _, err := gw.w.Write(b); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+func (gw *geojsonWriter) Close() error {
+	end := "\n]}\n"
+	if gw.n == 0 {
+		end = `{"type":"FeatureCollection","features":[]}` + "\n"
+	}
+	if _, err := io.WriteString(gw.w, end); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
@@ -3,9 +3,22 @@ module github.com/modular-project/address-service
 go 1.16
 
 require (
+	github.com/BurntSushi/toml v1.2.1
+	github.com/google/uuid v1.3.0
 	github.com/grpc-ecosystem/go-grpc-middleware v1.3.0
+	github.com/grpc-ecosystem/go-grpc-prometheus v1.2.0
 	github.com/modular-project/protobuffers v0.0.0-20220912155936-0cfd57444ce6
+	github.com/prometheus/client_golang v1.12.2
 	go.mongodb.org/mongo-driver v1.10.0
+	go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo v0.32.0
+	go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc v0.32.0
+	go.opentelemetry.io/otel v1.7.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc v1.7.0
+	go.opentelemetry.io/otel/sdk v1.7.0
+	go.opentelemetry.io/otel/trace v1.7.0
+	go.uber.org/zap v1.21.0
 	google.golang.org/grpc v1.46.2
+	google.golang.org/protobuf v1.28.0
 	googlemaps.github.io/maps v1.3.2
+	gopkg.in/yaml.v3 v3.0.1
 )
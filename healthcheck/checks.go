@@ -0,0 +1,65 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+type availability interface {
+	Available() error
+}
+
+type indexVerifier interface {
+	VerifyIndexes(context.Context) error
+}
+
+// Mongo pings the primary of c.
+func Mongo(c *mongo.Client) Check {
+	return Check{
+		Name:     "mongo",
+		Interval: 10 * time.Second,
+		Timeout:  3 * time.Second,
+		Fn: func(ctx context.Context) error {
+			if err := c.Ping(ctx, readpref.Primary()); err != nil {
+				return fmt.Errorf("ping: %w", err)
+			}
+			return nil
+		},
+	}
+}
+
+// Indexes verifies the indexes each storage needs are present.
+func Indexes(vs ...indexVerifier) Check {
+	return Check{
+		Name:     "indexes",
+		Interval: time.Minute,
+		Timeout:  5 * time.Second,
+		Fn: func(ctx context.Context) error {
+			for _, v := range vs {
+				if err := v.VerifyIndexes(ctx); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// GeoCoder reports the circuit breaker and budget of the provider without
+// calling it. It is degraded only, the addresses are geocoded later while
+// the provider is unavailable.
+func GeoCoder(a availability) Check {
+	return Check{
+		Name:     "geocoder",
+		Interval: 10 * time.Second,
+		Timeout:  time.Second,
+		Degraded: true,
+		Fn: func(context.Context) error {
+			return a.Available()
+		},
+	}
+}
@@ -0,0 +1,156 @@
+package healthcheck
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// Liveness is SERVING while the process is running, it only turns
+	// NOT_SERVING on shutdown so Kubernetes does not restart the pod because
+	// a dependency is down.
+	Liveness = "liveness"
+	// Readiness is SERVING only while every check passes.
+	Readiness = "readiness"
+)
+
+// Check is a dependency probe run every Interval. A Degraded check is only
+// reported as its own service, the service keeps working without it.
+type Check struct {
+	Name     string
+	Interval time.Duration
+	Timeout  time.Duration
+	Degraded bool
+	Fn       func(context.Context) error
+}
+
+// Prober runs checks in the background and keeps the status of the health
+// server up to date. Every check is reported as its own service, the
+// readiness services are SERVING only when all of them but the degraded
+// ones pass.
+type Prober struct {
+	hs       *health.Server
+	l        *zap.Logger
+	services []string
+	checks   []Check
+	mu       sync.Mutex
+	failing  map[string]error
+	degraded map[string]error
+	done     bool
+}
+
+// NewProber returns a prober for hs, services are the names that follow the
+// readiness status, "" and Readiness are always included.
+func NewProber(hs *health.Server, l *zap.Logger, services []string, checks ...Check) *Prober {
+	p := &Prober{
+		hs:       hs,
+		l:        l,
+		services: append([]string{"", Readiness}, services...),
+		checks:   checks,
+		failing:  make(map[string]error, len(checks)),
+		degraded: make(map[string]error),
+	}
+	// not ready until every check passed once
+	for _, c := range checks {
+		if c.Degraded {
+			p.degraded[c.Name] = nil
+		} else {
+			p.failing[c.Name] = nil
+		}
+		hs.SetServingStatus(c.Name, healthpb.HealthCheckResponse_UNKNOWN)
+	}
+	hs.SetServingStatus(Liveness, healthpb.HealthCheckResponse_SERVING)
+	p.update()
+	return p
+}
+
+// Run probes every check until ctx is done.
+func (p *Prober) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, c := range p.checks {
+		wg.Add(1)
+		go func(c Check) {
+			defer wg.Done()
+			p.loop(ctx, c)
+		}(c)
+	}
+	wg.Wait()
+}
+
+func (p *Prober) loop(ctx context.Context, c Check) {
+	interval := c.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		p.probe(ctx, c)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (p *Prober) probe(ctx context.Context, c Check) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	err := c.Fn(cctx)
+	cancel()
+	if ctx.Err() != nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done {
+		return
+	}
+	results := p.failing
+	if c.Degraded {
+		results = p.degraded
+	}
+	prev := results[c.Name]
+	if err != nil {
+		if prev == nil {
+			p.l.Warn("health check failed", zap.String("check", c.Name), zap.Bool("degraded", c.Degraded), zap.Error(err))
+		}
+		results[c.Name] = err
+		p.hs.SetServingStatus(c.Name, healthpb.HealthCheckResponse_NOT_SERVING)
+	} else {
+		if prev != nil {
+			p.l.Info("health check recovered", zap.String("check", c.Name))
+		}
+		delete(results, c.Name)
+		p.hs.SetServingStatus(c.Name, healthpb.HealthCheckResponse_SERVING)
+	}
+	p.update()
+}
+
+// update must be called with mu held.
+func (p *Prober) update() {
+	s := healthpb.HealthCheckResponse_SERVING
+	if len(p.failing) != 0 {
+		s = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	for _, srv := range p.services {
+		p.hs.SetServingStatus(srv, s)
+	}
+}
+
+// Shutdown marks every service NOT_SERVING and ignores further results.
+func (p *Prober) Shutdown() {
+	p.mu.Lock()
+	p.done = true
+	p.mu.Unlock()
+	p.hs.Shutdown()
+}
@@ -0,0 +1,89 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func status(t *testing.T, hs *health.Server, srv string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	r, err := hs.Check(context.Background(), &healthpb.HealthCheckRequest{Service: srv})
+	if err != nil {
+		t.Fatalf("Check(%q): %s", srv, err)
+	}
+	return r.Status
+}
+
+func TestProber_probe(t *testing.T) {
+	const service = "address.AddressService"
+	var mongoErr, geoErr error
+	mongo := Check{Name: "mongo", Fn: func(context.Context) error { return mongoErr }}
+	geo := Check{Name: "geocoder", Degraded: true, Fn: func(context.Context) error { return geoErr }}
+	hs := health.NewServer()
+	p := NewProber(hs, zap.NewNop(), []string{service}, mongo, geo)
+	tests := []struct {
+		name      string
+		mongoErr  error
+		geoErr    error
+		wantReady healthpb.HealthCheckResponse_ServingStatus
+		wantMongo healthpb.HealthCheckResponse_ServingStatus
+		wantGeo   healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{
+			name:      "all checks pass",
+			wantReady: healthpb.HealthCheckResponse_SERVING,
+			wantMongo: healthpb.HealthCheckResponse_SERVING,
+			wantGeo:   healthpb.HealthCheckResponse_SERVING,
+		}, {
+			name:      "mongo down",
+			mongoErr:  errors.New("unreachable"),
+			wantReady: healthpb.HealthCheckResponse_NOT_SERVING,
+			wantMongo: healthpb.HealthCheckResponse_NOT_SERVING,
+			wantGeo:   healthpb.HealthCheckResponse_SERVING,
+		}, {
+			name:      "geocoder circuit open is degraded only",
+			geoErr:    errors.New("circuit open"),
+			wantReady: healthpb.HealthCheckResponse_SERVING,
+			wantMongo: healthpb.HealthCheckResponse_SERVING,
+			wantGeo:   healthpb.HealthCheckResponse_NOT_SERVING,
+		}, {
+			name:      "recovered",
+			wantReady: healthpb.HealthCheckResponse_SERVING,
+			wantMongo: healthpb.HealthCheckResponse_SERVING,
+			wantGeo:   healthpb.HealthCheckResponse_SERVING,
+		},
+	}
+	if got := status(t, hs, Readiness); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("readiness before first probe = %s, want NOT_SERVING", got)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mongoErr, geoErr = tt.mongoErr, tt.geoErr
+			p.probe(context.Background(), mongo)
+			p.probe(context.Background(), geo)
+			for _, srv := range []string{"", Readiness, service} {
+				if got := status(t, hs, srv); got != tt.wantReady {
+					t.Errorf("status(%q) = %s, want %s", srv, got, tt.wantReady)
+				}
+			}
+			if got := status(t, hs, "mongo"); got != tt.wantMongo {
+				t.Errorf("status(mongo) = %s, want %s", got, tt.wantMongo)
+			}
+			if got := status(t, hs, "geocoder"); got != tt.wantGeo {
+				t.Errorf("status(geocoder) = %s, want %s", got, tt.wantGeo)
+			}
+			if got := status(t, hs, Liveness); got != healthpb.HealthCheckResponse_SERVING {
+				t.Errorf("status(liveness) = %s, want SERVING", got)
+			}
+		})
+	}
+	p.Shutdown()
+	if got := status(t, hs, Liveness); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("liveness after Shutdown = %s, want NOT_SERVING", got)
+	}
+}
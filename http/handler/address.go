@@ -4,14 +4,36 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/modular-project/address-service/controller"
 	"github.com/modular-project/address-service/model"
 	pf "github.com/modular-project/protobuffers/address/address"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// The geocoding status is not part of the Address message, it is sent in
+// the response headers: GeocodeStatusHeader for a single address and
+// GeocodePendingHeader with the ids not geocoded yet for lists.
+const (
+	GeocodeStatusHeader  = "x-geocode-status"
+	GeocodePendingHeader = "x-geocode-pending"
+)
+
+// CreateDelivery reads the duplicate policy of the call from
+// DuplicatePolicyHeader, allow, reuse or reject, and sends the id of the
+// existing address it matched in DuplicateOfHeader.
+const (
+	DuplicatePolicyHeader = "x-duplicate-policy"
+	DuplicateOfHeader     = "x-duplicate-of"
 )
 
 type AddressServicer interface {
-	CreateDelivery(context.Context, *model.Delivery) (string, error)
+	CreateDelivery(ctx context.Context, d *model.Delivery, policy string) (string, bool, error)
 	User(c context.Context, uID uint64) ([]model.Address, error)
 	GetByID(c context.Context, uID uint64, aID string) (model.Address, error)
 	GetAddByID(c context.Context, aID string) (model.Address, error)
@@ -19,7 +41,7 @@ type AddressServicer interface {
 	Create(context.Context, *model.Address) (string, error)
 	DeleteByID(c context.Context, aID string) (int64, error)
 	Search(context.Context, *model.Search) ([]model.Address, error)
-	Nearest(c context.Context, uID uint64, aID string) (string, error)
+	Nearest(c context.Context, uID uint64, aID string, av model.Availability) (string, error)
 }
 
 //
@@ -32,6 +54,46 @@ func NewAddressUC(as AddressServicer) AddressUC {
 	return AddressUC{as: as}
 }
 
+// statusError returns a gRPC status for the known domain errors, any other
+// error is wrapped with msg.
+func statusError(err error, msg string) error {
+	switch {
+	case errors.Is(err, controller.ErrDeliveryLimit):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, controller.ErrGeoCoderUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, controller.ErrNoEstablishment):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, controller.ErrNotGeocoded):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, controller.ErrBatchSize), errors.Is(err, controller.ErrInvalidInfo),
+		errors.Is(err, controller.ErrInvalidLocation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, controller.ErrDuplicate):
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// setGeocodeStatus sends the geocoding status of a, the header is best
+// effort and its error ignored.
+func setGeocodeStatus(ctx context.Context, a *model.Address) {
+	_ = grpc.SetHeader(ctx, metadata.Pairs(GeocodeStatusHeader, a.Status()))
+}
+
+// setGeocodePending sends the ids of the addresses without location.
+func setGeocodePending(ctx context.Context, as []model.Address) {
+	var ids []string
+	for i := range as {
+		if as[i].Status() != model.GeocodeDone {
+			ids = append(ids, as[i].ID.Hex())
+		}
+	}
+	if len(ids) != 0 {
+		_ = grpc.SetHeader(ctx, metadata.Pairs(GeocodePendingHeader, strings.Join(ids, ",")))
+	}
+}
+
 func protoAddress(m *model.Address) pf.Address {
 	return pf.Address{
 		Id:      m.ID.Hex(),
@@ -60,14 +122,31 @@ func (uc AddressUC) CreateDelivery(c context.Context, d *pf.Delivery) (*pf.ID, e
 	if d.Address == nil {
 		return &pf.ID{}, errors.New("empty address")
 	}
+	var policy string
+	if md, ok := metadata.FromIncomingContext(c); ok {
+		if v := md.Get(DuplicatePolicyHeader); len(v) > 0 {
+			policy = v[0]
+		}
+	}
+	if policy != "" && !controller.ValidDuplicatePolicy(policy) {
+		return &pf.ID{}, status.Errorf(codes.InvalidArgument, "invalid %s %q", DuplicatePolicyHeader, policy)
+	}
 	m := model.Delivery{
 		UserID:  d.UserId,
 		Address: modelAddress(d.Address),
 	}
-	id, err := uc.as.CreateDelivery(c, &m)
+	id, dup, err := uc.as.CreateDelivery(c, &m, policy)
+	var de controller.DuplicateError
+	if errors.As(err, &de) {
+		_ = grpc.SetHeader(c, metadata.Pairs(DuplicateOfHeader, de.Existing.ID.Hex()))
+	}
 	if err != nil {
-		return &pf.ID{}, fmt.Errorf("create delivery: %w", err)
+		return &pf.ID{}, statusError(err, "create delivery")
 	}
+	if dup {
+		_ = grpc.SetHeader(c, metadata.Pairs(DuplicateOfHeader, id))
+	}
+	setGeocodeStatus(c, &m.Address)
 	return &pf.ID{Id: id}, nil
 }
 
@@ -76,6 +155,7 @@ func (uc AddressUC) GetAllByUser(c context.Context, u *pf.User) (*pf.ResponseAll
 	if err != nil {
 		return &pf.ResponseAll{}, fmt.Errorf("user: %w", err)
 	}
+	setGeocodePending(c, ads)
 	if ads == nil {
 		return &pf.ResponseAll{}, nil
 	}
@@ -100,6 +180,7 @@ func (uc AddressUC) GetByID(c context.Context, u *pf.User) (*pf.Address, error)
 	if err != nil {
 		return &pf.Address{}, fmt.Errorf("get by id: %w", err)
 	}
+	setGeocodeStatus(c, &ma)
 	pa := protoAddress(&ma)
 	return &pa, nil
 }
@@ -109,6 +190,7 @@ func (uc AddressUC) GetAddByID(c context.Context, ID *pf.ID) (*pf.Address, error
 	if err != nil {
 		return &pf.Address{}, fmt.Errorf("get add by id: %w", err)
 	}
+	setGeocodeStatus(c, &ma)
 	pa := protoAddress(&ma)
 	return &pa, nil
 }
@@ -118,6 +200,7 @@ func (uc AddressUC) GetByUser(c context.Context, u *pf.User) (*pf.Address, error
 	if err != nil {
 		return &pf.Address{}, fmt.Errorf("get by id: %w", err)
 	}
+	setGeocodeStatus(c, &ma)
 	pa := protoAddress(&ma)
 	return &pa, nil
 }
@@ -126,8 +209,9 @@ func (uc AddressUC) CreateEstablishment(c context.Context, pa *pf.Address) (*pf.
 	ma := modelAddress(pa)
 	id, err := uc.as.Create(c, &ma)
 	if err != nil {
-		return &pf.ID{}, fmt.Errorf("create: %w", err)
+		return &pf.ID{}, statusError(err, "create")
 	}
+	setGeocodeStatus(c, &ma)
 	return &pf.ID{Id: id}, nil
 }
 
@@ -139,31 +223,40 @@ func (uc AddressUC) DeleteEstablishment(c context.Context, id *pf.ID) (*pf.Respo
 	return &pf.ResponseDelete{}, nil
 }
 
-func (uc AddressUC) Search(c context.Context, ps *pf.SearchAddress) (*pf.ResponseAll, error) {
+// modelSearch converts ps, its queries match case insensitive regexes.
+func modelSearch(ps *pf.SearchAddress) model.Search {
 	ms := model.Search{
-		Limit:   int64(ps.Default.Limit),
-		Offset:  int64(ps.Default.Offset),
+		Limit:   int64(ps.GetDefault().GetLimit()),
+		Offset:  int64(ps.GetDefault().GetOffset()),
 		OrderBy: primitive.D{},
 		Querys:  primitive.D{},
 	}
-	if ps.OrderBy != nil {
-		ms.OrderBy = make(primitive.D, len(ps.OrderBy))
-		for i := range ps.OrderBy {
-			ms.OrderBy[i].Key = ps.OrderBy[i].Key
-			ms.OrderBy[i].Value = ps.OrderBy[i].Val
+	if ob := ps.GetOrderBy(); ob != nil {
+		ms.OrderBy = make(primitive.D, len(ob))
+		for i := range ob {
+			ms.OrderBy[i].Key = ob[i].Key
+			ms.OrderBy[i].Value = ob[i].Val
 		}
 	}
-	if ps.Query != nil {
-		ms.Querys = make(primitive.D, len(ps.Query))
-		for i := range ps.Query {
-			ms.Querys[i].Key = ps.Query[i].Key
-			ms.Querys[i].Value = primitive.Regex{Pattern: ps.Query[i].Val, Options: "i"}
+	if q := ps.GetQuery(); q != nil {
+		ms.Querys = make(primitive.D, len(q))
+		for i := range q {
+			ms.Querys[i].Key = q[i].Key
+			ms.Querys[i].Value = primitive.Regex{Pattern: q[i].Val, Options: "i"}
 		}
 	}
+	return ms
+}
+
+func (uc AddressUC) Search(c context.Context, ps *pf.SearchAddress) (*pf.ResponseAll, error) {
+	ms := modelSearch(ps)
+	// the shared search lists every establishment
+	ms.IncludeSuspended = true
 	mas, err := uc.as.Search(c, &ms)
 	if err != nil {
 		return &pf.ResponseAll{}, fmt.Errorf("search: %w", err)
 	}
+	setGeocodePending(c, mas)
 	if mas == nil {
 		return &pf.ResponseAll{}, nil
 	}
@@ -176,9 +269,9 @@ func (uc AddressUC) Search(c context.Context, ps *pf.SearchAddress) (*pf.Respons
 }
 
 func (uc AddressUC) Nearest(c context.Context, u *pf.User) (*pf.ID, error) {
-	id, err := uc.as.Nearest(c, u.Id, u.AddressId)
+	id, err := uc.as.Nearest(c, u.Id, u.AddressId, model.Availability{})
 	if err != nil {
-		return &pf.ID{}, fmt.Errorf("nearest: %w", err)
+		return &pf.ID{}, statusError(err, "nearest")
 	}
 	return &pf.ID{Id: id}, nil
 }
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/modular-project/address-service/controller"
+	"github.com/modular-project/address-service/exporter"
+	"github.com/modular-project/address-service/metrics"
+	"github.com/modular-project/address-service/model"
+	pe "github.com/modular-project/address-service/proto/addressext"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// defaultAuditLimit is the number of entries of an AuditHistory without
+// limit.
+const defaultAuditLimit = 100
+
+type AuditReader interface {
+	History(context.Context, model.AuditQuery) ([]model.AuditEntry, error)
+}
+
+type Restorer interface {
+	RestoreDelivery(context.Context, uint64, string) (int64, error)
+}
+
+type Privacy interface {
+	ExportUserData(context.Context, uint64) (controller.UserData, error)
+	EraseUser(context.Context, uint64, string) (int64, error)
+}
+
+// AdminUC serves the RPCs of the support and back office tools.
+type AdminUC struct {
+	pe.UnimplementedAddressAdminServiceServer
+	ar AuditReader
+	rs Restorer
+	pr Privacy
+}
+
+func NewAdminUC(ar AuditReader, rs Restorer, pr Privacy) AdminUC {
+	return AdminUC{ar: ar, rs: rs, pr: pr}
+}
+
+var auditKind = map[string]pe.Kind{
+	metrics.Establishment: pe.Kind_ESTABLISHMENT,
+	metrics.Delivery:      pe.Kind_DELIVERY,
+}
+
+func protoAuditEntry(e *model.AuditEntry) *pe.AuditEntry {
+	cs := make([]*pe.FieldChange, len(e.Changes))
+	for i, c := range e.Changes {
+		cs[i] = &pe.FieldChange{Field: c.Field, Before: c.Before, After: c.After}
+	}
+	return &pe.AuditEntry{
+		Kind:      auditKind[e.Kind],
+		AddressId: e.AddressID,
+		UserId:    e.UserID,
+		Op:        e.Op,
+		Actor:     e.Actor,
+		Rpc:       e.RPC,
+		RequestId: e.RequestID,
+		Time:      timestamppb.New(e.At),
+		Changes:   cs,
+	}
+}
+
+func (uc AdminUC) AuditHistory(c context.Context, req *pe.AuditHistoryRequest) (*pe.AuditHistoryResponse, error) {
+	if req.AddressId == "" && req.UserId == 0 {
+		return &pe.AuditHistoryResponse{}, status.Error(codes.InvalidArgument, "address_id or user_id is required")
+	}
+	q := model.AuditQuery{AddressID: req.AddressId, UserID: req.UserId, Limit: int64(req.Limit)}
+	if q.Limit == 0 {
+		q.Limit = defaultAuditLimit
+	}
+	if req.Before != nil {
+		q.Before = req.Before.AsTime()
+	}
+	es, err := uc.ar.History(c, q)
+	if err != nil {
+		return &pe.AuditHistoryResponse{}, statusError(err, "history")
+	}
+	pes := make([]*pe.AuditEntry, len(es))
+	for i := range es {
+		pes[i] = protoAuditEntry(&es[i])
+	}
+	return &pe.AuditHistoryResponse{Entries: pes}, nil
+}
+
+func (uc AdminUC) RestoreDelivery(c context.Context, req *pe.RestoreDeliveryRequest) (*pe.RestoreDeliveryResponse, error) {
+	n, err := uc.rs.RestoreDelivery(c, req.UserId, req.AddressId)
+	if err != nil {
+		return &pe.RestoreDeliveryResponse{}, statusError(err, "restore delivery")
+	}
+	return &pe.RestoreDeliveryResponse{Restored: n != 0}, nil
+}
+
+// userData is the document sent by ExportUserData.
+type userData struct {
+	UserID     string            `json:"user_id"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Addresses  []exporter.Record `json:"addresses"`
+}
+
+func (uc AdminUC) ExportUserData(c context.Context, req *pe.UserDataRequest) (*pe.UserDataResponse, error) {
+	if req.UserId == 0 {
+		return &pe.UserDataResponse{}, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	ud, err := uc.pr.ExportUserData(c, req.UserId)
+	if err != nil {
+		return &pe.UserDataResponse{}, statusError(err, "export user data")
+	}
+	doc := userData{
+		UserID:     strconv.FormatUint(ud.UserID, 10),
+		ExportedAt: ud.ExportedAt,
+		Addresses:  make([]exporter.Record, len(ud.Deliveries)),
+	}
+	for i := range ud.Deliveries {
+		doc.Addresses[i] = exporter.NewRecord(&ud.Deliveries[i])
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return &pe.UserDataResponse{}, fmt.Errorf("marshal: %w", err)
+	}
+	return &pe.UserDataResponse{Data: b}, nil
+}
+
+var eraseMode = map[pe.EraseUserRequest_Mode]string{
+	pe.EraseUserRequest_ANONYMIZE: controller.EraseAnonymize,
+	pe.EraseUserRequest_DELETE:    controller.EraseDelete,
+}
+
+func (uc AdminUC) EraseUser(c context.Context, req *pe.EraseUserRequest) (*pe.EraseUserResponse, error) {
+	mode, ok := eraseMode[req.Mode]
+	if req.UserId == 0 || !ok {
+		return &pe.EraseUserResponse{}, status.Error(codes.InvalidArgument, "user_id and a known mode are required")
+	}
+	n, err := uc.pr.EraseUser(c, req.UserId, mode)
+	if err != nil {
+		return &pe.EraseUserResponse{}, statusError(err, "erase user")
+	}
+	return &pe.EraseUserResponse{Erased: uint32(n)}, nil
+}
@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/modular-project/address-service/controller"
+	"github.com/modular-project/address-service/model"
+	pe "github.com/modular-project/address-service/proto/addressext"
+	pf "github.com/modular-project/protobuffers/address/address"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type Establishments interface {
+	GetAddByID(context.Context, string) (model.Address, error)
+	UpdateEstablishmentInfo(context.Context, string, model.EstablishmentInfo) (model.Address, error)
+	Assign(ctx context.Context, uID uint64, aID string, av model.Availability) (controller.Assignment, error)
+	ReportLoad(ctx context.Context, aID string, active int) (int64, error)
+	Search(context.Context, *model.Search) ([]model.Address, error)
+	CheckDeliverability(ctx context.Context, a model.Address, loc []float64, t time.Time) (controller.Deliverability, error)
+}
+
+// parseClock returns the minutes since midnight of HH:MM, up to 24:00.
+func parseClock(s string) (int, error) {
+	var h, m int
+	if len(s) != 5 || s[2] != ':' {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	if _, err := fmt.Sscanf(s, "%02d:%02d", &h, &m); err != nil || h > 24 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	return h*60 + m, nil
+}
+
+func formatClock(m int) string {
+	return fmt.Sprintf("%02d:%02d", m/60, m%60)
+}
+
+func modelRanges(prs []*pe.TimeRange) ([]model.TimeRange, error) {
+	rs := make([]model.TimeRange, len(prs))
+	for i, pr := range prs {
+		var err error
+		if rs[i].Open, err = parseClock(pr.Open); err != nil {
+			return nil, err
+		}
+		if rs[i].Close, err = parseClock(pr.Close); err != nil {
+			return nil, err
+		}
+	}
+	return rs, nil
+}
+
+func protoRanges(rs []model.TimeRange) []*pe.TimeRange {
+	prs := make([]*pe.TimeRange, len(rs))
+	for i, r := range rs {
+		prs[i] = &pe.TimeRange{Open: formatClock(r.Open), Close: formatClock(r.Close)}
+	}
+	return prs
+}
+
+// modelInfo converts pi, the error is about the format of the hours.
+func modelInfo(pi *pe.EstablishmentInfo) (model.EstablishmentInfo, error) {
+	info := model.EstablishmentInfo{
+		Name:      pi.GetName(),
+		Phone:     pi.GetPhone(),
+		Suspended: pi.GetStatus() == pe.EstablishmentInfo_SUSPENDED,
+		Priority:  int(pi.GetPriority()),
+		Capacity:  int(pi.GetCapacity()),
+	}
+	ph := pi.GetHours()
+	if ph == nil {
+		return info, nil
+	}
+	info.Hours = &model.OpeningHours{TimeZone: ph.TimeZone}
+	for _, d := range ph.Weekly {
+		rs, err := modelRanges(d.Ranges)
+		if err != nil {
+			return model.EstablishmentInfo{}, err
+		}
+		for _, r := range rs {
+			info.Hours.Weekly = append(info.Hours.Weekly, model.Period{Day: time.Weekday(d.Day), TimeRange: r})
+		}
+	}
+	for _, e := range ph.Exceptions {
+		rs, err := modelRanges(e.Ranges)
+		if err != nil {
+			return model.EstablishmentInfo{}, err
+		}
+		info.Hours.Exceptions = append(info.Hours.Exceptions, model.HoursException{Date: e.Date, Ranges: rs})
+	}
+	return info, nil
+}
+
+func protoInfo(info model.EstablishmentInfo) *pe.EstablishmentInfo {
+	pi := &pe.EstablishmentInfo{
+		Name:     info.Name,
+		Phone:    info.Phone,
+		Priority: int32(info.Priority),
+		Capacity: uint32(info.Capacity),
+	}
+	if info.Suspended {
+		pi.Status = pe.EstablishmentInfo_SUSPENDED
+	}
+	if info.Hours == nil {
+		return pi
+	}
+	pi.Hours = &pe.OpeningHours{TimeZone: info.Hours.TimeZone}
+	// the periods of the same day are grouped
+	days := map[time.Weekday]*pe.DayHours{}
+	for _, p := range info.Hours.Weekly {
+		d, ok := days[p.Day]
+		if !ok {
+			d = &pe.DayHours{Day: uint32(p.Day)}
+			days[p.Day] = d
+			pi.Hours.Weekly = append(pi.Hours.Weekly, d)
+		}
+		d.Ranges = append(d.Ranges, protoRanges([]model.TimeRange{p.TimeRange})...)
+	}
+	for _, e := range info.Hours.Exceptions {
+		pi.Hours.Exceptions = append(pi.Hours.Exceptions, &pe.HoursException{Date: e.Date, Ranges: protoRanges(e.Ranges)})
+	}
+	return pi
+}
+
+func protoEstablishment(a *model.Address, at time.Time) *pe.Establishment {
+	pa := protoAddress(a)
+	return &pe.Establishment{Address: &pa, Info: protoInfo(a.Info()), Open: a.OpenAt(at)}
+}
+
+// requestTime returns the time of ts, now when unset.
+func requestTime(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Now()
+	}
+	return ts.AsTime()
+}
+
+// openAt returns the time of ts, zero when unset.
+func openAt(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+func (uc AddressExtUC) GetEstablishment(c context.Context, req *pe.GetEstablishmentRequest) (*pe.Establishment, error) {
+	a, err := uc.es.GetAddByID(c, req.Id)
+	if err != nil {
+		return &pe.Establishment{}, fmt.Errorf("get establishment: %w", err)
+	}
+	return protoEstablishment(&a, requestTime(req.At)), nil
+}
+
+func (uc AddressExtUC) UpdateEstablishmentInfo(c context.Context, req *pe.UpdateEstablishmentInfoRequest) (*pe.Establishment, error) {
+	info, err := modelInfo(req.Info)
+	if err != nil {
+		return &pe.Establishment{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+	a, err := uc.es.UpdateEstablishmentInfo(c, req.Id, info)
+	if err != nil {
+		return &pe.Establishment{}, statusError(err, "update establishment info")
+	}
+	return protoEstablishment(&a, time.Now()), nil
+}
+
+func protoAssignment(a controller.Assignment) *pe.Assignment {
+	pa := &pe.Assignment{Strategy: a.Strategy, Candidates: make([]*pe.CandidateScore, len(a.Scores))}
+	for i, s := range a.Scores {
+		pa.Candidates[i] = &pe.CandidateScore{
+			EstablishmentId: s.EstablishmentID,
+			Distance:        s.Distance,
+			Load:            s.Load,
+			Priority:        s.Priority,
+			Total:           s.Total,
+		}
+	}
+	return pa
+}
+
+func (uc AddressExtUC) NearestEstablishment(c context.Context, req *pe.NearestEstablishmentRequest) (*pe.Establishment, error) {
+	as, err := uc.es.Assign(c, req.UserId, req.AddressId, model.Availability{OpenAt: openAt(req.OpenAt)})
+	if err != nil {
+		return &pe.Establishment{}, statusError(err, "nearest establishment")
+	}
+	a, err := uc.es.GetAddByID(c, as.EstablishmentID())
+	if err != nil {
+		return &pe.Establishment{}, fmt.Errorf("get establishment: %w", err)
+	}
+	e := protoEstablishment(&a, requestTime(req.OpenAt))
+	e.Assignment = protoAssignment(as)
+	return e, nil
+}
+
+func (uc AddressExtUC) ReportLoad(c context.Context, req *pe.ReportLoadRequest) (*pe.ReportLoadResponse, error) {
+	n, err := uc.es.ReportLoad(c, req.EstablishmentId, int(req.ActiveOrders))
+	if err != nil {
+		return &pe.ReportLoadResponse{}, fmt.Errorf("report load: %w", err)
+	}
+	if n == 0 {
+		return &pe.ReportLoadResponse{}, status.Errorf(codes.NotFound, "establishment %s not found", req.EstablishmentId)
+	}
+	return &pe.ReportLoadResponse{}, nil
+}
+
+func (uc AddressExtUC) SearchEstablishments(c context.Context, req *pe.SearchEstablishmentsRequest) (*pe.SearchEstablishmentsResponse, error) {
+	ms := modelSearch(req.GetSearch())
+	ms.Availability = model.Availability{IncludeSuspended: req.IncludeSuspended, OpenAt: openAt(req.OpenAt)}
+	mas, err := uc.es.Search(c, &ms)
+	if err != nil {
+		return &pe.SearchEstablishmentsResponse{}, fmt.Errorf("search establishments: %w", err)
+	}
+	at := requestTime(req.OpenAt)
+	pes := make([]*pe.Establishment, len(mas))
+	for i := range mas {
+		pes[i] = protoEstablishment(&mas[i], at)
+	}
+	return &pe.SearchEstablishmentsResponse{Establishments: pes}, nil
+}
+
+var deliverabilityReason = map[string]pe.CheckDeliverabilityResponse_Reason{
+	controller.ReasonOutOfRange:   pe.CheckDeliverabilityResponse_OUT_OF_RANGE,
+	controller.ReasonZoneExcluded: pe.CheckDeliverabilityResponse_ZONE_EXCLUDED,
+	controller.ReasonClosed:       pe.CheckDeliverabilityResponse_STORE_CLOSED,
+}
+
+func (uc AddressExtUC) CheckDeliverability(c context.Context, req *pe.CheckDeliverabilityRequest) (*pe.CheckDeliverabilityResponse, error) {
+	var a model.Address
+	var loc []float64
+	switch {
+	case req.GetLocation() != nil:
+		loc = []float64{float64(req.GetLocation().Long), float64(req.GetLocation().Lat)}
+	case req.GetAddress() != nil:
+		a = modelAddress(req.GetAddress())
+	default:
+		return &pe.CheckDeliverabilityResponse{}, status.Error(codes.InvalidArgument, "missing address or location")
+	}
+	d, err := uc.es.CheckDeliverability(c, a, loc, requestTime(req.At))
+	if err != nil {
+		return &pe.CheckDeliverabilityResponse{}, statusError(err, "check deliverability")
+	}
+	return &pe.CheckDeliverabilityResponse{
+		Deliverable:     d.Deliverable,
+		Reason:          deliverabilityReason[d.Reason],
+		EstablishmentId: d.EstablishmentID,
+		Distance:        d.Distance,
+		Location: &pf.Location{
+			Long: float32(d.Location.Coordinates[0]),
+			Lat:  float32(d.Location.Coordinates[1]),
+		},
+		Zone: d.Zone,
+	}, nil
+}
@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/modular-project/address-service/controller"
+	"github.com/modular-project/address-service/exporter"
+	"github.com/modular-project/address-service/importer"
+	"github.com/modular-project/address-service/metrics"
+	"github.com/modular-project/address-service/model"
+	pe "github.com/modular-project/address-service/proto/addressext"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// exportChunkSize is the size of the chunks sent by ExportAddresses.
+const exportChunkSize = 64 << 10
+
+type Importer interface {
+	Import(context.Context, importer.Source, bool) (importer.Report, error)
+}
+
+type Exporter interface {
+	Export(context.Context, io.Writer, exporter.Options) (int, error)
+}
+
+type Watcher interface {
+	Watch(context.Context, []byte, func(model.EstablishmentChange) error) error
+}
+
+type BatchGetter interface {
+	BatchGetEstablishments(context.Context, []string) ([]controller.BatchResult, error)
+	BatchGetDeliveries(context.Context, uint64, []string) ([]controller.BatchResult, error)
+}
+
+// AddressExtUC serves the RPCs of the address service that are not in the
+// shared protobuffers.
+type AddressExtUC struct {
+	pe.UnimplementedAddressExtServiceServer
+	im Importer
+	ex Exporter
+	w  Watcher
+	bg BatchGetter
+	es Establishments
+}
+
+func NewAddressExtUC(im Importer, ex Exporter, w Watcher, bg BatchGetter, es Establishments) AddressExtUC {
+	return AddressExtUC{im: im, ex: ex, w: w, bg: bg, es: es}
+}
+
+// importStream reads the rows of an import, the options must be the first
+// message.
+type importStream struct {
+	s      pe.AddressExtService_ImportEstablishmentsServer
+	row    int
+	dryRun bool
+	first  *pe.ImportRequest
+}
+
+func (is *importStream) Next() (importer.Row, error) {
+	req := is.first
+	is.first = nil
+	if req == nil {
+		var err error
+		req, err = is.s.Recv()
+		if err != nil {
+			return importer.Row{}, err
+		}
+	}
+	is.row++
+	r := req.GetRow()
+	if r == nil {
+		return importer.Row{Line: is.row, Err: errors.New("options must be the first message")}, nil
+	}
+	var a model.Address
+	if r.Address != nil {
+		a = modelAddress(r.Address)
+	}
+	a.ExternalKey = r.Key
+	return importer.Row{Line: is.row, Address: a}, nil
+}
+
+func (uc AddressExtUC) ImportEstablishments(s pe.AddressExtService_ImportEstablishmentsServer) error {
+	is := &importStream{s: s}
+	req, err := s.Recv()
+	if err == io.EOF {
+		return s.SendAndClose(&pe.ImportReport{})
+	}
+	if err != nil {
+		return fmt.Errorf("recv: %w", err)
+	}
+	if o := req.GetOptions(); o != nil {
+		is.dryRun = o.DryRun
+	} else {
+		is.first = req
+	}
+	rep, err := uc.im.Import(s.Context(), is, is.dryRun)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	return s.SendAndClose(protoReport(rep))
+}
+
+var importStatus = map[string]pe.ImportResult_Status{
+	importer.Created: pe.ImportResult_CREATED,
+	importer.Updated: pe.ImportResult_UPDATED,
+	importer.Valid:   pe.ImportResult_VALID,
+	importer.Invalid: pe.ImportResult_INVALID,
+	importer.Failed:  pe.ImportResult_FAILED,
+}
+
+func protoReport(rep importer.Report) *pe.ImportReport {
+	res := make([]*pe.ImportResult, len(rep.Results))
+	for i, r := range rep.Results {
+		res[i] = &pe.ImportResult{
+			Row:       uint32(r.Line),
+			Key:       r.Key,
+			Id:        r.ID,
+			Status:    importStatus[r.Status],
+			Ambiguous: r.Ambiguous,
+			Message:   r.Message,
+		}
+	}
+	return &pe.ImportReport{Results: res, DryRun: rep.DryRun}
+}
+
+// chunkWriter sends every Write as an ExportChunk.
+type chunkWriter struct {
+	s pe.AddressExtService_ExportAddressesServer
+}
+
+func (cw chunkWriter) Write(b []byte) (int, error) {
+	if err := cw.s.Send(&pe.ExportChunk{Data: b}); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+var exportKind = map[pe.Kind]string{
+	pe.Kind_ESTABLISHMENT: metrics.Establishment,
+	pe.Kind_DELIVERY:      metrics.Delivery,
+}
+
+var exportFormat = map[pe.ExportRequest_Format]string{
+	pe.ExportRequest_CSV:     exporter.CSV,
+	pe.ExportRequest_NDJSON:  exporter.NDJSON,
+	pe.ExportRequest_GEOJSON: exporter.GeoJSON,
+}
+
+func (uc AddressExtUC) ExportAddresses(req *pe.ExportRequest, s pe.AddressExtService_ExportAddressesServer) error {
+	o := exporter.Options{
+		Kind:   exportKind[req.Kind],
+		Format: exportFormat[req.Format],
+		Filter: model.Filter{
+			City:           req.City,
+			State:          req.State,
+			Country:        req.Country,
+			PostalCode:     req.Pc,
+			UserID:         req.UserId,
+			GeocodeStatus:  req.GeocodeStatus,
+			IncludeDeleted: req.IncludeDeleted,
+		},
+		StripPII: req.StripPii,
+	}
+	if o.Kind == "" || o.Format == "" {
+		return status.Error(codes.InvalidArgument, "unknown kind or format")
+	}
+	if req.Since != nil {
+		o.Filter.Since = req.Since.AsTime()
+	}
+	if req.Until != nil {
+		o.Filter.Until = req.Until.AsTime()
+	}
+	// the writers are unbuffered, group them in chunks
+	bw := bufio.NewWriterSize(chunkWriter{s}, exportChunkSize)
+	if _, err := uc.ex.Export(s.Context(), bw, o); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("flush: %w", err)
+	}
+	return nil
+}
+
+var changeOp = map[string]pe.EstablishmentChange_Op{
+	model.ChangeStart:  pe.EstablishmentChange_START,
+	model.ChangeInsert: pe.EstablishmentChange_INSERT,
+	model.ChangeUpdate: pe.EstablishmentChange_UPDATE,
+	model.ChangeDelete: pe.EstablishmentChange_DELETE,
+}
+
+func protoChange(c model.EstablishmentChange) *pe.EstablishmentChange {
+	pc := &pe.EstablishmentChange{
+		Op:          changeOp[c.Op],
+		Id:          c.ID,
+		ResumeToken: c.Token,
+		Time:        timestamppb.New(c.At),
+	}
+	if c.Op == model.ChangeInsert || c.Op == model.ChangeUpdate {
+		pa := protoAddress(&c.Address)
+		pc.Address = &pa
+	}
+	return pc
+}
+
+func (uc AddressExtUC) WatchEstablishments(req *pe.WatchRequest, s pe.AddressExtService_WatchEstablishmentsServer) error {
+	err := uc.w.Watch(s.Context(), req.ResumeToken, func(c model.EstablishmentChange) error {
+		return s.Send(protoChange(c))
+	})
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, controller.ErrResumeToken):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, controller.ErrWatchUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, context.Canceled) && s.Context().Err() == nil:
+		// the server is shutting down
+		return status.Error(codes.Unavailable, "watch ended, resume it with the last token")
+	}
+	return fmt.Errorf("watch: %w", err)
+}
+
+var batchStatus = map[string]pe.BatchGetResult_Status{
+	controller.BatchFound:     pe.BatchGetResult_FOUND,
+	controller.BatchNotFound:  pe.BatchGetResult_NOT_FOUND,
+	controller.BatchForbidden: pe.BatchGetResult_FORBIDDEN,
+	controller.BatchInvalidID: pe.BatchGetResult_INVALID_ID,
+}
+
+func protoBatch(res []controller.BatchResult) *pe.BatchGetResponse {
+	pr := make([]*pe.BatchGetResult, len(res))
+	for i := range res {
+		pr[i] = &pe.BatchGetResult{Id: res[i].ID, Status: batchStatus[res[i].Status]}
+		if res[i].Status == controller.BatchFound {
+			pa := protoAddress(&res[i].Address)
+			pr[i].Address = &pa
+		}
+	}
+	return &pe.BatchGetResponse{Results: pr}
+}
+
+func (uc AddressExtUC) BatchGetEstablishments(c context.Context, req *pe.BatchGetEstablishmentsRequest) (*pe.BatchGetResponse, error) {
+	res, err := uc.bg.BatchGetEstablishments(c, req.Ids)
+	if err != nil {
+		return &pe.BatchGetResponse{}, statusError(err, "batch get establishments")
+	}
+	return protoBatch(res), nil
+}
+
+func (uc AddressExtUC) BatchGetDeliveries(c context.Context, req *pe.BatchGetDeliveriesRequest) (*pe.BatchGetResponse, error) {
+	// the anonymized addresses have no user
+	if req.UserId == 0 {
+		return &pe.BatchGetResponse{}, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	res, err := uc.bg.BatchGetDeliveries(c, req.UserId, req.Ids)
+	if err != nil {
+		return &pe.BatchGetResponse{}, statusError(err, "batch get deliveries")
+	}
+	return protoBatch(res), nil
+}
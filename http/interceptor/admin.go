@@ -0,0 +1,107 @@
+package interceptor
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/modular-project/address-service/audit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AdminTokenHeader is the metadata key holding the token the gateway signs
+// for an authenticated administrator.
+const AdminTokenHeader = "x-admin-token"
+
+var errAdminToken = errors.New("invalid admin token")
+
+func adminSignature(key []byte, payload string) string {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// AdminToken returns the token of subject valid until exp, signed with key.
+// Its format is subject.expiry.signature, the expiry in Unix seconds.
+func AdminToken(key []byte, subject string, exp time.Time) string {
+	payload := subject + "." + strconv.FormatInt(exp.Unix(), 10)
+	return payload + "." + adminSignature(key, payload)
+}
+
+// verifyAdminToken returns the subject of tok when it is signed with key and
+// not expired at now.
+func verifyAdminToken(key []byte, tok string, now time.Time) (string, error) {
+	i := strings.LastIndexByte(tok, '.')
+	if len(key) == 0 || i < 0 {
+		return "", errAdminToken
+	}
+	payload, sig := tok[:i], tok[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(adminSignature(key, payload))) {
+		return "", errAdminToken
+	}
+	j := strings.LastIndexByte(payload, '.')
+	if j < 1 {
+		return "", errAdminToken
+	}
+	exp, err := strconv.ParseInt(payload[j+1:], 10, 64)
+	if err != nil || !now.Before(time.Unix(exp, 0)) {
+		return "", errAdminToken
+	}
+	return payload[:j], nil
+}
+
+// withAdmin rejects the calls of the methods under prefix without a valid
+// admin token, the changes of the others are audited under its subject.
+func withAdmin(ctx context.Context, method, prefix string, key []byte) (context.Context, error) {
+	if !strings.HasPrefix(method, prefix) {
+		return ctx, nil
+	}
+	var tok string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(AdminTokenHeader); len(v) > 0 {
+			tok = v[0]
+		}
+	}
+	sub, err := verifyAdminToken(key, tok, time.Now())
+	if err != nil {
+		return nil, status.Errorf(codes.PermissionDenied, "%s requires a valid %s", method, AdminTokenHeader)
+	}
+	m := audit.FromContext(ctx)
+	m.Actor = sub
+	return audit.NewContext(ctx, m), nil
+}
+
+// UnaryAdmin restricts the methods of the service prefix, e.g.
+// "/pkg.AdminService/", to the callers with an admin token signed with key.
+// An empty key rejects every call. It must run after UnaryAuditMeta.
+func UnaryAdmin(prefix string, key []byte) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := withAdmin(ctx, info.FullMethod, prefix, key)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAdmin is the streaming counterpart of UnaryAdmin.
+func StreamAdmin(prefix string, key []byte) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := withAdmin(ss.Context(), info.FullMethod, prefix, key)
+		if err != nil {
+			return err
+		}
+		w := grpc_middleware.WrapServerStream(ss)
+		w.WrappedContext = ctx
+		return handler(srv, w)
+	}
+}
@@ -0,0 +1,58 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/modular-project/address-service/audit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryAdmin(t *testing.T) {
+	const prefix = "/proto.address.ext.AddressAdminService/"
+	key := []byte("admin-key")
+	exp := time.Now().Add(time.Minute)
+	tests := []struct {
+		name      string
+		method    string
+		key       []byte
+		token     string
+		wantCode  codes.Code
+		wantActor string
+	}{
+		{name: "unauthenticated", key: key, wantCode: codes.PermissionDenied},
+		{name: "signed with another key", key: key, token: AdminToken([]byte("other"), "ana", exp), wantCode: codes.PermissionDenied},
+		{name: "expired", key: key, token: AdminToken(key, "ana", time.Now().Add(-time.Second)), wantCode: codes.PermissionDenied},
+		{name: "tampered subject", key: key, token: "root" + AdminToken(key, "ana", exp)[3:], wantCode: codes.PermissionDenied},
+		{name: "without key", token: AdminToken(nil, "ana", exp), wantCode: codes.PermissionDenied},
+		{name: "admin", key: key, token: AdminToken(key, "ana.support", exp), wantActor: "ana.support"},
+		{name: "other service", method: "/proto.address.address.AddressService/Search", key: key, wantActor: audit.Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.token != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(AdminTokenHeader, tt.token))
+			}
+			if tt.method == "" {
+				tt.method = prefix + "EraseUser"
+			}
+			var actor string
+			handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+				actor = audit.FromContext(ctx).Actor
+				return nil, nil
+			}
+			_, err := UnaryAdmin(prefix, tt.key)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("UnaryAdmin() error = %v, want code %s", err, tt.wantCode)
+			}
+			if actor != tt.wantActor {
+				t.Errorf("UnaryAdmin() actor = %q, want %q", actor, tt.wantActor)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package interceptor
+
+import (
+	"context"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/modular-project/address-service/audit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ActorHeader is the metadata key holding the subject authenticated by the
+// gateway, the changes of the call are audited under it.
+const ActorHeader = "x-actor-id"
+
+func withAuditMeta(ctx context.Context, method string) context.Context {
+	m := audit.Meta{RPC: method, RequestID: RequestID(ctx)}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(ActorHeader); len(v) > 0 {
+			m.Actor = v[0]
+		}
+	}
+	return audit.NewContext(ctx, m)
+}
+
+// UnaryAuditMeta gives the changes of the call its actor, method and
+// request ID, it must run after UnaryRequestID.
+func UnaryAuditMeta() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(withAuditMeta(ctx, info.FullMethod), req)
+	}
+}
+
+// StreamAuditMeta is the streaming counterpart of UnaryAuditMeta.
+func StreamAuditMeta() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		w := grpc_middleware.WrapServerStream(ss)
+		w.WrappedContext = withAuditMeta(ss.Context(), info.FullMethod)
+		return handler(srv, w)
+	}
+}
@@ -0,0 +1,59 @@
+package interceptor
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// RequestIDHeader is the metadata key used to receive and return the request ID.
+	RequestIDHeader = "x-request-id"
+	// RequestIDTag is the grpc_ctxtags key holding the request ID, every
+	// logger extracted from the context includes it.
+	RequestIDTag = "request_id"
+)
+
+type requestIDKey struct{}
+
+// RequestID returns the ID assigned to the current call, or an empty string.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func withRequestID(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(RequestIDHeader); len(v) > 0 {
+			id = v[0]
+		}
+	}
+	if id == "" {
+		id = uuid.NewString()
+	}
+	grpc_ctxtags.Extract(ctx).Set(RequestIDTag, id)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, id))
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// UnaryRequestID reuses the caller's x-request-id or generates a new one,
+// it must run after grpc_ctxtags.
+func UnaryRequestID() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(withRequestID(ctx), req)
+	}
+}
+
+// StreamRequestID is the streaming counterpart of UnaryRequestID.
+func StreamRequestID() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		w := grpc_middleware.WrapServerStream(ss)
+		w.WrappedContext = withRequestID(ss.Context())
+		return handler(srv, w)
+	}
+}
@@ -0,0 +1,69 @@
+package interceptor
+
+import (
+	"context"
+	"strings"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
+	"github.com/modular-project/address-service/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// TenantHeader is the metadata key holding the tenant of the call.
+	TenantHeader = "x-tenant-id"
+	// TenantTag is the grpc_ctxtags key holding the tenant.
+	TenantTag = "tenant_id"
+)
+
+// withTenant resolves the tenant of the call from its metadata, def is used
+// when it is missing, an empty def makes the header mandatory.
+func withTenant(ctx context.Context, method, def string) (context.Context, error) {
+	// the health checks are not bound to a tenant
+	if strings.HasPrefix(method, "/grpc.health.") {
+		return ctx, nil
+	}
+	id := def
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(TenantHeader); len(v) > 0 {
+			id = v[0]
+		}
+	}
+	if id == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "missing %s", TenantHeader)
+	}
+	if !tenant.Valid(id) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s %q", TenantHeader, id)
+	}
+	grpc_ctxtags.Extract(ctx).Set(TenantTag, id)
+	return tenant.NewContext(ctx, id), nil
+}
+
+// UnaryTenant binds the call to the tenant of its x-tenant-id, or to def,
+// it must run after grpc_ctxtags.
+func UnaryTenant(def string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := withTenant(ctx, info.FullMethod, def)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamTenant is the streaming counterpart of UnaryTenant.
+func StreamTenant(def string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := withTenant(ss.Context(), info.FullMethod, def)
+		if err != nil {
+			return err
+		}
+		w := grpc_middleware.WrapServerStream(ss)
+		w.WrappedContext = ctx
+		return handler(srv, w)
+	}
+}
@@ -0,0 +1,66 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+
+	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
+	"github.com/modular-project/address-service/tenant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func Test_withTenant(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		header   string
+		def      string
+		want     string
+		wantCode codes.Code
+	}{
+		{
+			name:   "header",
+			header: "brand-b",
+			def:    "punto-y-coma",
+			want:   "brand-b",
+		}, {
+			name: "default",
+			def:  "punto-y-coma",
+			want: "punto-y-coma",
+		}, {
+			name:     "missing without default",
+			wantCode: codes.InvalidArgument,
+		}, {
+			name:     "invalid",
+			header:   "Brand B",
+			def:      "punto-y-coma",
+			wantCode: codes.InvalidArgument,
+		}, {
+			name:   "health check",
+			method: "/grpc.health.v1.Health/Check",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := grpc_ctxtags.SetInContext(context.Background(), grpc_ctxtags.NewTags())
+			if tt.header != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(TenantHeader, tt.header))
+			}
+			if tt.method == "" {
+				tt.method = "/proto.address.address.AddressService/Search"
+			}
+			ctx, err := withTenant(ctx, tt.method, tt.def)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("withTenant() error = %v, want code %s", err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			if got, _ := tenant.FromContext(ctx); got != tt.want {
+				t.Errorf("withTenant() tenant = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/modular-project/address-service/audit"
+	"github.com/modular-project/address-service/controller"
+	"github.com/modular-project/address-service/metrics"
+	"github.com/modular-project/address-service/model"
+)
+
+// Status of an imported row.
+const (
+	Created = "created"
+	Updated = "updated"
+	// Valid rows were validated and geocoded by a dry run
+	Valid   = "valid"
+	Invalid = "invalid"
+	Failed  = "failed"
+)
+
+type Result struct {
+	Line   int    `json:"line"`
+	Key    string `json:"key,omitempty"`
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+	// Ambiguous rows are imported, their location should be checked
+	Ambiguous bool   `json:"ambiguous,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// Report has a result per row ordered by line.
+type Report struct {
+	Results []Result `json:"results"`
+	DryRun  bool     `json:"dry_run"`
+}
+
+// Count returns the number of rows with status.
+func (r Report) Count(status string) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Ambiguous returns the number of rows geocoded with an ambiguous location.
+func (r Report) Ambiguous() int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Ambiguous {
+			n++
+		}
+	}
+	return n
+}
+
+type Storager interface {
+	Upsert(context.Context, *model.Address) (string, model.Address, bool, error)
+}
+
+// Importer geocodes and upserts establishments by their external key.
+type Importer struct {
+	gc      controller.GeoCoder
+	st      Storager
+	ob      controller.Outbox
+	au      controller.Auditor
+	workers int
+}
+
+// New returns an Importer geocoding up to workers rows at the same time, the
+// events of the upserts go to ob and their audit entries to au.
+func New(gc controller.GeoCoder, st Storager, ob controller.Outbox, au controller.Auditor, workers int) Importer {
+	if workers < 1 {
+		workers = 1
+	}
+	return Importer{gc: gc, st: st, ob: ob, au: au, workers: workers}
+}
+
+// Validate returns the problem of a, if any.
+func Validate(a *model.Address) error {
+	switch {
+	case a.ExternalKey == "":
+		return errors.New("key is required")
+	case a.Street == "":
+		return errors.New("street is required")
+	case a.City == "":
+		return errors.New("city is required")
+	case a.State == "":
+		return errors.New("state is required")
+	case a.Country == "":
+		return errors.New("country is required")
+	}
+	return nil
+}
+
+// Import reads every row of src, a dry run validates and geocodes them
+// without writing. The error is only set when src fails, the rows read
+// until then are reported.
+func (im Importer) Import(ctx context.Context, src Source, dryRun bool) (Report, error) {
+	rows := make(chan Row)
+	results := make(chan Result)
+	var wg sync.WaitGroup
+	for i := 0; i < im.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range rows {
+				results <- im.row(ctx, r, dryRun)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var srcErr error
+	go func() {
+		defer close(rows)
+		// the same key twice in a file would race between the workers
+		keys := make(map[string]int)
+		for {
+			r, err := src.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				srcErr = err
+				return
+			}
+			if k := r.Address.ExternalKey; r.Err == nil && k != "" {
+				if l, ok := keys[k]; ok {
+					r.Err = fmt.Errorf("key %q already in line %d", k, l)
+				} else {
+					keys[k] = r.Line
+				}
+			}
+			select {
+			case rows <- r:
+			case <-ctx.Done():
+				srcErr = ctx.Err()
+				return
+			}
+		}
+	}()
+
+	rep := Report{DryRun: dryRun}
+	for res := range results {
+		rep.Results = append(rep.Results, res)
+	}
+	sort.Slice(rep.Results, func(i, j int) bool { return rep.Results[i].Line < rep.Results[j].Line })
+	return rep, srcErr
+}
+
+func (im Importer) row(ctx context.Context, r Row, dryRun bool) Result {
+	res := Result{Line: r.Line, Key: r.Address.ExternalKey}
+	if r.Err == nil {
+		r.Err = Validate(&r.Address)
+	}
+	if r.Err != nil {
+		res.Status, res.Message = Invalid, r.Err.Error()
+		return res
+	}
+	if ctx.Err() != nil {
+		res.Status, res.Message = Failed, ctx.Err().Error()
+		return res
+	}
+	loc, err := im.gc.GeoCode(ctx, r.Address.String())
+	if err != nil {
+		res.Status, res.Message = Failed, fmt.Sprintf("geocode: %s", err)
+		return res
+	}
+	if loc.Ambiguous() {
+		res.Ambiguous = true
+		res.Message = fmt.Sprintf("%d matches, partial %t", loc.Matches, loc.Partial)
+	}
+	if dryRun {
+		res.Status = Valid
+		return res
+	}
+	r.Address.SetLocation(loc, time.Now())
+	var id string
+	var created bool
+	err = im.ob.Atomically(ctx, func(ctx context.Context) ([]model.Event, error) {
+		var err error
+		var before model.Address
+		if id, before, created, err = im.st.Upsert(ctx, &r.Address); err != nil {
+			return nil, err
+		}
+		typ, op := model.EstablishmentUpdated, model.AuditUpdate
+		if created {
+			typ, op = model.EstablishmentCreated, model.AuditCreate
+		}
+		e := audit.NewEntry(ctx, op, metrics.Establishment, id, 0, before, r.Address)
+		if err := im.au.Record(ctx, e); err != nil {
+			return nil, fmt.Errorf("record audit: %w", err)
+		}
+		return []model.Event{model.NewEvent(typ, id, 0)}, nil
+	})
+	if err != nil {
+		res.Status, res.Message = Failed, fmt.Sprintf("upsert: %s", err)
+		return res
+	}
+	res.ID, res.Status = id, Updated
+	if created {
+		res.Status = Created
+		metrics.AddressCreated(metrics.Establishment)
+	}
+	return res
+}
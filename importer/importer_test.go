@@ -0,0 +1,110 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/modular-project/address-service/controller"
+	"github.com/modular-project/address-service/model"
+)
+
+type fakeGeoCoder struct{}
+
+func (fakeGeoCoder) GeoCode(_ context.Context, add string) (model.Location, error) {
+	switch {
+	case strings.Contains(add, "Nowhere"):
+		return model.Location{}, errors.New("no results")
+	case strings.Contains(add, "Juárez"):
+		return model.Location{Type: "Point", Coordinates: []float64{-103.3, 20.6}, Matches: 3}, nil
+	}
+	return model.Location{Type: "Point", Coordinates: []float64{-103.3, 20.6}, Matches: 1}, nil
+}
+
+type fakeStorage struct {
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+func (f *fakeStorage) Upsert(_ context.Context, a *model.Address) (string, model.Address, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	created := !f.keys[a.ExternalKey]
+	f.keys[a.ExternalKey] = true
+	return "id-" + a.ExternalKey, model.Address{}, created, nil
+}
+
+const csvRows = `key,street,suburb,city,pc,state,country
+s1,Av. Vallarta 1,Centro,Guadalajara,44100,Jalisco,México
+s2,Av. Juárez 2,Centro,Guadalajara,44100,Jalisco,México
+s3,,Centro,Guadalajara,44100,Jalisco,México
+s4,Calle 5,Nowhere,Guadalajara,44100,Jalisco,México
+s1,Av. Vallarta 1,Centro,Guadalajara,44100,Jalisco,México
+s5,"unterminated,Centro
+`
+
+const jsonlRows = `{"key":"s1","street":"Av. Vallarta 1","city":"Guadalajara","state":"Jalisco","country":"México"}
+{"key":"s2","street":"Av. Juárez 2","city":"Guadalajara","state":"Jalisco","country":"México"}
+{"key":"s3","city":"Guadalajara","state":"Jalisco","country":"México"}
+{"key":"s4","street":"Calle 5","suburb":"Nowhere","city":"Guadalajara","state":"Jalisco","country":"México"}
+
+{"key":"s1","street":"Av. Vallarta 1","city":"Guadalajara","state":"Jalisco","country":"México"}
+{"key":"s5","street":1}
+`
+
+func TestImporter_Import(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		dryRun bool
+		want   []string
+	}{
+		{
+			name:   "csv",
+			format: "csv",
+			input:  csvRows,
+			want:   []string{Updated, Created, Invalid, Failed, Invalid, Invalid},
+		}, {
+			name:   "jsonl",
+			format: "jsonl",
+			input:  jsonlRows,
+			want:   []string{Updated, Created, Invalid, Failed, Invalid, Invalid},
+		}, {
+			name:   "dry run",
+			format: "csv",
+			input:  csvRows,
+			dryRun: true,
+			want:   []string{Valid, Valid, Invalid, Failed, Invalid, Invalid},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &fakeStorage{keys: map[string]bool{"s1": true}}
+			src, err := NewSource(strings.NewReader(tt.input), tt.format)
+			if err != nil {
+				t.Fatalf("NewSource() error = %v", err)
+			}
+			rep, err := New(fakeGeoCoder{}, st, controller.NoOutbox, controller.NoAuditor, 3).Import(context.Background(), src, tt.dryRun)
+			if err != nil {
+				t.Fatalf("Importer.Import() error = %v", err)
+			}
+			if len(rep.Results) != len(tt.want) {
+				t.Fatalf("Importer.Import() = %d results, want %d: %+v", len(rep.Results), len(tt.want), rep.Results)
+			}
+			for i, res := range rep.Results {
+				if res.Status != tt.want[i] {
+					t.Errorf("Importer.Import() row %d = %s (%s), want %s", res.Line, res.Status, res.Message, tt.want[i])
+				}
+			}
+			if !rep.Results[1].Ambiguous || rep.Ambiguous() != 1 {
+				t.Errorf("Importer.Import() ambiguous rows = %d, want the second one", rep.Ambiguous())
+			}
+			if tt.dryRun && len(st.keys) != 1 {
+				t.Errorf("Importer.Import() wrote %d keys in a dry run", len(st.keys)-1)
+			}
+		})
+	}
+}
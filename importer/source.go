@@ -0,0 +1,145 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/modular-project/address-service/model"
+)
+
+// Row is an establishment to import, Err is set when the row could not be
+// parsed, the import goes on with the next one.
+type Row struct {
+	Line    int
+	Address model.Address
+	Err     error
+}
+
+// Source returns the rows to import until io.EOF, any other error stops the
+// import.
+type Source interface {
+	Next() (Row, error)
+}
+
+// record is a row in JSON Lines, the CSV header uses the same names.
+type record struct {
+	Key     string `json:"key"`
+	Street  string `json:"street"`
+	Suburb  string `json:"suburb"`
+	City    string `json:"city"`
+	PC      string `json:"pc"`
+	State   string `json:"state"`
+	Country string `json:"country"`
+}
+
+func (r record) address() model.Address {
+	return model.Address{
+		ExternalKey: strings.TrimSpace(r.Key),
+		Street:      strings.TrimSpace(r.Street),
+		Suburb:      strings.TrimSpace(r.Suburb),
+		City:        strings.TrimSpace(r.City),
+		PostalCode:  strings.TrimSpace(r.PC),
+		State:       strings.TrimSpace(r.State),
+		Country:     strings.TrimSpace(r.Country),
+	}
+}
+
+// NewSource reads r in format, csv or jsonl.
+func NewSource(r io.Reader, format string) (Source, error) {
+	switch format {
+	case "csv":
+		return newCSVSource(r)
+	case "jsonl", "ndjson":
+		return &jsonlSource{s: bufio.NewScanner(r)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, want csv or jsonl", format)
+}
+
+type csvSource struct {
+	r    *csv.Reader
+	cols map[string]int
+	line int
+}
+
+// newCSVSource reads the header, it must have a key column and may have any
+// of the other record columns in any order.
+func newCSVSource(r io.Reader) (*csvSource, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	h, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	cols := make(map[string]int, len(h))
+	for i, c := range h {
+		c = strings.ToLower(strings.TrimSpace(c))
+		switch c {
+		case "key", "street", "suburb", "city", "pc", "state", "country":
+			cols[c] = i
+		default:
+			return nil, fmt.Errorf("unknown column %q", c)
+		}
+	}
+	if _, ok := cols["key"]; !ok {
+		return nil, errors.New("missing key column")
+	}
+	return &csvSource{r: cr, cols: cols, line: 1}, nil
+}
+
+func (s *csvSource) Next() (Row, error) {
+	rec, err := s.r.Read()
+	if err == io.EOF {
+		return Row{}, io.EOF
+	}
+	s.line++
+	if err != nil {
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			return Row{Line: s.line, Err: err}, nil
+		}
+		return Row{}, fmt.Errorf("read: %w", err)
+	}
+	get := func(c string) string {
+		if i, ok := s.cols[c]; ok && i < len(rec) {
+			return rec[i]
+		}
+		return ""
+	}
+	r := record{
+		Key: get("key"), Street: get("street"), Suburb: get("suburb"), City: get("city"),
+		PC: get("pc"), State: get("state"), Country: get("country"),
+	}
+	return Row{Line: s.line, Address: r.address()}, nil
+}
+
+type jsonlSource struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func (s *jsonlSource) Next() (Row, error) {
+	for s.s.Scan() {
+		s.line++
+		b := strings.TrimSpace(s.s.Text())
+		if b == "" {
+			continue
+		}
+		var r record
+		d := json.NewDecoder(strings.NewReader(b))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&r); err != nil {
+			return Row{Line: s.line, Err: fmt.Errorf("decode: %w", err)}, nil
+		}
+		return Row{Line: s.line, Address: r.address()}, nil
+	}
+	if err := s.s.Err(); err != nil {
+		return Row{}, fmt.Errorf("read: %w", err)
+	}
+	return Row{}, io.EOF
+}
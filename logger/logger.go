@@ -0,0 +1,31 @@
+package logger
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// New returns a JSON logger writing to stderr at the given level
+// (debug, info, warn, error). Street lines and user IDs are redacted
+// from every entry before it is written, the user IDs are hashed by h.
+func New(level string, h UserHasher) (*zap.Logger, error) {
+	lvl := zap.NewAtomicLevel()
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("level: %w", err)
+		}
+	}
+	cfg := zap.NewProductionConfig()
+	cfg.Level = lvl
+	cfg.EncoderConfig.TimeKey = "time"
+	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	l, err := cfg.Build(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return NewRedactCore(c, h)
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("build: %w", err)
+	}
+	return l, nil
+}
@@ -0,0 +1,164 @@
+package logger
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	// streetKeys are replaced with a fixed placeholder.
+	streetKeys = map[string]bool{
+		"street": true,
+		"suburb": true,
+		"line1":  true,
+		"line2":  true,
+	}
+	// userKeys are replaced with a keyed hash so entries of the same user
+	// can still be correlated.
+	userKeys = map[string]bool{
+		"user_id": true,
+		"uid":     true,
+	}
+)
+
+// UserHasher pseudonymizes user IDs with an HMAC-SHA256, without its key
+// the sequential IDs can not be found back by enumeration. Rotating the key
+// changes every pseudonym.
+type UserHasher struct {
+	key []byte
+}
+
+// NewUserHasher returns the hasher of key. A random key is used when empty,
+// the pseudonyms then only correlate within the process.
+func NewUserHasher(key []byte) (UserHasher, error) {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return UserHasher{}, fmt.Errorf("random key: %w", err)
+		}
+	}
+	return UserHasher{key: key}, nil
+}
+
+// Hash returns the pseudonym of the user ID uID.
+func (h UserHasher) Hash(uID string) string {
+	m := hmac.New(sha256.New, h.key)
+	m.Write([]byte(uID))
+	return "u:" + hex.EncodeToString(m.Sum(nil)[:8])
+}
+
+type redactCore struct {
+	zapcore.Core
+	h UserHasher
+}
+
+// NewRedactCore wraps c so fields holding street lines or user IDs never
+// reach the underlying writer in clear text, the user IDs are hashed by h.
+func NewRedactCore(c zapcore.Core, h UserHasher) zapcore.Core {
+	return redactCore{c, h}
+}
+
+func (rc redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactCore{rc.Core.With(Redact(fields, rc.h)), rc.h}
+}
+
+func (rc redactCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if rc.Enabled(e.Level) {
+		return ce.AddCore(e, rc)
+	}
+	return ce
+}
+
+func (rc redactCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	return rc.Core.Write(e, Redact(fields, rc.h))
+}
+
+// Redact returns a copy of fields with sensitive values replaced, the user
+// IDs are hashed by h.
+func Redact(fields []zapcore.Field, h UserHasher) []zapcore.Field {
+	var out []zapcore.Field
+	for i := range fields {
+		k := fields[i].Key
+		if !streetKeys[k] && !userKeys[k] {
+			continue
+		}
+		if out == nil {
+			out = make([]zapcore.Field, len(fields))
+			copy(out, fields)
+		}
+		if streetKeys[k] {
+			out[i] = zap.String(k, redacted)
+			continue
+		}
+		out[i] = zap.String(k, h.Hash(fieldValue(fields[i])))
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
+
+func fieldValue(f zapcore.Field) string {
+	switch f.Type {
+	case zapcore.StringType:
+		return f.String
+	case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+		return fmt.Sprint(f.Integer)
+	case zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type, zapcore.UintptrType:
+		return fmt.Sprint(uint64(f.Integer))
+	default:
+		return fmt.Sprint(f.Interface)
+	}
+}
+
+type address model.Address
+
+func (a address) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("id", a.ID.Hex())
+	enc.AddString("city", a.City)
+	enc.AddString("pc", a.PostalCode)
+	enc.AddString("state", a.State)
+	enc.AddString("country", a.Country)
+	return nil
+}
+
+// Address logs the non personal components of a, street and suburb are
+// always left out.
+func Address(key string, a model.Address) zap.Field {
+	return zap.Object(key, address(a))
+}
+
+// QueryKeys logs the fields and operators of the Mongo query q without
+// their values, which may hold street or suburb patterns. The core only
+// redacts fields by their own key, not the values nested in them.
+func QueryKeys(key string, q primitive.D) zap.Field {
+	return zap.Strings(key, queryKeys(nil, q))
+}
+
+func queryKeys(keys []string, v interface{}) []string {
+	switch v := v.(type) {
+	case primitive.D:
+		for _, e := range v {
+			keys = queryKeys(append(keys, e.Key), e.Value)
+		}
+	case primitive.M:
+		for k, e := range v {
+			keys = queryKeys(append(keys, k), e)
+		}
+	case primitive.A:
+		for _, e := range v {
+			keys = queryKeys(keys, e)
+		}
+	}
+	return keys
+}
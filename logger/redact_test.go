@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var testHasher = UserHasher{key: []byte("test")}
+
+func TestRedactCore(t *testing.T) {
+	tests := []struct {
+		name  string
+		field zap.Field
+		want  interface{}
+	}{
+		{
+			name:  "street is redacted",
+			field: zap.String("street", "Blvd. Marcelino García Barragán 1421"),
+			want:  redacted,
+		}, {
+			name:  "line2 is redacted",
+			field: zap.String("line2", "Olímpica"),
+			want:  redacted,
+		}, {
+			name:  "uint user id is hashed",
+			field: zap.Uint64("user_id", 1),
+			want:  testHasher.Hash("1"),
+		}, {
+			name:  "string user id is hashed",
+			field: zap.String("user_id", "1"),
+			want:  testHasher.Hash("1"),
+		}, {
+			name:  "city is kept",
+			field: zap.String("city", "Guadalajara"),
+			want:  "Guadalajara",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			l := zap.New(NewRedactCore(core, testHasher))
+			l.Info("msg", tt.field)
+			l.With(tt.field).Info("with")
+			for _, e := range logs.All() {
+				got := e.ContextMap()[tt.field.Key]
+				if got != tt.want {
+					t.Errorf("%s: %s = %v, want %v", e.Message, tt.field.Key, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAddress(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := zap.New(NewRedactCore(core, testHasher))
+	l.Info("msg", Address("address", model.Address{Street: "test 1", Suburb: "sub", City: "city test"}))
+	m, ok := logs.All()[0].ContextMap()["address"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("address is not an object")
+	}
+	if _, ok := m["street"]; ok {
+		t.Errorf("street must not be logged")
+	}
+	if _, ok := m["suburb"]; ok {
+		t.Errorf("suburb must not be logged")
+	}
+	if m["city"] != "city test" {
+		t.Errorf("city = %v, want %v", m["city"], "city test")
+	}
+}
+
+func TestQueryKeys(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := zap.New(NewRedactCore(core, testHasher))
+	q := primitive.D{
+		{Key: "street", Value: primitive.Regex{Pattern: "hidalgo 1421", Options: "i"}},
+		{Key: "$or", Value: primitive.A{primitive.D{{Key: "suburb", Value: "Americana"}}}},
+	}
+	l.Info("msg", QueryKeys("query", q))
+	got := logs.All()[0].ContextMap()["query"]
+	want := []interface{}{"street", "$or", "suburb"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("query = %v, want %v", got, want)
+	}
+}
+
+func TestUserHasher_Hash(t *testing.T) {
+	other := UserHasher{key: []byte("rotated")}
+	if got := testHasher.Hash("1"); got != testHasher.Hash("1") || got == other.Hash("1") || got == testHasher.Hash("2") {
+		t.Errorf("UserHasher.Hash(1) = %s, want stable and keyed", got)
+	}
+	h, err := NewUserHasher(nil)
+	if err != nil {
+		t.Fatalf("NewUserHasher() error = %v", err)
+	}
+	if len(h.key) == 0 || h.Hash("1") == (UserHasher{}).Hash("1") {
+		t.Errorf("NewUserHasher() without key = %+v, want a random key", h)
+	}
+}
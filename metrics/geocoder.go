@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/modular-project/address-service/model"
+)
+
+type geoCoder interface {
+	GeoCode(context.Context, string) (model.Location, error)
+}
+
+type instrumentedGeoCoder struct {
+	gc       geoCoder
+	provider string
+}
+
+// NewGeoCoder wraps gc recording call count, latency and errors under the
+// provider label.
+func NewGeoCoder(provider string, gc geoCoder) instrumentedGeoCoder {
+	return instrumentedGeoCoder{gc: gc, provider: provider}
+}
+
+func (ig instrumentedGeoCoder) GeoCode(ctx context.Context, add string) (model.Location, error) {
+	start := time.Now()
+	loc, err := ig.gc.GeoCode(ctx, add)
+	geocodeDuration.WithLabelValues(ig.provider).Observe(time.Since(start).Seconds())
+	r := "ok"
+	if err != nil {
+		r = "error"
+	}
+	geocodeRequests.WithLabelValues(ig.provider, r).Inc()
+	return loc, err
+}
@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "address"
+
+var (
+	geocodeRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "geocoder",
+		Name:      "requests_total",
+		Help:      "Geocoding requests by provider and result.",
+	}, []string{"provider", "result"})
+	geocodeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "geocoder",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of geocoding requests by provider.",
+		Buckets:   []float64{.025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"provider"})
+	geocodeCircuit = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "geocoder",
+		Name:      "circuit_state",
+		Help:      "Circuit breaker state by provider (0 closed, 1 half open, 2 open).",
+	}, []string{"provider"})
+	geocodeBudget = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "geocoder",
+		Name:      "budget_used",
+		Help:      "Requests sent to the provider since the daily budget was reset.",
+	}, []string{"provider"})
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Cache lookups by cache name and result (hit or miss).",
+	}, []string{"cache", "result"})
+	addressesCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "created_total",
+		Help:      "Addresses created by kind (establishment or delivery).",
+	}, []string{"kind"})
+	addressesDeleted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "deleted_total",
+		Help:      "Addresses deleted by kind (establishment or delivery).",
+	}, []string{"kind"})
+	addressesPurged = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "retention",
+		Name:      "purged_total",
+		Help:      "Expired deleted delivery addresses by action (deleted or anonymized).",
+	}, []string{"action"})
+	purgeRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "retention",
+		Name:      "runs_total",
+		Help:      "Runs of the retention purge by result.",
+	}, []string{"result"})
+	auditDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "audit",
+		Name:      "dropped_total",
+		Help:      "Audit entries that could not be recorded after their change, by kind.",
+	}, []string{"kind"})
+	purgeDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "retention",
+		Name:      "run_duration_seconds",
+		Help:      "Duration of the runs of the retention purge.",
+		Buckets:   prometheus.ExponentialBuckets(.1, 4, 8),
+	})
+)
+
+const (
+	Establishment = "establishment"
+	Delivery      = "delivery"
+)
+
+// Circuit breaker states reported by GeocoderCircuit.
+const (
+	CircuitClosed   = 0
+	CircuitHalfOpen = 1
+	CircuitOpen     = 2
+)
+
+// NewServer returns the HTTP server exposing /metrics at addr, it is meant
+// to listen on a different port than the gRPC server.
+func NewServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+}
+
+// CacheResult records a lookup on the named cache.
+func CacheResult(cache string, hit bool) {
+	r := "miss"
+	if hit {
+		r = "hit"
+	}
+	cacheRequests.WithLabelValues(cache, r).Inc()
+}
+
+// AddressCreated increments the created counter of kind.
+func AddressCreated(kind string) {
+	addressesCreated.WithLabelValues(kind).Inc()
+}
+
+// AddressDeleted adds n to the deleted counter of kind.
+func AddressDeleted(kind string, n int64) {
+	if n > 0 {
+		addressesDeleted.WithLabelValues(kind).Add(float64(n))
+	}
+}
+
+// AuditDropped increments the dropped audit entries of kind.
+func AuditDropped(kind string) {
+	auditDropped.WithLabelValues(kind).Inc()
+}
+
+// Actions of the retention purge.
+const (
+	PurgeDeleted    = "deleted"
+	PurgeAnonymized = "anonymized"
+)
+
+// AddressPurged adds n to the purged counter of action.
+func AddressPurged(action string, n int64) {
+	if n > 0 {
+		addressesPurged.WithLabelValues(action).Add(float64(n))
+	}
+}
+
+// PurgeRun records a run of the retention purge that took d.
+func PurgeRun(d time.Duration, err error) {
+	r := "ok"
+	if err != nil {
+		r = "error"
+	}
+	purgeRuns.WithLabelValues(r).Inc()
+	purgeDuration.Observe(d.Seconds())
+}
+
+// GeocoderCircuit records the circuit breaker state of provider.
+func GeocoderCircuit(provider string, state int) {
+	geocodeCircuit.WithLabelValues(provider).Set(float64(state))
+}
+
+// GeocoderBudgetUsed records the requests spent of the daily budget.
+func GeocoderBudgetUsed(provider string, used int) {
+	geocodeBudget.WithLabelValues(provider).Set(float64(used))
+}
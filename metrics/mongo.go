@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var mongoDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Subsystem: "mongo",
+	Name:      "command_duration_seconds",
+	Help:      "Duration of Mongo commands by collection, operation and result.",
+	Buckets:   []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+}, []string{"collection", "operation", "result"})
+
+// commands without a collection as first value are reported with this one
+const noCollection = "-"
+
+type mongoMonitor struct {
+	// collections maps the request ID of a started command to its collection
+	collections sync.Map
+}
+
+// NewCommandMonitor returns a driver command monitor recording every
+// command duration in address_mongo_command_duration_seconds.
+func NewCommandMonitor() *event.CommandMonitor {
+	m := &mongoMonitor{}
+	return &event.CommandMonitor{
+		Started:   m.started,
+		Succeeded: m.succeeded,
+		Failed:    m.failed,
+	}
+}
+
+func (m *mongoMonitor) started(_ context.Context, e *event.CommandStartedEvent) {
+	coll := noCollection
+	if el, err := e.Command.IndexErr(0); err == nil {
+		if s, ok := el.Value().StringValueOK(); ok {
+			coll = s
+		}
+	}
+	m.collections.Store(e.RequestID, coll)
+}
+
+func (m *mongoMonitor) observe(id int64, op string, d time.Duration, result string) {
+	coll := noCollection
+	if v, ok := m.collections.LoadAndDelete(id); ok {
+		coll = v.(string)
+	}
+	mongoDuration.WithLabelValues(coll, op, result).Observe(d.Seconds())
+}
+
+func (m *mongoMonitor) succeeded(_ context.Context, e *event.CommandSucceededEvent) {
+	m.observe(e.RequestID, e.CommandName, time.Duration(e.DurationNanos), "ok")
+}
+
+func (m *mongoMonitor) failed(_ context.Context, e *event.CommandFailedEvent) {
+	m.observe(e.RequestID, e.CommandName, time.Duration(e.DurationNanos), "error")
+}
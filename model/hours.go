@@ -0,0 +1,143 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DateLayout is the layout of the dates of the hours exceptions.
+const DateLayout = "2006-01-02"
+
+// minutesPerDay bounds the minutes of a TimeRange.
+const minutesPerDay = 24 * 60
+
+// TimeRange is open from Open until Close, in minutes since the local
+// midnight. A range can not span midnight, it is split in two days.
+type TimeRange struct {
+	Open  int `bson:"open"`
+	Close int `bson:"close"`
+}
+
+func (r TimeRange) contains(m int) bool {
+	return r.Open <= m && m < r.Close
+}
+
+func (r TimeRange) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", r.Open/60, r.Open%60, r.Close/60, r.Close%60)
+}
+
+// Period is a TimeRange of a day of the week.
+type Period struct {
+	Day       time.Weekday `bson:"day"`
+	TimeRange `bson:",inline"`
+}
+
+// HoursException replaces the weekly hours on a local Date, e.g. a
+// holiday. It is closed all day without Ranges.
+type HoursException struct {
+	Date   string      `bson:"date"`
+	Ranges []TimeRange `bson:"ranges,omitempty"`
+}
+
+// OpeningHours of an establishment in its TimeZone.
+type OpeningHours struct {
+	TimeZone   string           `bson:"time_zone"`
+	Weekly     []Period         `bson:"weekly,omitempty"`
+	Exceptions []HoursException `bson:"exceptions,omitempty"`
+}
+
+// Validate checks the time zone, ranges and dates of h.
+func (h OpeningHours) Validate() error {
+	if h.TimeZone == "" {
+		return errors.New("missing time zone")
+	}
+	if _, err := time.LoadLocation(h.TimeZone); err != nil {
+		return fmt.Errorf("time zone %q: %w", h.TimeZone, err)
+	}
+	valid := func(r TimeRange) error {
+		if r.Open < 0 || r.Close > minutesPerDay || r.Open >= r.Close {
+			return fmt.Errorf("invalid range %s", r)
+		}
+		return nil
+	}
+	for _, p := range h.Weekly {
+		if p.Day < time.Sunday || p.Day > time.Saturday {
+			return fmt.Errorf("invalid day %d", p.Day)
+		}
+		if err := valid(p.TimeRange); err != nil {
+			return fmt.Errorf("%s: %w", p.Day, err)
+		}
+	}
+	seen := make(map[string]bool, len(h.Exceptions))
+	for _, e := range h.Exceptions {
+		if _, err := time.Parse(DateLayout, e.Date); err != nil {
+			return fmt.Errorf("invalid exception date %q", e.Date)
+		}
+		if seen[e.Date] {
+			return fmt.Errorf("repeated exception date %s", e.Date)
+		}
+		seen[e.Date] = true
+		for _, r := range e.Ranges {
+			if err := valid(r); err != nil {
+				return fmt.Errorf("%s: %w", e.Date, err)
+			}
+		}
+	}
+	return nil
+}
+
+// OpenAt reports whether h is open at t, the exception of the local date
+// of t replaces its weekly hours. Hours without time zone are always open.
+func (h OpeningHours) OpenAt(t time.Time) bool {
+	if h.TimeZone == "" {
+		return true
+	}
+	loc, err := time.LoadLocation(h.TimeZone)
+	if err != nil {
+		return false
+	}
+	t = t.In(loc)
+	m := t.Hour()*60 + t.Minute()
+	date := t.Format(DateLayout)
+	for _, e := range h.Exceptions {
+		if e.Date != date {
+			continue
+		}
+		for _, r := range e.Ranges {
+			if r.contains(m) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, p := range h.Weekly {
+		if p.Day == t.Weekday() && p.contains(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// String summarizes h for the audit entries.
+func (h OpeningHours) String() string {
+	if h.TimeZone == "" {
+		return ""
+	}
+	parts := []string{h.TimeZone}
+	for _, p := range h.Weekly {
+		parts = append(parts, fmt.Sprintf("%.3s %s", p.Day, p.TimeRange))
+	}
+	for _, e := range h.Exceptions {
+		rs := make([]string, len(e.Ranges))
+		for i, r := range e.Ranges {
+			rs[i] = r.String()
+		}
+		if len(rs) == 0 {
+			rs = []string{"closed"}
+		}
+		parts = append(parts, e.Date+" "+strings.Join(rs, " "))
+	}
+	return strings.Join(parts, ", ")
+}
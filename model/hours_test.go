@@ -0,0 +1,70 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOpeningHours_OpenAt(t *testing.T) {
+	h := OpeningHours{
+		TimeZone: "America/Mexico_City",
+		Weekly: []Period{
+			{Day: time.Monday, TimeRange: TimeRange{Open: 9 * 60, Close: 14 * 60}},
+			{Day: time.Monday, TimeRange: TimeRange{Open: 16 * 60, Close: 22 * 60}},
+			{Day: time.Saturday, TimeRange: TimeRange{Open: 10 * 60, Close: 24 * 60}},
+		},
+		Exceptions: []HoursException{
+			{Date: "2022-09-16"},
+			{Date: "2022-09-19", Ranges: []TimeRange{{Open: 12 * 60, Close: 18 * 60}}},
+		},
+	}
+	tests := []struct {
+		name string
+		at   string
+		want bool
+	}{
+		{name: "monday morning", at: "2022-09-12T10:00:00-05:00", want: true},
+		{name: "monday break", at: "2022-09-12T15:00:00-05:00"},
+		{name: "close excluded", at: "2022-09-12T22:00:00-05:00"},
+		{name: "local time of another zone", at: "2022-09-12T15:30:00Z", want: true},
+		{name: "sunday", at: "2022-09-11T12:00:00-05:00"},
+		{name: "until midnight", at: "2022-09-10T23:59:00-05:00", want: true},
+		{name: "closed holiday", at: "2022-09-16T12:00:00-05:00"},
+		{name: "exception replaces the week", at: "2022-09-19T10:00:00-05:00"},
+		{name: "exception hours", at: "2022-09-19T17:00:00-05:00", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, err := time.Parse(time.RFC3339, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := h.OpenAt(at); got != tt.want {
+				t.Errorf("OpeningHours.OpenAt(%s) = %t, want %t", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpeningHours_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		h       OpeningHours
+		wantErr bool
+	}{
+		{name: "valid", h: OpeningHours{TimeZone: "UTC", Weekly: []Period{{Day: time.Friday, TimeRange: TimeRange{Open: 0, Close: 24 * 60}}}}},
+		{name: "missing time zone", h: OpeningHours{}, wantErr: true},
+		{name: "unknown time zone", h: OpeningHours{TimeZone: "Mars/Olympus"}, wantErr: true},
+		{name: "spans midnight", h: OpeningHours{TimeZone: "UTC", Weekly: []Period{{Day: time.Friday, TimeRange: TimeRange{Open: 20 * 60, Close: 2 * 60}}}}, wantErr: true},
+		{name: "invalid day", h: OpeningHours{TimeZone: "UTC", Weekly: []Period{{Day: 7, TimeRange: TimeRange{Open: 0, Close: 60}}}}, wantErr: true},
+		{name: "invalid date", h: OpeningHours{TimeZone: "UTC", Exceptions: []HoursException{{Date: "16/09/2022"}}}, wantErr: true},
+		{name: "repeated date", h: OpeningHours{TimeZone: "UTC", Exceptions: []HoursException{{Date: "2022-09-16"}, {Date: "2022-09-16"}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.h.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("OpeningHours.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
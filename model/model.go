@@ -2,6 +2,8 @@ package model
 
 import (
 	"fmt"
+	"math"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -20,18 +22,131 @@ type Location struct {
 	// lat between -90 and 90
 	Type        string    `json:"-"`
 	Coordinates []float64 `json:"-"` // long, Lat
+	// Matches is the number of results of the geocoder and Partial whether
+	// the best one only matched part of the address, more than one match or
+	// a partial one make the location ambiguous. They are not stored.
+	Matches int  `json:"-" bson:"-"`
+	Partial bool `json:"-" bson:"-"`
+	// Provider that found the location and its Precision, e.g. ROOFTOP or
+	// APPROXIMATE, they are stored in the address
+	Provider  string `json:"-" bson:"-"`
+	Precision string `json:"-" bson:"-"`
 }
 
+// Ambiguous reports whether the geocoder was not sure about l.
+func (l Location) Ambiguous() bool {
+	return l.Matches > 1 || l.Partial
+}
+
+// earthRadius in meters.
+const earthRadius = 6371000
+
+// Distance returns the meters between two [lng, lat] points.
+func Distance(a, b []float64) float64 {
+	if len(a) != 2 || len(b) != 2 {
+		return 0
+	}
+	rad := func(d float64) float64 { return d * math.Pi / 180 }
+	dLat, dLng := rad(b[1]-a[1]), rad(b[0]-a[0])
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(rad(a[1]))*math.Cos(rad(b[1]))*math.Pow(math.Sin(dLng/2), 2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+// IsZero reports whether the location is unknown, it is omitted from the
+// documents so the 2dsphere index ignores addresses pending geocoding.
+func (l Location) IsZero() bool {
+	return len(l.Coordinates) == 0
+}
+
+// Geocoding status of an address, documents created before the status
+// existed have none and are geocoded.
+const (
+	GeocodePending = "pending"
+	GeocodeDone    = "done"
+	GeocodeFailed  = "failed"
+)
+
 type Address struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty"`
-	Street     string             `bson:"street,omitempty"`
-	Suburb     string             `bson:"suburb,omitempty"`
-	City       string             `bson:"city,omitempty"`
-	PostalCode string             `bson:"pc,omitempty"`
-	State      string             `bson:"state,omitempty"`
-	Country    string             `bson:"country,omitempty"`
-	Location   Location           `bson:"location"`
-	IsDeleted  bool               `bson:is_deleted,omitempty`
+	ID primitive.ObjectID `bson:"_id,omitempty"`
+	// TenantID is the brand owning the address, set by the storage
+	TenantID string `bson:"tenant_id,omitempty"`
+	// ExternalKey identifies an establishment in the systems it is imported
+	// from, it is unique when set
+	ExternalKey string   `bson:"external_key,omitempty"`
+	Street      string   `bson:"street,omitempty"`
+	Suburb      string   `bson:"suburb,omitempty"`
+	City        string   `bson:"city,omitempty"`
+	PostalCode  string   `bson:"pc,omitempty"`
+	State       string   `bson:"state,omitempty"`
+	Country     string   `bson:"country,omitempty"`
+	Location    Location `bson:"location,omitempty"`
+	// IsDeleted is decoded from the is_deleted set by the deletes, its tag
+	// was malformed before and the older documents still hold an unused
+	// isdeleted field
+	IsDeleted bool `bson:"is_deleted,omitempty"`
+	// DeletedAt starts the retention of a deleted delivery address
+	DeletedAt time.Time `bson:"deleted_at,omitempty"`
+	// Anonymized addresses have no user, street, suburb nor precise location
+	Anonymized bool `bson:"anonymized,omitempty"`
+	// EncryptionKey encrypted the personal data of the address, set by the
+	// storage
+	EncryptionKey string `bson:"enc_key,omitempty"`
+	// Name, Phone, Hours and Suspended describe an establishment, the
+	// suspended ones are not returned by Nearest
+	Name      string        `bson:"name,omitempty"`
+	Phone     string        `bson:"phone,omitempty"`
+	Hours     *OpeningHours `bson:"hours,omitempty"`
+	Suspended bool          `bson:"suspended,omitempty"`
+	// Priority favors an establishment in Nearest, Capacity is the orders it
+	// can prepare at once and Load the ones it reported, only read by
+	// Nearest as it is stored apart
+	Priority int   `bson:"priority,omitempty"`
+	Capacity int   `bson:"capacity,omitempty"`
+	Load     *Load `bson:"load,omitempty"`
+	// GeocodeStatus is pending until a worker finds the location
+	GeocodeStatus   string    `bson:"geocode_status,omitempty"`
+	GeocodeAttempts int       `bson:"geocode_attempts,omitempty"`
+	GeocodeRetryAt  time.Time `bson:"geocode_retry_at,omitempty"`
+	GeocodedAt      time.Time `bson:"geocoded_at,omitempty"`
+	GeocodeProvider string    `bson:"geocode_provider,omitempty"`
+	GeocodeQuality  string    `bson:"geocode_quality,omitempty"`
+}
+
+// Info returns the description of the establishment a.
+func (a Address) Info() EstablishmentInfo {
+	return EstablishmentInfo{
+		Name:      a.Name,
+		Phone:     a.Phone,
+		Hours:     a.Hours,
+		Suspended: a.Suspended,
+		Priority:  a.Priority,
+		Capacity:  a.Capacity,
+	}
+}
+
+// OpenAt reports whether the establishment a is active and open at t.
+func (a Address) OpenAt(t time.Time) bool {
+	if a.Suspended {
+		return false
+	}
+	return a.Hours == nil || a.Hours.OpenAt(t)
+}
+
+// SetLocation stores loc as the location of a geocoded at at.
+func (a *Address) SetLocation(loc Location, at time.Time) {
+	a.Location = loc
+	a.GeocodeStatus = GeocodeDone
+	a.GeocodedAt = at
+	a.GeocodeProvider = loc.Provider
+	a.GeocodeQuality = loc.Precision
+}
+
+// Status returns the geocoding status of a, done when it has none.
+func (a Address) Status() string {
+	if a.GeocodeStatus == "" {
+		return GeocodeDone
+	}
+	return a.GeocodeStatus
 }
 
 type Delivery struct {
@@ -49,6 +164,194 @@ type Search struct {
 	Offset  int64
 	OrderBy primitive.D
 	Querys  primitive.D
+	Availability
+}
+
+// Availability restricts the establishments of Nearest and Search.
+type Availability struct {
+	IncludeSuspended bool
+	// OpenAt excludes the establishments closed at the time unless zero,
+	// the ones without hours are always open
+	OpenAt time.Time
+}
+
+// EstablishmentInfo is the description of an establishment besides its
+// address.
+type EstablishmentInfo struct {
+	Name      string
+	Phone     string
+	Hours     *OpeningHours
+	Suspended bool
+	Priority  int
+	Capacity  int
+}
+
+// Load is the number of orders an establishment was preparing at a time.
+type Load struct {
+	Active     int       `bson:"active"`
+	ReportedAt time.Time `bson:"reported_at"`
+}
+
+// Candidate is an establishment of Nearest at Distance meters.
+type Candidate struct {
+	Address  `bson:",inline"`
+	Distance float64 `bson:"distance"`
+}
+
+// Filter selects the addresses to export, empty fields match any value.
+type Filter struct {
+	City       string
+	State      string
+	Country    string
+	PostalCode string
+	// UserID only applies to delivery addresses
+	UserID        uint64
+	GeocodeStatus string
+	// Since and Until bound the creation time
+	Since          time.Time
+	Until          time.Time
+	IncludeDeleted bool
+}
+
+// StaleFilter selects the addresses to geocode again, all the set fields
+// must match.
+type StaleFilter struct {
+	MissingLocation bool
+	// Provider of the location, NoProvider matches the addresses geocoded
+	// before the provider was stored
+	Provider string
+	// GeocodedBefore also matches the addresses without geocoding time
+	GeocodedBefore time.Time
+	Qualities      []string
+}
+
+// NoProvider is the StaleFilter.Provider of the addresses without provider.
+const NoProvider = "none"
+
+// BackfillCheckpoint is the progress of a backfill job on a collection.
+type BackfillCheckpoint struct {
+	ID        string             `bson:"_id"`
+	LastID    primitive.ObjectID `bson:"last_id,omitempty"`
+	Processed int                `bson:"processed"`
+	Updated   int                `bson:"updated"`
+	Failed    int                `bson:"failed"`
+	Done      bool               `bson:"done"`
+	UpdatedAt time.Time          `bson:"updated_at"`
+}
+
+// BackfillChange records the new location of an address, Error is set when
+// it could not be geocoded.
+type BackfillChange struct {
+	Job         string             `bson:"job" json:"job"`
+	Kind        string             `bson:"kind" json:"kind"`
+	AddressID   primitive.ObjectID `bson:"address_id" json:"address_id"`
+	Before      []float64          `bson:"before,omitempty" json:"before,omitempty"`
+	After       []float64          `bson:"after,omitempty" json:"after,omitempty"`
+	ShiftMeters float64            `bson:"shift_meters" json:"shift_meters"`
+	Quality     string             `bson:"quality,omitempty" json:"quality,omitempty"`
+	Error       string             `bson:"error,omitempty" json:"error,omitempty"`
+	DryRun      bool               `bson:"dry_run" json:"dry_run"`
+	At          time.Time          `bson:"at" json:"at"`
+}
+
+// Types of the domain events.
+const (
+	EstablishmentCreated = "establishment.created"
+	EstablishmentUpdated = "establishment.updated"
+	EstablishmentRemoved = "establishment.removed"
+	DeliveryCreated      = "delivery.created"
+	DeliveryDeleted      = "delivery.deleted"
+	DeliveryRestored     = "delivery.restored"
+	// UserErased is sent once the addresses of a user are erased, its
+	// aggregate is the user
+	UserErased = "user.erased"
+	// AddressGeocoded is sent when a pending address gets its location
+	AddressGeocoded = "address.geocoded"
+)
+
+// Event is a change of an address, the aggregate, written to the outbox with
+// the change itself.
+type Event struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Type        string             `bson:"type" json:"type"`
+	AggregateID string             `bson:"aggregate_id" json:"aggregate_id"`
+	UserID      uint64             `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	TenantID    string             `bson:"tenant_id" json:"tenant_id"`
+	OccurredAt  time.Time          `bson:"occurred_at" json:"occurred_at"`
+	PublishedAt time.Time          `bson:"published_at,omitempty" json:"-"`
+	Attempts    int                `bson:"attempts,omitempty" json:"-"`
+	DeadAt      time.Time          `bson:"dead_at,omitempty" json:"-"`
+}
+
+// NewEvent returns an event of typ about the address with id aID.
+func NewEvent(typ, aID string, uID uint64) Event {
+	return Event{Type: typ, AggregateID: aID, UserID: uID, OccurredAt: time.Now()}
+}
+
+// Operations of an AuditEntry.
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	// AuditErase has no changes, they would keep what was erased
+	AuditErase = "erase"
+)
+
+// FieldChange is the value of a field before and after an operation.
+type FieldChange struct {
+	Field  string `bson:"field" json:"field"`
+	Before string `bson:"before,omitempty" json:"before,omitempty"`
+	After  string `bson:"after,omitempty" json:"after,omitempty"`
+}
+
+// AuditEntry records who changed an address, it is never updated.
+type AuditEntry struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID  string             `bson:"tenant_id" json:"tenant_id"`
+	Kind      string             `bson:"kind" json:"kind"`
+	AddressID string             `bson:"address_id" json:"address_id"`
+	// UserID owns the delivery address
+	UserID    uint64        `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Op        string        `bson:"op" json:"op"`
+	Actor     string        `bson:"actor" json:"actor"`
+	RPC       string        `bson:"rpc,omitempty" json:"rpc,omitempty"`
+	RequestID string        `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	At        time.Time     `bson:"at" json:"at"`
+	Changes   []FieldChange `bson:"changes,omitempty" json:"changes,omitempty"`
+	// Redacted entries lost the values of their changes with the erasure of
+	// their user
+	Redacted bool `bson:"redacted,omitempty" json:"redacted,omitempty"`
+}
+
+// AuditQuery selects the history of an address or of the delivery
+// addresses of a user, newest first.
+type AuditQuery struct {
+	AddressID string
+	UserID    uint64
+	// Before excludes the entries at or after it, to page back
+	Before time.Time
+	Limit  int64
+}
+
+// Operations of an EstablishmentChange.
+const (
+	// ChangeStart is the first change of a watch, it only carries the token
+	ChangeStart  = "start"
+	ChangeInsert = "insert"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// EstablishmentChange is a change of the establishments, Token resumes a
+// watch right after it.
+type EstablishmentChange struct {
+	Op string
+	ID string
+	// Address after the change, empty on deletes
+	Address Address
+	Token   []byte
+	At      time.Time
 }
 
 func (a Address) String() string {
@@ -0,0 +1,14 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	// Guadalajara cathedral to Minerva roundabout
+	got := Distance([]float64{-103.3474, 20.6767}, []float64{-103.3750, 20.6742})
+	if math.Abs(got-2885) > 10 {
+		t.Errorf("Distance() = %f, want about 2885", got)
+	}
+}
@@ -0,0 +1,64 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+
+	"github.com/modular-project/address-service/model"
+	"go.uber.org/zap"
+)
+
+// Publisher delivers the events to the other services, e.g. through NATS or
+// Kafka. The relay calls it again with the same event when it fails, the
+// subscribers must tolerate duplicates.
+type Publisher interface {
+	Publish(context.Context, model.Event) error
+}
+
+// LogPublisher writes the events to a logger, it stands in for a broker.
+type LogPublisher struct {
+	l *zap.Logger
+}
+
+func NewLogPublisher(l *zap.Logger) LogPublisher {
+	return LogPublisher{l: l}
+}
+
+func (lp LogPublisher) Publish(_ context.Context, e model.Event) error {
+	lp.l.Info("event",
+		zap.String("event_id", e.ID.Hex()), zap.String("type", e.Type),
+		zap.String("aggregate_id", e.AggregateID), zap.Uint64("user_id", e.UserID),
+		zap.Time("occurred_at", e.OccurredAt))
+	return nil
+}
+
+// MemoryPublisher keeps the events in memory, Err makes Publish fail for
+// an aggregate.
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []model.Event
+	Err    func(model.Event) error
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+func (mp *MemoryPublisher) Publish(_ context.Context, e model.Event) error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	if mp.Err != nil {
+		if err := mp.Err(e); err != nil {
+			return err
+		}
+	}
+	mp.events = append(mp.events, e)
+	return nil
+}
+
+// Events returns the events published so far.
+func (mp *MemoryPublisher) Events() []model.Event {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	return append([]model.Event(nil), mp.events...)
+}
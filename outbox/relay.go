@@ -0,0 +1,144 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// leaseName is the lease held by the replica relaying the events.
+const leaseName = "relay"
+
+type Storager interface {
+	Unpublished(ctx context.Context, limit int) ([]model.Event, error)
+	MarkPublished(ctx context.Context, id primitive.ObjectID) error
+	MarkFailed(ctx context.Context, id primitive.ObjectID) error
+	MarkDead(ctx context.Context, id primitive.ObjectID) error
+	AcquireLease(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+}
+
+type Config struct {
+	// Owner identifies the replica, e.g. its hostname
+	Owner        string
+	PollInterval time.Duration
+	BatchSize    int
+	// LeaseTTL is renewed before each event, whose publication must take
+	// less, 30s when zero
+	LeaseTTL time.Duration
+	// MaxAttempts of an event before it is dead-lettered, 0 is unlimited
+	MaxAttempts int
+}
+
+// Relay publishes the events of the outbox at least once. The events of an
+// aggregate are published in order: once one fails the following ones wait
+// for the next round, unless it is dead-lettered after MaxAttempts.
+type Relay struct {
+	st  Storager
+	pub Publisher
+	c   Config
+	l   *zap.Logger
+}
+
+func NewRelay(st Storager, pub Publisher, l *zap.Logger, c Config) Relay {
+	if c.BatchSize < 1 {
+		c.BatchSize = 100
+	}
+	if c.LeaseTTL <= 0 {
+		c.LeaseTTL = 30 * time.Second
+	}
+	return Relay{st: st, pub: pub, c: c, l: l}
+}
+
+// Run relays the events every PollInterval while this replica holds the
+// lease, until ctx is done.
+func (r Relay) Run(ctx context.Context) {
+	t := time.NewTicker(r.c.PollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		ok, err := r.st.AcquireLease(ctx, leaseName, r.c.Owner, r.c.LeaseTTL)
+		if err != nil {
+			r.l.Error("outbox lease", zap.Error(err))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if _, err := r.Relay(ctx); err != nil {
+			r.l.Error("outbox relay", zap.Error(err))
+		}
+	}
+}
+
+// Relay publishes a batch of events and returns how many were published.
+// It stops once the lease is lost.
+func (r Relay) Relay(ctx context.Context) (int, error) {
+	evs, err := r.st.Unpublished(ctx, r.c.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("unpublished: %w", err)
+	}
+	blocked := make(map[string]bool)
+	n := 0
+	for _, e := range evs {
+		if blocked[e.AggregateID] {
+			continue
+		}
+		// renewed for each event so that a slow broker does not let another
+		// replica publish the same aggregates meanwhile
+		ok, err := r.st.AcquireLease(ctx, leaseName, r.c.Owner, r.c.LeaseTTL)
+		if err != nil {
+			return n, fmt.Errorf("acquireLease: %w", err)
+		}
+		if !ok {
+			return n, nil
+		}
+		if err := r.publish(ctx, e); err != nil {
+			blocked[e.AggregateID] = true
+			if err := r.failed(ctx, e, err); err != nil {
+				return n, err
+			}
+			continue
+		}
+		// a crash here publishes e again, hence at least once
+		if err := r.st.MarkPublished(ctx, e.ID); err != nil {
+			return n, fmt.Errorf("markPublished: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// publish sends e within the lease.
+func (r Relay) publish(ctx context.Context, e model.Event) error {
+	ctx, cancel := context.WithTimeout(ctx, r.c.LeaseTTL/2)
+	defer cancel()
+	return r.pub.Publish(ctx, e)
+}
+
+// failed counts the failed publication of e, and dead-letters it after
+// MaxAttempts so that the following events of its aggregate go on.
+func (r Relay) failed(ctx context.Context, e model.Event, perr error) error {
+	attempts := e.Attempts + 1
+	if r.c.MaxAttempts > 0 && attempts >= r.c.MaxAttempts {
+		r.l.Error("event dead-lettered", zap.String("event_id", e.ID.Hex()), zap.String("type", e.Type),
+			zap.String("aggregate_id", e.AggregateID), zap.Int("attempts", attempts), zap.Error(perr))
+		if err := r.st.MarkDead(ctx, e.ID); err != nil {
+			return fmt.Errorf("markDead: %w", err)
+		}
+		return nil
+	}
+	r.l.Warn("publish event", zap.String("event_id", e.ID.Hex()), zap.String("type", e.Type),
+		zap.Int("attempts", attempts), zap.Error(perr))
+	if err := r.st.MarkFailed(ctx, e.ID); err != nil {
+		return fmt.Errorf("markFailed: %w", err)
+	}
+	return nil
+}
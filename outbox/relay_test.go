@@ -0,0 +1,171 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type fakeStorage struct {
+	events []model.Event
+	// leases left before another replica takes it, unlimited when negative
+	leases int
+}
+
+func (f *fakeStorage) Unpublished(_ context.Context, limit int) ([]model.Event, error) {
+	var evs []model.Event
+	for _, e := range f.events {
+		if e.PublishedAt.IsZero() && e.DeadAt.IsZero() && len(evs) < limit {
+			evs = append(evs, e)
+		}
+	}
+	return evs, nil
+}
+
+func (f *fakeStorage) MarkPublished(_ context.Context, id primitive.ObjectID) error {
+	for i := range f.events {
+		if f.events[i].ID == id {
+			f.events[i].PublishedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (f *fakeStorage) MarkFailed(_ context.Context, id primitive.ObjectID) error {
+	for i := range f.events {
+		if f.events[i].ID == id {
+			f.events[i].Attempts++
+		}
+	}
+	return nil
+}
+
+func (f *fakeStorage) MarkDead(_ context.Context, id primitive.ObjectID) error {
+	for i := range f.events {
+		if f.events[i].ID == id {
+			f.events[i].Attempts++
+			f.events[i].DeadAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (f *fakeStorage) AcquireLease(context.Context, string, string, time.Duration) (bool, error) {
+	if f.leases == 0 {
+		return false, nil
+	}
+	f.leases--
+	return true, nil
+}
+
+func newEvents(st *fakeStorage, aggs ...string) {
+	for _, agg := range aggs {
+		ev := model.NewEvent(model.DeliveryCreated, agg, 0)
+		ev.ID = primitive.NewObjectID()
+		st.events = append(st.events, ev)
+	}
+}
+
+func TestRelay_Relay(t *testing.T) {
+	st := &fakeStorage{leases: -1}
+	for _, e := range []struct{ typ, agg string }{
+		{model.DeliveryCreated, "a"},
+		{model.EstablishmentCreated, "b"},
+		{model.DeliveryDeleted, "a"},
+		{model.EstablishmentRemoved, "b"},
+	} {
+		ev := model.NewEvent(e.typ, e.agg, 0)
+		ev.ID = primitive.NewObjectID()
+		st.events = append(st.events, ev)
+	}
+	down := true
+	pub := NewMemoryPublisher()
+	pub.Err = func(e model.Event) error {
+		if down && e.AggregateID == "a" {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	}
+	r := NewRelay(st, pub, zap.NewNop(), Config{BatchSize: 10})
+	tests := []struct {
+		name string
+		down bool
+		want []string
+	}{
+		{
+			name: "failed aggregate waits",
+			down: true,
+			want: []string{model.EstablishmentCreated, model.EstablishmentRemoved},
+		}, {
+			name: "published in order once recovered",
+			want: []string{model.EstablishmentCreated, model.EstablishmentRemoved, model.DeliveryCreated, model.DeliveryDeleted},
+		}, {
+			name: "nothing left",
+			want: []string{model.EstablishmentCreated, model.EstablishmentRemoved, model.DeliveryCreated, model.DeliveryDeleted},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			down = tt.down
+			if _, err := r.Relay(context.Background()); err != nil {
+				t.Fatalf("Relay.Relay() error = %v", err)
+			}
+			var got []string
+			for _, e := range pub.Events() {
+				got = append(got, e.Type)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Relay.Relay() published %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if st.events[0].Attempts != 1 || st.events[2].Attempts != 0 {
+		t.Errorf("Relay.Relay() attempts = %d, %d, want 1, 0", st.events[0].Attempts, st.events[2].Attempts)
+	}
+}
+
+func TestRelay_Relay_deadLetter(t *testing.T) {
+	st := &fakeStorage{leases: -1}
+	newEvents(st, "a", "a")
+	pub := NewMemoryPublisher()
+	poison := st.events[0].ID
+	pub.Err = func(e model.Event) error {
+		if e.ID == poison {
+			return errors.New("rejected")
+		}
+		return nil
+	}
+	r := NewRelay(st, pub, zap.NewNop(), Config{MaxAttempts: 2})
+	for i, want := range []int{0, 0, 1} {
+		got, err := r.Relay(context.Background())
+		if err != nil {
+			t.Fatalf("round %d: Relay.Relay() error = %v", i, err)
+		}
+		if got != want {
+			t.Errorf("round %d: Relay.Relay() = %d, want %d", i, got, want)
+		}
+	}
+	if st.events[0].DeadAt.IsZero() || st.events[0].Attempts != 2 {
+		t.Errorf("Relay.Relay() poison event = %+v, want dead after 2 attempts", st.events[0])
+	}
+}
+
+func TestRelay_Relay_leaseLost(t *testing.T) {
+	st := &fakeStorage{leases: 2}
+	newEvents(st, "a", "b", "c")
+	pub := NewMemoryPublisher()
+	r := NewRelay(st, pub, zap.NewNop(), Config{})
+	got, err := r.Relay(context.Background())
+	if err != nil {
+		t.Fatalf("Relay.Relay() error = %v", err)
+	}
+	if got != 2 || len(pub.Events()) != 2 {
+		t.Errorf("Relay.Relay() = %d, published %d, want 2 before the lease was lost", got, len(pub.Events()))
+	}
+}
@@ -0,0 +1,774 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: addressext/address.proto
+
+package addressext
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// AddressExtServiceClient is the client API for AddressExtService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AddressExtServiceClient interface {
+	ImportEstablishments(ctx context.Context, opts ...grpc.CallOption) (AddressExtService_ImportEstablishmentsClient, error)
+	ExportAddresses(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (AddressExtService_ExportAddressesClient, error)
+	// WatchEstablishments sends the changes of the establishments, a watch
+	// resumed with an expired token fails with FAILED_PRECONDITION
+	WatchEstablishments(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (AddressExtService_WatchEstablishmentsClient, error)
+	// BatchGetEstablishments and BatchGetDeliveries fail with
+	// INVALID_ARGUMENT when there are more ids than the configured maximum
+	BatchGetEstablishments(ctx context.Context, in *BatchGetEstablishmentsRequest, opts ...grpc.CallOption) (*BatchGetResponse, error)
+	BatchGetDeliveries(ctx context.Context, in *BatchGetDeliveriesRequest, opts ...grpc.CallOption) (*BatchGetResponse, error)
+	GetEstablishment(ctx context.Context, in *GetEstablishmentRequest, opts ...grpc.CallOption) (*Establishment, error)
+	// UpdateEstablishmentInfo fails with INVALID_ARGUMENT for invalid hours
+	UpdateEstablishmentInfo(ctx context.Context, in *UpdateEstablishmentInfoRequest, opts ...grpc.CallOption) (*Establishment, error)
+	// NearestEstablishment is Nearest with its description and the scores
+	// of the candidates, the user must have the delivery address
+	NearestEstablishment(ctx context.Context, in *NearestEstablishmentRequest, opts ...grpc.CallOption) (*Establishment, error)
+	// ReportLoad is called by the establishments every few minutes, NOT_FOUND
+	// for an unknown one
+	ReportLoad(ctx context.Context, in *ReportLoadRequest, opts ...grpc.CallOption) (*ReportLoadResponse, error)
+	SearchEstablishments(ctx context.Context, in *SearchEstablishmentsRequest, opts ...grpc.CallOption) (*SearchEstablishmentsResponse, error)
+	// CheckDeliverability tells an anonymous visitor whether the address is
+	// served, it stores nothing
+	CheckDeliverability(ctx context.Context, in *CheckDeliverabilityRequest, opts ...grpc.CallOption) (*CheckDeliverabilityResponse, error)
+}
+
+type addressExtServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAddressExtServiceClient(cc grpc.ClientConnInterface) AddressExtServiceClient {
+	return &addressExtServiceClient{cc}
+}
+
+func (c *addressExtServiceClient) ImportEstablishments(ctx context.Context, opts ...grpc.CallOption) (AddressExtService_ImportEstablishmentsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AddressExtService_ServiceDesc.Streams[0], "/proto.address.ext.AddressExtService/ImportEstablishments", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &addressExtServiceImportEstablishmentsClient{stream}
+	return x, nil
+}
+
+type AddressExtService_ImportEstablishmentsClient interface {
+	Send(*ImportRequest) error
+	CloseAndRecv() (*ImportReport, error)
+	grpc.ClientStream
+}
+
+type addressExtServiceImportEstablishmentsClient struct {
+	grpc.ClientStream
+}
+
+func (x *addressExtServiceImportEstablishmentsClient) Send(m *ImportRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *addressExtServiceImportEstablishmentsClient) CloseAndRecv() (*ImportReport, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ImportReport)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *addressExtServiceClient) ExportAddresses(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (AddressExtService_ExportAddressesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AddressExtService_ServiceDesc.Streams[1], "/proto.address.ext.AddressExtService/ExportAddresses", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &addressExtServiceExportAddressesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type AddressExtService_ExportAddressesClient interface {
+	Recv() (*ExportChunk, error)
+	grpc.ClientStream
+}
+
+type addressExtServiceExportAddressesClient struct {
+	grpc.ClientStream
+}
+
+func (x *addressExtServiceExportAddressesClient) Recv() (*ExportChunk, error) {
+	m := new(ExportChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *addressExtServiceClient) WatchEstablishments(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (AddressExtService_WatchEstablishmentsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AddressExtService_ServiceDesc.Streams[2], "/proto.address.ext.AddressExtService/WatchEstablishments", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &addressExtServiceWatchEstablishmentsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type AddressExtService_WatchEstablishmentsClient interface {
+	Recv() (*EstablishmentChange, error)
+	grpc.ClientStream
+}
+
+type addressExtServiceWatchEstablishmentsClient struct {
+	grpc.ClientStream
+}
+
+func (x *addressExtServiceWatchEstablishmentsClient) Recv() (*EstablishmentChange, error) {
+	m := new(EstablishmentChange)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *addressExtServiceClient) BatchGetEstablishments(ctx context.Context, in *BatchGetEstablishmentsRequest, opts ...grpc.CallOption) (*BatchGetResponse, error) {
+	out := new(BatchGetResponse)
+	err := c.cc.Invoke(ctx, "/proto.address.ext.AddressExtService/BatchGetEstablishments", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *addressExtServiceClient) BatchGetDeliveries(ctx context.Context, in *BatchGetDeliveriesRequest, opts ...grpc.CallOption) (*BatchGetResponse, error) {
+	out := new(BatchGetResponse)
+	err := c.cc.Invoke(ctx, "/proto.address.ext.AddressExtService/BatchGetDeliveries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *addressExtServiceClient) GetEstablishment(ctx context.Context, in *GetEstablishmentRequest, opts ...grpc.CallOption) (*Establishment, error) {
+	out := new(Establishment)
+	err := c.cc.Invoke(ctx, "/proto.address.ext.AddressExtService/GetEstablishment", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *addressExtServiceClient) UpdateEstablishmentInfo(ctx context.Context, in *UpdateEstablishmentInfoRequest, opts ...grpc.CallOption) (*Establishment, error) {
+	out := new(Establishment)
+	err := c.cc.Invoke(ctx, "/proto.address.ext.AddressExtService/UpdateEstablishmentInfo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *addressExtServiceClient) NearestEstablishment(ctx context.Context, in *NearestEstablishmentRequest, opts ...grpc.CallOption) (*Establishment, error) {
+	out := new(Establishment)
+	err := c.cc.Invoke(ctx, "/proto.address.ext.AddressExtService/NearestEstablishment", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *addressExtServiceClient) ReportLoad(ctx context.Context, in *ReportLoadRequest, opts ...grpc.CallOption) (*ReportLoadResponse, error) {
+	out := new(ReportLoadResponse)
+	err := c.cc.Invoke(ctx, "/proto.address.ext.AddressExtService/ReportLoad", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *addressExtServiceClient) SearchEstablishments(ctx context.Context, in *SearchEstablishmentsRequest, opts ...grpc.CallOption) (*SearchEstablishmentsResponse, error) {
+	out := new(SearchEstablishmentsResponse)
+	err := c.cc.Invoke(ctx, "/proto.address.ext.AddressExtService/SearchEstablishments", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *addressExtServiceClient) CheckDeliverability(ctx context.Context, in *CheckDeliverabilityRequest, opts ...grpc.CallOption) (*CheckDeliverabilityResponse, error) {
+	out := new(CheckDeliverabilityResponse)
+	err := c.cc.Invoke(ctx, "/proto.address.ext.AddressExtService/CheckDeliverability", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AddressExtServiceServer is the server API for AddressExtService service.
+// All implementations must embed UnimplementedAddressExtServiceServer
+// for forward compatibility
+type AddressExtServiceServer interface {
+	ImportEstablishments(AddressExtService_ImportEstablishmentsServer) error
+	ExportAddresses(*ExportRequest, AddressExtService_ExportAddressesServer) error
+	// WatchEstablishments sends the changes of the establishments, a watch
+	// resumed with an expired token fails with FAILED_PRECONDITION
+	WatchEstablishments(*WatchRequest, AddressExtService_WatchEstablishmentsServer) error
+	// BatchGetEstablishments and BatchGetDeliveries fail with
+	// INVALID_ARGUMENT when there are more ids than the configured maximum
+	BatchGetEstablishments(context.Context, *BatchGetEstablishmentsRequest) (*BatchGetResponse, error)
+	BatchGetDeliveries(context.Context, *BatchGetDeliveriesRequest) (*BatchGetResponse, error)
+	GetEstablishment(context.Context, *GetEstablishmentRequest) (*Establishment, error)
+	// UpdateEstablishmentInfo fails with INVALID_ARGUMENT for invalid hours
+	UpdateEstablishmentInfo(context.Context, *UpdateEstablishmentInfoRequest) (*Establishment, error)
+	// NearestEstablishment is Nearest with its description and the scores
+	// of the candidates, the user must have the delivery address
+	NearestEstablishment(context.Context, *NearestEstablishmentRequest) (*Establishment, error)
+	// ReportLoad is called by the establishments every few minutes, NOT_FOUND
+	// for an unknown one
+	ReportLoad(context.Context, *ReportLoadRequest) (*ReportLoadResponse, error)
+	SearchEstablishments(context.Context, *SearchEstablishmentsRequest) (*SearchEstablishmentsResponse, error)
+	// CheckDeliverability tells an anonymous visitor whether the address is
+	// served, it stores nothing
+	CheckDeliverability(context.Context, *CheckDeliverabilityRequest) (*CheckDeliverabilityResponse, error)
+	mustEmbedUnimplementedAddressExtServiceServer()
+}
+
+// UnimplementedAddressExtServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAddressExtServiceServer struct {
+}
+
+func (UnimplementedAddressExtServiceServer) ImportEstablishments(AddressExtService_ImportEstablishmentsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ImportEstablishments not implemented")
+}
+func (UnimplementedAddressExtServiceServer) ExportAddresses(*ExportRequest, AddressExtService_ExportAddressesServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportAddresses not implemented")
+}
+func (UnimplementedAddressExtServiceServer) WatchEstablishments(*WatchRequest, AddressExtService_WatchEstablishmentsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEstablishments not implemented")
+}
+func (UnimplementedAddressExtServiceServer) BatchGetEstablishments(context.Context, *BatchGetEstablishmentsRequest) (*BatchGetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetEstablishments not implemented")
+}
+func (UnimplementedAddressExtServiceServer) BatchGetDeliveries(context.Context, *BatchGetDeliveriesRequest) (*BatchGetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetDeliveries not implemented")
+}
+func (UnimplementedAddressExtServiceServer) GetEstablishment(context.Context, *GetEstablishmentRequest) (*Establishment, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEstablishment not implemented")
+}
+func (UnimplementedAddressExtServiceServer) UpdateEstablishmentInfo(context.Context, *UpdateEstablishmentInfoRequest) (*Establishment, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateEstablishmentInfo not implemented")
+}
+func (UnimplementedAddressExtServiceServer) NearestEstablishment(context.Context, *NearestEstablishmentRequest) (*Establishment, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NearestEstablishment not implemented")
+}
+func (UnimplementedAddressExtServiceServer) ReportLoad(context.Context, *ReportLoadRequest) (*ReportLoadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportLoad not implemented")
+}
+func (UnimplementedAddressExtServiceServer) SearchEstablishments(context.Context, *SearchEstablishmentsRequest) (*SearchEstablishmentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchEstablishments not implemented")
+}
+func (UnimplementedAddressExtServiceServer) CheckDeliverability(context.Context, *CheckDeliverabilityRequest) (*CheckDeliverabilityResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckDeliverability not implemented")
+}
+func (UnimplementedAddressExtServiceServer) mustEmbedUnimplementedAddressExtServiceServer() {}
+
+// UnsafeAddressExtServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AddressExtServiceServer will
+// result in compilation errors.
+type UnsafeAddressExtServiceServer interface {
+	mustEmbedUnimplementedAddressExtServiceServer()
+}
+
+func RegisterAddressExtServiceServer(s grpc.ServiceRegistrar, srv AddressExtServiceServer) {
+	s.RegisterService(&AddressExtService_ServiceDesc, srv)
+}
+
+func _AddressExtService_ImportEstablishments_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AddressExtServiceServer).ImportEstablishments(&addressExtServiceImportEstablishmentsServer{stream})
+}
+
+type AddressExtService_ImportEstablishmentsServer interface {
+	SendAndClose(*ImportReport) error
+	Recv() (*ImportRequest, error)
+	grpc.ServerStream
+}
+
+type addressExtServiceImportEstablishmentsServer struct {
+	grpc.ServerStream
+}
+
+func (x *addressExtServiceImportEstablishmentsServer) SendAndClose(m *ImportReport) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *addressExtServiceImportEstablishmentsServer) Recv() (*ImportRequest, error) {
+	m := new(ImportRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _AddressExtService_ExportAddresses_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AddressExtServiceServer).ExportAddresses(m, &addressExtServiceExportAddressesServer{stream})
+}
+
+type AddressExtService_ExportAddressesServer interface {
+	Send(*ExportChunk) error
+	grpc.ServerStream
+}
+
+type addressExtServiceExportAddressesServer struct {
+	grpc.ServerStream
+}
+
+func (x *addressExtServiceExportAddressesServer) Send(m *ExportChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _AddressExtService_WatchEstablishments_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AddressExtServiceServer).WatchEstablishments(m, &addressExtServiceWatchEstablishmentsServer{stream})
+}
+
+type AddressExtService_WatchEstablishmentsServer interface {
+	Send(*EstablishmentChange) error
+	grpc.ServerStream
+}
+
+type addressExtServiceWatchEstablishmentsServer struct {
+	grpc.ServerStream
+}
+
+func (x *addressExtServiceWatchEstablishmentsServer) Send(m *EstablishmentChange) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _AddressExtService_BatchGetEstablishments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetEstablishmentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AddressExtServiceServer).BatchGetEstablishments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.address.ext.AddressExtService/BatchGetEstablishments",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AddressExtServiceServer).BatchGetEstablishments(ctx, req.(*BatchGetEstablishmentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AddressExtService_BatchGetDeliveries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetDeliveriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AddressExtServiceServer).BatchGetDeliveries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.address.ext.AddressExtService/BatchGetDeliveries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AddressExtServiceServer).BatchGetDeliveries(ctx, req.(*BatchGetDeliveriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AddressExtService_GetEstablishment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEstablishmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AddressExtServiceServer).GetEstablishment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.address.ext.AddressExtService/GetEstablishment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AddressExtServiceServer).GetEstablishment(ctx, req.(*GetEstablishmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AddressExtService_UpdateEstablishmentInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateEstablishmentInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AddressExtServiceServer).UpdateEstablishmentInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.address.ext.AddressExtService/UpdateEstablishmentInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AddressExtServiceServer).UpdateEstablishmentInfo(ctx, req.(*UpdateEstablishmentInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AddressExtService_NearestEstablishment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NearestEstablishmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AddressExtServiceServer).NearestEstablishment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.address.ext.AddressExtService/NearestEstablishment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AddressExtServiceServer).NearestEstablishment(ctx, req.(*NearestEstablishmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AddressExtService_ReportLoad_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportLoadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AddressExtServiceServer).ReportLoad(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.address.ext.AddressExtService/ReportLoad",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AddressExtServiceServer).ReportLoad(ctx, req.(*ReportLoadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AddressExtService_SearchEstablishments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchEstablishmentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AddressExtServiceServer).SearchEstablishments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.address.ext.AddressExtService/SearchEstablishments",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AddressExtServiceServer).SearchEstablishments(ctx, req.(*SearchEstablishmentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AddressExtService_CheckDeliverability_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckDeliverabilityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AddressExtServiceServer).CheckDeliverability(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.address.ext.AddressExtService/CheckDeliverability",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AddressExtServiceServer).CheckDeliverability(ctx, req.(*CheckDeliverabilityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AddressExtService_ServiceDesc is the grpc.ServiceDesc for AddressExtService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AddressExtService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.address.ext.AddressExtService",
+	HandlerType: (*AddressExtServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "BatchGetEstablishments",
+			Handler:    _AddressExtService_BatchGetEstablishments_Handler,
+		},
+		{
+			MethodName: "BatchGetDeliveries",
+			Handler:    _AddressExtService_BatchGetDeliveries_Handler,
+		},
+		{
+			MethodName: "GetEstablishment",
+			Handler:    _AddressExtService_GetEstablishment_Handler,
+		},
+		{
+			MethodName: "UpdateEstablishmentInfo",
+			Handler:    _AddressExtService_UpdateEstablishmentInfo_Handler,
+		},
+		{
+			MethodName: "NearestEstablishment",
+			Handler:    _AddressExtService_NearestEstablishment_Handler,
+		},
+		{
+			MethodName: "ReportLoad",
+			Handler:    _AddressExtService_ReportLoad_Handler,
+		},
+		{
+			MethodName: "SearchEstablishments",
+			Handler:    _AddressExtService_SearchEstablishments_Handler,
+		},
+		{
+			MethodName: "CheckDeliverability",
+			Handler:    _AddressExtService_CheckDeliverability_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ImportEstablishments",
+			Handler:       _AddressExtService_ImportEstablishments_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ExportAddresses",
+			Handler:       _AddressExtService_ExportAddresses_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchEstablishments",
+			Handler:       _AddressExtService_WatchEstablishments_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "addressext/address.proto",
+}
+
+// AddressAdminServiceClient is the client API for AddressAdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AddressAdminServiceClient interface {
+	AuditHistory(ctx context.Context, in *AuditHistoryRequest, opts ...grpc.CallOption) (*AuditHistoryResponse, error)
+	RestoreDelivery(ctx context.Context, in *RestoreDeliveryRequest, opts ...grpc.CallOption) (*RestoreDeliveryResponse, error)
+	ExportUserData(ctx context.Context, in *UserDataRequest, opts ...grpc.CallOption) (*UserDataResponse, error)
+	// EraseUser can not be undone, it also redacts the audited changes of
+	// the user
+	EraseUser(ctx context.Context, in *EraseUserRequest, opts ...grpc.CallOption) (*EraseUserResponse, error)
+}
+
+type addressAdminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAddressAdminServiceClient(cc grpc.ClientConnInterface) AddressAdminServiceClient {
+	return &addressAdminServiceClient{cc}
+}
+
+func (c *addressAdminServiceClient) AuditHistory(ctx context.Context, in *AuditHistoryRequest, opts ...grpc.CallOption) (*AuditHistoryResponse, error) {
+	out := new(AuditHistoryResponse)
+	err := c.cc.Invoke(ctx, "/proto.address.ext.AddressAdminService/AuditHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *addressAdminServiceClient) RestoreDelivery(ctx context.Context, in *RestoreDeliveryRequest, opts ...grpc.CallOption) (*RestoreDeliveryResponse, error) {
+	out := new(RestoreDeliveryResponse)
+	err := c.cc.Invoke(ctx, "/proto.address.ext.AddressAdminService/RestoreDelivery", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *addressAdminServiceClient) ExportUserData(ctx context.Context, in *UserDataRequest, opts ...grpc.CallOption) (*UserDataResponse, error) {
+	out := new(UserDataResponse)
+	err := c.cc.Invoke(ctx, "/proto.address.ext.AddressAdminService/ExportUserData", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *addressAdminServiceClient) EraseUser(ctx context.Context, in *EraseUserRequest, opts ...grpc.CallOption) (*EraseUserResponse, error) {
+	out := new(EraseUserResponse)
+	err := c.cc.Invoke(ctx, "/proto.address.ext.AddressAdminService/EraseUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AddressAdminServiceServer is the server API for AddressAdminService service.
+// All implementations must embed UnimplementedAddressAdminServiceServer
+// for forward compatibility
+type AddressAdminServiceServer interface {
+	AuditHistory(context.Context, *AuditHistoryRequest) (*AuditHistoryResponse, error)
+	RestoreDelivery(context.Context, *RestoreDeliveryRequest) (*RestoreDeliveryResponse, error)
+	ExportUserData(context.Context, *UserDataRequest) (*UserDataResponse, error)
+	// EraseUser can not be undone, it also redacts the audited changes of
+	// the user
+	EraseUser(context.Context, *EraseUserRequest) (*EraseUserResponse, error)
+	mustEmbedUnimplementedAddressAdminServiceServer()
+}
+
+// UnimplementedAddressAdminServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAddressAdminServiceServer struct {
+}
+
+func (UnimplementedAddressAdminServiceServer) AuditHistory(context.Context, *AuditHistoryRequest) (*AuditHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AuditHistory not implemented")
+}
+func (UnimplementedAddressAdminServiceServer) RestoreDelivery(context.Context, *RestoreDeliveryRequest) (*RestoreDeliveryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreDelivery not implemented")
+}
+func (UnimplementedAddressAdminServiceServer) ExportUserData(context.Context, *UserDataRequest) (*UserDataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportUserData not implemented")
+}
+func (UnimplementedAddressAdminServiceServer) EraseUser(context.Context, *EraseUserRequest) (*EraseUserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EraseUser not implemented")
+}
+func (UnimplementedAddressAdminServiceServer) mustEmbedUnimplementedAddressAdminServiceServer() {}
+
+// UnsafeAddressAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AddressAdminServiceServer will
+// result in compilation errors.
+type UnsafeAddressAdminServiceServer interface {
+	mustEmbedUnimplementedAddressAdminServiceServer()
+}
+
+func RegisterAddressAdminServiceServer(s grpc.ServiceRegistrar, srv AddressAdminServiceServer) {
+	s.RegisterService(&AddressAdminService_ServiceDesc, srv)
+}
+
+func _AddressAdminService_AuditHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuditHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AddressAdminServiceServer).AuditHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.address.ext.AddressAdminService/AuditHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AddressAdminServiceServer).AuditHistory(ctx, req.(*AuditHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AddressAdminService_RestoreDelivery_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreDeliveryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AddressAdminServiceServer).RestoreDelivery(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.address.ext.AddressAdminService/RestoreDelivery",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AddressAdminServiceServer).RestoreDelivery(ctx, req.(*RestoreDeliveryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AddressAdminService_ExportUserData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserDataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AddressAdminServiceServer).ExportUserData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.address.ext.AddressAdminService/ExportUserData",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AddressAdminServiceServer).ExportUserData(ctx, req.(*UserDataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AddressAdminService_EraseUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EraseUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AddressAdminServiceServer).EraseUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.address.ext.AddressAdminService/EraseUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AddressAdminServiceServer).EraseUser(ctx, req.(*EraseUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AddressAdminService_ServiceDesc is the grpc.ServiceDesc for AddressAdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AddressAdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.address.ext.AddressAdminService",
+	HandlerType: (*AddressAdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AuditHistory",
+			Handler:    _AddressAdminService_AuditHistory_Handler,
+		},
+		{
+			MethodName: "RestoreDelivery",
+			Handler:    _AddressAdminService_RestoreDelivery_Handler,
+		},
+		{
+			MethodName: "ExportUserData",
+			Handler:    _AddressAdminService_ExportUserData_Handler,
+		},
+		{
+			MethodName: "EraseUser",
+			Handler:    _AddressAdminService_EraseUser_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "addressext/address.proto",
+}
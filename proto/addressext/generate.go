@@ -0,0 +1,5 @@
+// Package addressext has the RPCs of the address service that are not part
+// of the shared protobuffers module.
+package addressext
+
+//go:generate sh -c "cd .. && buf generate --path addressext"
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// refill is the time to fill the bucket from empty, burst/rate
+	refill time.Duration
+}
+
+// MemoryStore keeps the buckets of a single replica in memory.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+func (ms *MemoryStore) Take(_ context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	now := ms.now()
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	b, ok := ms.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), updated: now}
+		ms.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+	b.refill = time.Duration(float64(burst) / rate * float64(time.Second))
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rate * float64(time.Second)), nil
+	}
+	b.tokens--
+	return true, 0, nil
+}
+
+// Cleanup drops the buckets untouched for idle, or for their refill time
+// when longer, e.g. for a daily quota. Only a full bucket is dropped, as it
+// would be created again the same, so pausing does not reset a limit.
+func (ms *MemoryStore) Cleanup(idle time.Duration) {
+	now := ms.now()
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for k, b := range ms.buckets {
+		wait := idle
+		if b.refill > wait {
+			wait = b.refill
+		}
+		if now.Sub(b.updated) > wait {
+			delete(ms.buckets, k)
+		}
+	}
+}
+
+// Run calls Cleanup every interval until ctx is done.
+func (ms *MemoryStore) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			ms.Cleanup(interval)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore shares the buckets between replicas, every Take is a single
+// atomic findOneAndUpdate with an aggregation pipeline (Mongo 4.2+).
+type MongoStore struct {
+	c *mongo.Collection
+}
+
+func NewMongoStore(db *mongo.Database, coll string) MongoStore {
+	if coll == "" {
+		coll = "rate_limit"
+	}
+	return MongoStore{c: db.Collection(coll)}
+}
+
+// EnsureIndexes creates the TTL index removing idle buckets.
+func (ms MongoStore) EnsureIndexes(ctx context.Context) error {
+	_, err := ms.c.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expire_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("create index: %w", err)
+	}
+	return nil
+}
+
+type mongoBucket struct {
+	Tokens  float64 `bson:"tokens"`
+	Allowed bool    `bson:"allowed"`
+}
+
+func (ms MongoStore) Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	now := time.Now()
+	// a bucket is full again after burst/rate, it can be dropped by then
+	expire := now.Add(time.Duration(float64(burst) / rate * float64(time.Second)))
+	elapsed := bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$updated", now}}}}, 1000}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"tokens": bson.M{"$min": bson.A{burst, bson.M{"$add": bson.A{
+				bson.M{"$ifNull": bson.A{"$tokens", burst}},
+				bson.M{"$multiply": bson.A{elapsed, rate}},
+			}}}},
+			"updated":   now,
+			"expire_at": expire,
+		}}},
+		{{Key: "$set", Value: bson.M{"allowed": bson.M{"$gte": bson.A{"$tokens", 1}}}}},
+		{{Key: "$set", Value: bson.M{"tokens": bson.M{"$cond": bson.A{"$allowed", bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}}}}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var b mongoBucket
+	if err := ms.c.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&b); err != nil {
+		return false, 0, fmt.Errorf("findOneAndUpdate: %w", err)
+	}
+	if !b.Allowed {
+		return false, time.Duration((1 - b.Tokens) / rate * float64(time.Second)), nil
+	}
+	return true, 0, nil
+}
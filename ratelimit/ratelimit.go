@@ -0,0 +1,176 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
+	"github.com/modular-project/address-service/tenant"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// CallerHeader names the calling service in the logs. It is chosen by the
+// client, the caller bucket is keyed on its verified identity instead.
+const CallerHeader = "x-caller-id"
+
+// CallerTag is the grpc_ctxtags key holding the x-caller-id of the call.
+const CallerTag = "caller"
+
+// Store keeps the token buckets.
+type Store interface {
+	// Take removes a token from the bucket of key, refilled at rate tokens
+	// per second up to burst. When no token is left it returns false and
+	// the time until the next one.
+	Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error)
+}
+
+// Bucket is the refill rate, in tokens per second, and capacity of a bucket.
+type Bucket struct {
+	Rate  float64
+	Burst int
+}
+
+// Limiter applies a bucket per user and per caller to the configured methods.
+type Limiter struct {
+	store   Store
+	user    Bucket
+	caller  Bucket
+	methods map[string]bool
+}
+
+// NewLimiter limits methods (full gRPC method names), a bucket with a zero
+// rate is not enforced.
+func NewLimiter(s Store, user, caller Bucket, methods ...string) Limiter {
+	m := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		m[method] = true
+	}
+	return Limiter{store: s, user: user, caller: caller, methods: m}
+}
+
+type userRequest interface {
+	GetUserId() uint64
+}
+
+type userIDRequest interface {
+	GetId() uint64
+}
+
+func userID(req interface{}) (uint64, bool) {
+	switch r := req.(type) {
+	case userRequest:
+		return r.GetUserId(), true
+	case userIDRequest:
+		return r.GetId(), true
+	}
+	return 0, false
+}
+
+// caller returns the key of the caller bucket: the subject of the verified
+// client certificate, or else the peer IP. Rotating x-caller-id does not
+// give a new bucket, it only labels the logs of the call.
+func caller(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(CallerHeader); len(v) > 0 && v[0] != "" {
+			grpc_ctxtags.Extract(ctx).Set(CallerTag, v[0])
+		}
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "unknown"
+	}
+	if ti, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(ti.State.VerifiedChains) != 0 {
+		if id := certIdentity(ti.State.VerifiedChains[0][0]); id != "" {
+			return "tls:" + id
+		}
+	}
+	if p.Addr == nil {
+		return "unknown"
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
+
+// certIdentity returns the first URI or DNS SAN of c, or its common name.
+func certIdentity(c *x509.Certificate) string {
+	switch {
+	case len(c.URIs) != 0:
+		return c.URIs[0].String()
+	case len(c.DNSNames) != 0:
+		return c.DNSNames[0]
+	}
+	return c.Subject.CommonName
+}
+
+func (lm Limiter) take(ctx context.Context, kind, key string, b Bucket) error {
+	if b.Rate <= 0 {
+		return nil
+	}
+	ok, wait, err := lm.store.Take(ctx, kind+":"+key, b.Rate, b.Burst)
+	if err != nil {
+		// fail open, a broken store must not stop the service
+		ctxzap.Warn(ctx, "rate limit store", zap.String("kind", kind), zap.Error(err))
+		return nil
+	}
+	if !ok {
+		secs := strconv.Itoa(int(math.Ceil(wait.Seconds())))
+		_ = grpc.SetTrailer(ctx, metadata.Pairs("retry-after", secs))
+		return status.Errorf(codes.ResourceExhausted, "%s rate limit exceeded, retry in %ss", kind, secs)
+	}
+	return nil
+}
+
+// Limit checks the caller bucket and, when req carries a user ID, the user
+// bucket.
+func (lm Limiter) Limit(ctx context.Context, method string, req interface{}) error {
+	if !lm.methods[method] {
+		return nil
+	}
+	if err := lm.take(ctx, "caller", caller(ctx), lm.caller); err != nil {
+		return err
+	}
+	if uID, ok := userID(req); ok {
+		// the user IDs of different tenants are unrelated
+		key := fmt.Sprint(uID)
+		if t, ok := tenant.FromContext(ctx); ok {
+			key = t + "/" + key
+		}
+		if err := lm.take(ctx, "user", key, lm.user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (lm Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := lm.Limit(ctx, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor limits the opening of streams by caller.
+func (lm Limiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := lm.Limit(ss.Context(), info.FullMethod, nil); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
+	pf "github.com/modular-project/protobuffers/address/address"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestMemoryStore_Take(t *testing.T) {
+	now := time.Unix(0, 0)
+	ms := NewMemoryStore()
+	ms.now = func() time.Time { return now }
+	tests := []struct {
+		name    string
+		advance time.Duration
+		want    bool
+	}{
+		{name: "first token", want: true},
+		{name: "second token", want: true},
+		{name: "burst exhausted", want: false},
+		{name: "not refilled yet", advance: 500 * time.Millisecond, want: false},
+		{name: "refilled", advance: 500 * time.Millisecond, want: true},
+		{name: "capped at burst", advance: time.Hour, want: true},
+		{name: "capped second", want: true},
+		{name: "capped exhausted", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			got, wait, err := ms.Take(context.Background(), "k", 1, 2)
+			if err != nil {
+				t.Fatalf("MemoryStore.Take() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("MemoryStore.Take() = %v, want %v", got, tt.want)
+			}
+			if !got && wait <= 0 {
+				t.Errorf("MemoryStore.Take() wait = %s, want positive", wait)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_Cleanup(t *testing.T) {
+	now := time.Unix(0, 0)
+	ms := NewMemoryStore()
+	ms.now = func() time.Time { return now }
+	ctx := context.Background()
+	// 10 per day, refilled in a day
+	daily := 10.0 / (24 * 60 * 60)
+	for i := 0; i < 10; i++ {
+		_, _, _ = ms.Take(ctx, "daily", daily, 10)
+	}
+	_, _, _ = ms.Take(ctx, "fast", 1, 2)
+	now = now.Add(time.Hour)
+	ms.Cleanup(10 * time.Minute)
+	if _, ok := ms.buckets["fast"]; ok {
+		t.Errorf("MemoryStore.Cleanup() kept the refilled bucket")
+	}
+	if ok, _, _ := ms.Take(ctx, "daily", daily, 10); ok {
+		t.Errorf("MemoryStore.Cleanup() reset the daily quota")
+	}
+}
+
+func TestLimiter_Limit(t *testing.T) {
+	const method = "/proto.address.address.AddressService/CreateDelivery"
+	lm := NewLimiter(NewMemoryStore(), Bucket{Rate: 0.001, Burst: 1}, Bucket{Rate: 0.001, Burst: 2}, method)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(CallerHeader, "order-service"))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 50000}})
+	tests := []struct {
+		name   string
+		method string
+		req    interface{}
+		want   codes.Code
+	}{
+		{name: "user 1 first", method: method, req: &pf.Delivery{UserId: 1}, want: codes.OK},
+		{name: "user 1 limited", method: method, req: &pf.Delivery{UserId: 1}, want: codes.ResourceExhausted},
+		{name: "other method not limited", method: "/proto.address.address.AddressService/Search", req: &pf.Delivery{UserId: 1}, want: codes.OK},
+		{name: "caller exhausted by user 2", method: method, req: &pf.Delivery{UserId: 2}, want: codes.ResourceExhausted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := lm.Limit(ctx, tt.method, tt.req)
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("Limiter.Limit() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimiter_Limit_callerHeader(t *testing.T) {
+	const method = "/proto.address.address.AddressService/CreateDelivery"
+	lm := NewLimiter(NewMemoryStore(), Bucket{}, Bucket{Rate: 0.001, Burst: 1}, method)
+	call := func(ip, header string) context.Context {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(CallerHeader, header))
+		ctx = grpc_ctxtags.SetInContext(ctx, grpc_ctxtags.NewTags())
+		return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000}})
+	}
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want codes.Code
+	}{
+		{name: "first", ctx: call("10.0.0.1", "order-service"), want: codes.OK},
+		{name: "same peer another header", ctx: call("10.0.0.1", "other-service"), want: codes.ResourceExhausted},
+		{name: "another peer", ctx: call("10.0.0.2", "order-service"), want: codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := lm.Limit(tt.ctx, method, nil)
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("Limiter.Limit() = %s, want %s", got, tt.want)
+			}
+			if got := grpc_ctxtags.Extract(tt.ctx).Values()[CallerTag]; got == nil {
+				t.Errorf("Limiter.Limit() did not tag the caller")
+			}
+		})
+	}
+}
@@ -2,18 +2,26 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/modular-project/address-service/controller"
+	"github.com/modular-project/address-service/logger"
 	"github.com/modular-project/address-service/model"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
 )
 
 type AddressStorage struct {
-	c      *mongo.Collection
+	pending
+	c *mongo.Collection
+	// loads reported by the establishments, apart so their frequent
+	// updates are not changes of the establishments for Watch
+	loads  *mongo.Collection
 	maxDis int
 }
 
@@ -21,7 +29,13 @@ func NewAddressStorage(db *mongo.Database, max int, coll string) AddressStorage
 	if coll == "" {
 		coll = "establishment"
 	}
-	return AddressStorage{c: db.Collection(coll), maxDis: max}
+	c := db.Collection(coll)
+	return AddressStorage{pending: pending{c}, c: c, loads: db.Collection(coll + "_load"), maxDis: max}
+}
+
+// VerifyIndexes checks the 2dsphere index used by Candidates exists.
+func (as AddressStorage) VerifyIndexes(ctx context.Context) error {
+	return verifyIndexes(ctx, as.c, bson.D{{Key: "tenant_id", Value: 1}, {Key: "location", Value: "2dsphere"}})
 }
 
 func (as AddressStorage) GetByID(ctx context.Context, aID string) (model.Address, error) {
@@ -30,7 +44,11 @@ func (as AddressStorage) GetByID(ctx context.Context, aID string) (model.Address
 	if err != nil {
 		return model.Address{}, fmt.Errorf("ObjectIDFromHex: %w", err)
 	}
-	r := as.c.FindOne(ctx, bson.M{"_id": id})
+	q, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return model.Address{}, err
+	}
+	r := as.c.FindOne(ctx, q)
 	if r.Err() != nil {
 		return model.Address{}, fmt.Errorf("findOne: %w", r.Err())
 	}
@@ -41,6 +59,11 @@ func (as AddressStorage) GetByID(ctx context.Context, aID string) (model.Address
 }
 
 func (as AddressStorage) Create(ctx context.Context, add *model.Address) (string, error) {
+	t, err := tenantOf(ctx)
+	if err != nil {
+		return "", err
+	}
+	add.TenantID = t
 	r, err := as.c.InsertOne(ctx, add)
 	if err != nil {
 		return "", fmt.Errorf("InsertOne: %w", err)
@@ -52,27 +75,149 @@ func (as AddressStorage) Create(ctx context.Context, add *model.Address) (string
 	return id.Hex(), nil
 }
 
+// importedFields are replaced by Upsert, the empty ones are removed. The
+// other fields of an establishment, e.g. its description, are kept.
+var importedFields = []string{
+	"street", "suburb", "city", "pc", "state", "country", "location",
+	"geocode_status", "geocoded_at", "geocode_provider", "geocode_quality",
+}
+
+// importResets are removed by Upsert, an imported establishment is active
+// and geocoded.
+var importResets = []string{"is_deleted", "deleted_at", "geocode_attempts", "geocode_retry_at"}
+
+// importUpdate returns the fields of add to set and unset.
+func importUpdate(add model.Address) (set, unset bson.M, err error) {
+	var doc bson.M
+	b, err := bson.Marshal(add)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal: %w", err)
+	}
+	if err := bson.Unmarshal(b, &doc); err != nil {
+		return nil, nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	set = bson.M{"tenant_id": add.TenantID, "external_key": add.ExternalKey}
+	unset = bson.M{}
+	for _, f := range importedFields {
+		if v, ok := doc[f]; ok {
+			set[f] = v
+		} else {
+			unset[f] = ""
+		}
+	}
+	for _, f := range importResets {
+		unset[f] = ""
+	}
+	return set, unset, nil
+}
+
+// imported returns before with the update of importUpdate applied.
+func imported(before model.Address, set, unset bson.M) (model.Address, error) {
+	var doc bson.M
+	b, err := bson.Marshal(before)
+	if err != nil {
+		return model.Address{}, fmt.Errorf("marshal: %w", err)
+	}
+	if err := bson.Unmarshal(b, &doc); err != nil {
+		return model.Address{}, fmt.Errorf("unmarshal: %w", err)
+	}
+	for f, v := range set {
+		doc[f] = v
+	}
+	for f := range unset {
+		delete(doc, f)
+	}
+	if b, err = bson.Marshal(doc); err != nil {
+		return model.Address{}, fmt.Errorf("marshal: %w", err)
+	}
+	var after model.Address
+	if err := bson.Unmarshal(b, &after); err != nil {
+		return model.Address{}, fmt.Errorf("unmarshal: %w", err)
+	}
+	return after, nil
+}
+
+// Upsert creates the establishment with the external key of add in its
+// tenant or replaces its importedFields. It returns the establishment
+// before, created is true when there was none, and leaves add as it is
+// stored.
+func (as AddressStorage) Upsert(ctx context.Context, add *model.Address) (id string, before model.Address, created bool, err error) {
+	if add.ExternalKey == "" {
+		return "", model.Address{}, false, fmt.Errorf("upsert: empty external key")
+	}
+	t, err := tenantOf(ctx)
+	if err != nil {
+		return "", model.Address{}, false, err
+	}
+	add.TenantID = t
+	set, unset, err := importUpdate(*add)
+	if err != nil {
+		return "", model.Address{}, false, err
+	}
+	newID := primitive.NewObjectID()
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+	r := as.c.FindOneAndUpdate(ctx, bson.M{"tenant_id": t, "external_key": add.ExternalKey}, bson.M{
+		"$set":         set,
+		"$unset":       unset,
+		"$setOnInsert": bson.M{"_id": newID},
+	}, opts)
+	if err := r.Decode(&before); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			after, err := imported(model.Address{ID: newID}, set, unset)
+			if err != nil {
+				return "", model.Address{}, false, err
+			}
+			*add = after
+			return newID.Hex(), model.Address{}, true, nil
+		}
+		return "", model.Address{}, false, fmt.Errorf("findOneAndUpdate: %w", err)
+	}
+	after, err := imported(before, set, unset)
+	if err != nil {
+		return "", model.Address{}, false, err
+	}
+	*add = after
+	return before.ID.Hex(), before, false, nil
+}
+
 func (as AddressStorage) DeleteByID(ctx context.Context, aID string) (int64, error) {
 	id, err := primitive.ObjectIDFromHex(aID)
 	if err != nil {
 		return 0, fmt.Errorf("ObjectIDFromHex: %w", err)
 	}
-	r, err := as.c.DeleteOne(ctx, bson.M{"_id": id})
+	q, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return 0, err
+	}
+	r, err := as.c.DeleteOne(ctx, q)
 	if err != nil {
 		return 0, fmt.Errorf("DeleteOne: %w", err)
 	}
+	if r.DeletedCount != 0 {
+		if _, err := as.loads.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+			return 0, fmt.Errorf("delete load: %w", err)
+		}
+	}
 	return r.DeletedCount, nil
 }
 
 func (as AddressStorage) Search(ctx context.Context, s *model.Search) ([]model.Address, error) {
 	var ads []model.Address
+	t, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
 	opt := options.FindOptions{
 		Limit: &s.Limit,
 		Skip:  &s.Offset,
 		Sort:  s.OrderBy,
 	}
-	log.Println(s.Querys, s.OrderBy)
-	r, err := as.c.Find(ctx, s.Querys, &opt)
+	ctxzap.Debug(ctx, "search establishments",
+		logger.QueryKeys("query", s.Querys), zap.Any("order_by", s.OrderBy),
+		zap.Int64("limit", s.Limit), zap.Int64("offset", s.Offset))
+	// the query comes from the caller, it can not override the tenant
+	q := bson.D{{Key: "$and", Value: bson.A{s.Querys, available(bson.M{"tenant_id": t}, s.Availability)}}}
+	r, err := as.c.Find(ctx, q, &opt)
 	if err != nil {
 		return nil, fmt.Errorf("find: %w", err)
 	}
@@ -82,25 +227,76 @@ func (as AddressStorage) Search(ctx context.Context, s *model.Search) ([]model.A
 	return ads, nil
 }
 
-func (as AddressStorage) Nearest(ctx context.Context, loc []float64) (string, error) {
-	var near model.Address
-	opts := options.FindOne().SetProjection(bson.M{"_id": 1})
-	r := as.c.FindOne(ctx, bson.M{
-		"location": bson.M{
-			"$near": bson.M{
-				"$geometry": bson.M{
-					"type":        "Point",
-					"coordinates": loc,
-				},
-				"$maxDistance": as.maxDis,
-			},
-		},
-	}, opts)
-	if r.Err() != nil {
-		return "", fmt.Errorf("findOne: %w", r.Err())
+// Candidates returns up to limit establishments of av within the maximum
+// distance of loc, closest first, or controller.ErrNoEstablishment.
+func (as AddressStorage) Candidates(ctx context.Context, loc []float64, av model.Availability, limit int) ([]model.Candidate, error) {
+	t, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// $geoNear because the hours are an expression, not allowed with $near
+	cur, err := as.c.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$geoNear", Value: bson.M{
+			"near":          bson.M{"type": "Point", "coordinates": loc},
+			"key":           "location",
+			"distanceField": "distance",
+			"maxDistance":   as.maxDis,
+			"spherical":     true,
+			"query":         available(bson.M{"tenant_id": t}, av),
+		}}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         as.loads.Name(),
+			"localField":   "_id",
+			"foreignField": "_id",
+			"as":           "load",
+		}}},
+		// a missing load removes the field
+		{{Key: "$set", Value: bson.M{"load": bson.M{"$arrayElemAt": bson.A{"$load", 0}}}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("aggregate: %w", err)
+	}
+	var cs []model.Candidate
+	if err := cur.All(ctx, &cs); err != nil {
+		return nil, fmt.Errorf("decode all: %w", err)
+	}
+	if len(cs) == 0 {
+		return nil, controller.ErrNoEstablishment
+	}
+	return cs, nil
+}
+
+// GetMany returns the establishments with the ids, in no particular order.
+func (as AddressStorage) GetMany(ctx context.Context, ids []string) ([]model.Address, error) {
+	var ads []model.Address
+	if err := getMany(ctx, as.c, ids, bson.M{}, &ads); err != nil {
+		return nil, err
+	}
+	return ads, nil
+}
+
+// getMany decodes the documents of c with the ids and matching f into out
+// with a single query.
+func getMany(ctx context.Context, c *mongo.Collection, ids []string, f bson.M, out interface{}) error {
+	oids := make([]primitive.ObjectID, len(ids))
+	for i, id := range ids {
+		var err error
+		if oids[i], err = primitive.ObjectIDFromHex(id); err != nil {
+			return fmt.Errorf("ObjectIDFromHex: %w", err)
+		}
+	}
+	f["_id"] = bson.M{"$in": oids}
+	q, err := scoped(ctx, f)
+	if err != nil {
+		return err
+	}
+	r, err := c.Find(ctx, q)
+	if err != nil {
+		return fmt.Errorf("find: %w", err)
 	}
-	if err := r.Decode(&near); err != nil {
-		return "", fmt.Errorf("decode: %w", err)
+	if err := r.All(ctx, out); err != nil {
+		return fmt.Errorf("decode all: %w", err)
 	}
-	return near.ID.Hex(), nil
+	return nil
 }
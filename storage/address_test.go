@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestImportUpdate(t *testing.T) {
+	at := time.Date(2022, 9, 12, 15, 0, 0, 0, time.UTC)
+	before := model.Address{
+		ID:              primitive.NewObjectID(),
+		TenantID:        "test",
+		ExternalKey:     "GDL-01",
+		Street:          "Av. Vallarta 1",
+		Suburb:          "Americana",
+		City:            "Guadalajara",
+		Name:            "Vallarta",
+		IsDeleted:       true,
+		GeocodeAttempts: 3,
+	}
+	row := model.Address{TenantID: "test", ExternalKey: "GDL-01", Street: "Av. Vallarta 2", City: "Guadalajara"}
+	row.SetLocation(model.Location{Type: "Point", Coordinates: []float64{-103.3, 20.6}, Provider: "google"}, at)
+	set, unset, err := importUpdate(row)
+	if err != nil {
+		t.Fatalf("importUpdate() error = %v", err)
+	}
+	for _, f := range []string{"suburb", "pc", "is_deleted", "geocode_attempts"} {
+		if _, ok := unset[f]; !ok {
+			t.Errorf("importUpdate() does not unset %s", f)
+		}
+	}
+	if _, ok := set["name"]; ok {
+		t.Errorf("importUpdate() sets the name, it is not imported")
+	}
+	got, err := imported(before, set, unset)
+	if err != nil {
+		t.Fatalf("imported() error = %v", err)
+	}
+	if got.ID != before.ID || got.Name != before.Name {
+		t.Errorf("imported() = %+v, want the id and name kept", got)
+	}
+	if got.Street != row.Street || got.Suburb != "" || got.IsDeleted || got.GeocodeAttempts != 0 {
+		t.Errorf("imported() = %+v, want the imported fields replaced", got)
+	}
+	if !got.GeocodedAt.Equal(at) || len(got.Location.Coordinates) != 2 {
+		t.Errorf("imported() location = %+v at %s", got.Location, got.GeocodedAt)
+	}
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxAuditEntries is the most entries returned by History.
+const maxAuditEntries = 1000
+
+// AuditStorage appends the audit entries, they are never removed and only
+// redacted by the erasure of their user or the purge of their address.
+type AuditStorage struct {
+	c *mongo.Collection
+}
+
+func NewAuditStorage(db *mongo.Database) AuditStorage {
+	return AuditStorage{c: db.Collection("audit")}
+}
+
+// EnsureIndexes creates the indexes of the history of an address and of a
+// user, and of the addresses purged from every tenant.
+func (as AuditStorage) EnsureIndexes(ctx context.Context) error {
+	_, err := as.c.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "address_id", Value: 1}, {Key: "at", Value: -1}},
+			Options: options.Index().SetName("tenant_id_address_id_at"),
+		},
+		{
+			Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "at", Value: -1}},
+			Options: options.Index().SetName("tenant_id_user_id_at").
+				SetPartialFilterExpression(bson.M{"user_id": bson.M{"$exists": true}}),
+		},
+		{
+			Keys:    bson.D{{Key: "address_id", Value: 1}},
+			Options: options.Index().SetName("address_id"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("create indexes: %w", err)
+	}
+	return nil
+}
+
+// Record appends e, within the transaction of ctx if any.
+func (as AuditStorage) Record(ctx context.Context, e model.AuditEntry) error {
+	if _, err := as.c.InsertOne(ctx, e); err != nil {
+		return fmt.Errorf("insertOne: %w", err)
+	}
+	return nil
+}
+
+// History returns the entries of the tenant of ctx matching q, newest
+// first.
+func (as AuditStorage) History(ctx context.Context, q model.AuditQuery) ([]model.AuditEntry, error) {
+	f := bson.M{}
+	if q.AddressID != "" {
+		f["address_id"] = q.AddressID
+	}
+	if q.UserID != 0 {
+		f["user_id"] = q.UserID
+	}
+	if !q.Before.IsZero() {
+		f["at"] = bson.M{"$lt": q.Before}
+	}
+	f, err := scoped(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	if q.Limit <= 0 || q.Limit > maxAuditEntries {
+		q.Limit = maxAuditEntries
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "at", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(q.Limit)
+	cur, err := as.c.Find(ctx, f, opts)
+	if err != nil {
+		return nil, fmt.Errorf("find: %w", err)
+	}
+	var es []model.AuditEntry
+	if err := cur.All(ctx, &es); err != nil {
+		return nil, fmt.Errorf("decode all: %w", err)
+	}
+	return es, nil
+}
+
+// RedactUser blanks the values of the changes of the entries of uID, who
+// did what and when is kept. It is the only change ever made to entries.
+func (as AuditStorage) RedactUser(ctx context.Context, uID uint64) (int64, error) {
+	q, err := scoped(ctx, bson.M{"user_id": uID, "changes": bson.M{"$exists": true}})
+	if err != nil {
+		return 0, err
+	}
+	return as.redact(ctx, q)
+}
+
+// RedactAddresses blanks the values of the changes of the entries of the
+// addresses ids of every tenant, which were purged.
+func (as AuditStorage) RedactAddresses(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	hex := make([]string, len(ids))
+	for i, id := range ids {
+		hex[i] = id.Hex()
+	}
+	return as.redact(ctx, bson.M{"address_id": bson.M{"$in": hex}, "changes": bson.M{"$exists": true}})
+}
+
+// redact keeps only the fields of the changes of the entries matching q.
+func (as AuditStorage) redact(ctx context.Context, q bson.M) (int64, error) {
+	r, err := as.c.UpdateMany(ctx, q, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"changes":  bson.M{"$map": bson.M{"input": "$changes", "in": bson.M{"field": "$$this.field"}}},
+			"redacted": true,
+		}}},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("updateMany: %w", err)
+	}
+	return r.ModifiedCount, nil
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func staleQuery(f model.StaleFilter, after primitive.ObjectID) bson.M {
+	and := bson.A{bson.M{"is_deleted": bson.M{"$ne": true}}}
+	if !after.IsZero() {
+		and = append(and, bson.M{"_id": bson.M{"$gt": after}})
+	}
+	if f.MissingLocation {
+		and = append(and, bson.M{"$or": bson.A{
+			bson.M{"location.coordinates": bson.M{"$exists": false}},
+			bson.M{"location.coordinates": bson.M{"$size": 0}},
+		}})
+	}
+	switch f.Provider {
+	case "":
+	case model.NoProvider:
+		and = append(and, bson.M{"geocode_provider": nil})
+	default:
+		and = append(and, bson.M{"geocode_provider": f.Provider})
+	}
+	if !f.GeocodedBefore.IsZero() {
+		and = append(and, bson.M{"$or": bson.A{
+			bson.M{"geocoded_at": nil},
+			bson.M{"geocoded_at": bson.M{"$lt": f.GeocodedBefore}},
+		}})
+	}
+	if len(f.Qualities) != 0 {
+		and = append(and, bson.M{"geocode_quality": bson.M{"$in": f.Qualities}})
+	}
+	// pending addresses belong to the geocode worker
+	and = append(and, bson.M{"geocode_status": bson.M{"$ne": model.GeocodePending}})
+	return bson.M{"$and": and}
+}
+
+// EachStale calls fn with the establishments matching f after the id after,
+// in id order.
+func (as AddressStorage) EachStale(ctx context.Context, f model.StaleFilter, after primitive.ObjectID, fn func(model.Delivery) error) error {
+	return each(ctx, as.c, staleQuery(f, after), fn)
+}
+
+// EachStale calls fn with the delivery addresses matching f after the id
+// after, in id order.
+func (ds DeliveryStorage) EachStale(ctx context.Context, f model.StaleFilter, after primitive.ObjectID, fn func(model.Delivery) error) error {
+	return each(ctx, ds.c, staleQuery(f, after), ds.opening(fn))
+}
+
+// BackfillStorage keeps the checkpoints of the backfill jobs and the
+// locations they changed.
+type BackfillStorage struct {
+	checkpoints *mongo.Collection
+	changes     *mongo.Collection
+}
+
+func NewBackfillStorage(db *mongo.Database) BackfillStorage {
+	return BackfillStorage{
+		checkpoints: db.Collection("backfill_checkpoint"),
+		changes:     db.Collection("backfill_change"),
+	}
+}
+
+// Checkpoint returns the checkpoint with id, an empty one when the job never
+// saved it.
+func (bs BackfillStorage) Checkpoint(ctx context.Context, id string) (model.BackfillCheckpoint, error) {
+	var cp model.BackfillCheckpoint
+	err := bs.checkpoints.FindOne(ctx, bson.M{"_id": id}).Decode(&cp)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return model.BackfillCheckpoint{ID: id}, nil
+	}
+	if err != nil {
+		return model.BackfillCheckpoint{}, fmt.Errorf("findOne: %w", err)
+	}
+	return cp, nil
+}
+
+// SaveCheckpoint replaces the checkpoint with the same id as cp.
+func (bs BackfillStorage) SaveCheckpoint(ctx context.Context, cp *model.BackfillCheckpoint) error {
+	_, err := bs.checkpoints.ReplaceOne(ctx, bson.M{"_id": cp.ID}, cp, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("replaceOne: %w", err)
+	}
+	return nil
+}
+
+// Record stores a change made by a job.
+func (bs BackfillStorage) Record(ctx context.Context, change *model.BackfillChange) error {
+	if _, err := bs.changes.InsertOne(ctx, change); err != nil {
+		return fmt.Errorf("insertOne: %w", err)
+	}
+	return nil
+}
+
+// EnsureIndexes creates the index used to find the changes of an address.
+func (bs BackfillStorage) EnsureIndexes(ctx context.Context) error {
+	_, err := bs.changes.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "address_id", Value: 1}},
+		Options: options.Index().SetName("address_id"),
+	})
+	if err != nil {
+		return fmt.Errorf("create index: %w", err)
+	}
+	return nil
+}
+
+// EraseChanges removes the changes of the addresses ids, they hold their
+// exact locations.
+func (bs BackfillStorage) EraseChanges(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	r, err := bs.changes.DeleteMany(ctx, bson.M{"address_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, fmt.Errorf("deleteMany: %w", err)
+	}
+	return r.DeletedCount, nil
+}
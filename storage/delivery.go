@@ -3,7 +3,9 @@ package storage
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/modular-project/address-service/encryption"
 	"github.com/modular-project/address-service/model"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -11,18 +13,35 @@ import (
 )
 
 type DeliveryStorage struct {
-	c *mongo.Collection
+	pending
+	c   *mongo.Collection
+	enc *encryption.Encryptor
 }
 
-func NewDeliveryStorage(db *mongo.Database, coll string) DeliveryStorage {
+func NewDeliveryStorage(db *mongo.Database, coll string, opts ...DeliveryOption) DeliveryStorage {
 	if coll == "" {
 		coll = "delivery"
 	}
-	return DeliveryStorage{db.Collection(coll)}
+	c := db.Collection(coll)
+	ds := DeliveryStorage{pending: pending{c}, c: c}
+	for _, opt := range opts {
+		opt(&ds)
+	}
+	return ds
 }
 
 func (ds DeliveryStorage) Create(ctx context.Context, d *model.Delivery) (string, error) {
-	r, err := ds.c.InsertOne(ctx, d)
+	t, err := tenantOf(ctx)
+	if err != nil {
+		return "", err
+	}
+	d.TenantID = t
+	// the caller keeps the plaintext
+	sealed := *d
+	if err := ds.seal(&sealed.Address); err != nil {
+		return "", err
+	}
+	r, err := ds.c.InsertOne(ctx, sealed)
 	if err != nil {
 		return "", fmt.Errorf("InsertOne: %w", err)
 	}
@@ -34,30 +53,69 @@ func (ds DeliveryStorage) Create(ctx context.Context, d *model.Delivery) (string
 }
 
 func (ds DeliveryStorage) GetAll(ctx context.Context, uID uint64) ([]model.Address, error) {
+	return ds.find(ctx, bson.M{"user_id": uID})
+}
+
+// GetActive returns the delivery addresses of uID not deleted.
+func (ds DeliveryStorage) GetActive(ctx context.Context, uID uint64) ([]model.Address, error) {
+	return ds.find(ctx, bson.M{"user_id": uID, "is_deleted": bson.M{"$ne": true}})
+}
+
+// find returns the decrypted addresses of the tenant matching f.
+func (ds DeliveryStorage) find(ctx context.Context, f bson.M) ([]model.Address, error) {
 	var as []model.Address
-	r, err := ds.c.Find(ctx, bson.M{"user_id": uID})
+	q, err := scoped(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	r, err := ds.c.Find(ctx, q)
 	if err != nil {
 		return nil, fmt.Errorf("find: %w", err)
 	}
 	if err := r.All(ctx, &as); err != nil {
 		return nil, fmt.Errorf("decode all: %w", err)
 	}
+	for i := range as {
+		if err := ds.open(&as[i]); err != nil {
+			return nil, err
+		}
+	}
 	return as, nil
 }
 
+// CountActive returns the number of delivery addresses of uID not deleted.
+func (ds DeliveryStorage) CountActive(ctx context.Context, uID uint64) (int64, error) {
+	q, err := scoped(ctx, bson.M{"user_id": uID, "is_deleted": bson.M{"$ne": true}})
+	if err != nil {
+		return 0, err
+	}
+	n, err := ds.c.CountDocuments(ctx, q)
+	if err != nil {
+		return 0, fmt.Errorf("countDocuments: %w", err)
+	}
+	return n, nil
+}
+
 func (ds DeliveryStorage) GetByID(ctx context.Context, uID uint64, aID string) (model.Address, error) {
 	var a model.Address
 	id, err := primitive.ObjectIDFromHex(aID)
 	if err != nil {
 		return model.Address{}, fmt.Errorf("ObjectIDFromHex: %w", err)
 	}
-	r := ds.c.FindOne(ctx, bson.M{"_id": id, "user_id": uID})
+	q, err := scoped(ctx, bson.M{"_id": id, "user_id": uID})
+	if err != nil {
+		return model.Address{}, err
+	}
+	r := ds.c.FindOne(ctx, q)
 	if r.Err() != nil {
 		return model.Address{}, fmt.Errorf("findOne: %w", r.Err())
 	}
 	if err := r.Decode(&a); err != nil {
 		return model.Address{}, fmt.Errorf("decode: %w", err)
 	}
+	if err := ds.open(&a); err != nil {
+		return model.Address{}, err
+	}
 	return a, nil
 }
 
@@ -66,9 +124,117 @@ func (ds DeliveryStorage) DeleteByID(ctx context.Context, uID uint64, aID string
 	if err != nil {
 		return 0, fmt.Errorf("ObjectIDFromHex: %w", err)
 	}
-	r, err := ds.c.UpdateOne(ctx, bson.M{"user_id": uID, "_id": id}, bson.D{{Key: "$set", Value: bson.D{{Key: "is_deleted", Value: true}}}})
+	q, err := scoped(ctx, bson.M{"user_id": uID, "_id": id})
+	if err != nil {
+		return 0, err
+	}
+	// deleting again keeps the retention running
+	r, err := ds.c.UpdateOne(ctx, q, mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"is_deleted": true,
+		"deleted_at": bson.M{"$ifNull": bson.A{"$deleted_at", time.Now()}},
+	}}}})
 	if err != nil {
 		return 0, fmt.Errorf("DeleteOne: %w", err)
 	}
 	return r.MatchedCount, nil
 }
+
+// GetMany returns the delivery addresses with the ids of any user, but the
+// anonymized ones, in no particular order.
+func (ds DeliveryStorage) GetMany(ctx context.Context, ids []string) ([]model.Delivery, error) {
+	var ads []model.Delivery
+	// the anonymized addresses have no user anymore
+	if err := getMany(ctx, ds.c, ids, bson.M{"user_id": bson.M{"$exists": true}}, &ads); err != nil {
+		return nil, err
+	}
+	for i := range ads {
+		if err := ds.open(&ads[i].Address); err != nil {
+			return nil, err
+		}
+	}
+	return ads, nil
+}
+
+// Restore undeletes the delivery address aID of uID, it returns 0 when it
+// is not deleted.
+func (ds DeliveryStorage) Restore(ctx context.Context, uID uint64, aID string) (int64, error) {
+	id, err := primitive.ObjectIDFromHex(aID)
+	if err != nil {
+		return 0, fmt.Errorf("ObjectIDFromHex: %w", err)
+	}
+	q, err := scoped(ctx, bson.M{"user_id": uID, "_id": id, "is_deleted": true})
+	if err != nil {
+		return 0, err
+	}
+	r, err := ds.c.UpdateOne(ctx, q, bson.M{"$unset": bson.M{"is_deleted": "", "deleted_at": ""}})
+	if err != nil {
+		return 0, fmt.Errorf("updateOne: %w", err)
+	}
+	return r.ModifiedCount, nil
+}
+
+// EraseUser removes every delivery address of uID, the deleted ones too.
+// Anonymizing keeps them deleted, without user, street, suburb nor key and
+// with their coordinates rounded to precision decimals.
+func (ds DeliveryStorage) EraseUser(ctx context.Context, uID uint64, anonymize bool, precision int) (int64, error) {
+	q, err := scoped(ctx, bson.M{"user_id": uID})
+	if err != nil {
+		return 0, err
+	}
+	if !anonymize {
+		r, err := ds.c.DeleteMany(ctx, q)
+		if err != nil {
+			return 0, fmt.Errorf("deleteMany: %w", err)
+		}
+		return r.DeletedCount, nil
+	}
+	r, err := ds.c.UpdateMany(ctx, q, anonymized(precision))
+	if err != nil {
+		return 0, fmt.Errorf("updateMany: %w", err)
+	}
+	return r.ModifiedCount, nil
+}
+
+// anonymized deletes the matched addresses without user, street, suburb nor
+// key and rounds their coordinates to precision decimals.
+func anonymized(precision int) mongo.Pipeline {
+	coarse := bson.M{"$cond": bson.A{
+		bson.M{"$isArray": "$location.coordinates"},
+		bson.M{
+			"type": "$location.type",
+			"coordinates": bson.M{"$map": bson.M{
+				"input": "$location.coordinates",
+				"in":    bson.M{"$round": bson.A{"$$this", precision}},
+			}},
+		},
+		"$$REMOVE",
+	}}
+	// a pending address would be geocoded again without its street
+	status := bson.M{"$cond": bson.A{
+		bson.M{"$eq": bson.A{"$geocode_status", model.GeocodePending}},
+		model.GeocodeFailed,
+		"$geocode_status",
+	}}
+	return mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"is_deleted":     true,
+			"deleted_at":     bson.M{"$ifNull": bson.A{"$deleted_at", time.Now()}},
+			"anonymized":     true,
+			"location":       coarse,
+			"geocode_status": status,
+		}}},
+		{{Key: "$unset", Value: bson.A{"user_id", "street", "suburb", "external_key", "geocode_retry_at"}}},
+	}
+}
+
+// ClaimPending claims the next delivery address to geocode, decrypted.
+func (ds DeliveryStorage) ClaimPending(ctx context.Context, lease time.Duration) (model.Address, bool, error) {
+	a, ok, err := ds.pending.ClaimPending(ctx, lease)
+	if err != nil || !ok {
+		return a, ok, err
+	}
+	if err := ds.open(&a); err != nil {
+		return model.Address{}, false, err
+	}
+	return a, true, nil
+}
@@ -3,19 +3,22 @@ package storage
 import (
 	"context"
 	"log"
-	"os"
 	"reflect"
 	"testing"
 
+	"github.com/modular-project/address-service/config"
 	"github.com/modular-project/address-service/model"
+	"github.com/modular-project/address-service/tenant"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 var (
+	testCtx = tenant.NewContext(context.Background(), "test")
 	address = []model.Address{
 		{
 			ID:         primitive.NewObjectID(),
+			TenantID:   "test",
 			Street:     "test 1",
 			City:       "city test",
 			PostalCode: "11111",
@@ -59,27 +62,30 @@ func dropTest(t *testing.T, db *mongo.Database) {
 }
 
 func newTestConnection() DBConnection {
-	env := "ADDR_DB_HOST"
-	host, f := os.LookupEnv(env)
-	if !f {
-		log.Fatalf("environment variable (%s) not found", env)
-	}
-	env = "ADDR_DB_USER"
-	user, f := os.LookupEnv(env)
-	if !f {
-		log.Fatalf("environment variable (%s) not found", env)
-	}
-	env = "ADDR_DB_PWD"
-	pwd, f := os.LookupEnv(env)
-	if !f {
-		log.Fatalf("environment variable (%s) not found", env)
-	}
-	env = "ADDR_DB_NAME"
-	cluster, f := os.LookupEnv(env)
-	if !f {
-		log.Fatalf("environment variable (%s) not found", env)
-	}
-	return DBConnection{User: user, Host: host, Password: pwd, Cluster: cluster, NameDB: "test"}
+	c := config.Default()
+	if err := config.LoadEnv(&c); err != nil {
+		log.Fatalf("load env: %s", err)
+	}
+	if err := c.DB.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	return DBConnection{User: c.DB.User, Host: c.DB.Host, Password: c.DB.Password, Cluster: c.DB.Cluster, NameDB: "test"}
+}
+
+// newTestDB connects to the test database, the client is closed at the end
+// of t.
+func newTestDB(t *testing.T) *mongo.Database {
+	conn := newTestConnection()
+	m, err := Connect(context.Background(), &conn)
+	if err != nil {
+		t.Fatalf("failed to Connect: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := m.Close(context.Background()); err != nil {
+			t.Errorf("close: %s", err)
+		}
+	})
+	return m.Database("")
 }
 
 func TestDeliveryStorage_Create(t *testing.T) {
@@ -95,7 +101,7 @@ func TestDeliveryStorage_Create(t *testing.T) {
 		{
 			name: "insert OK",
 			args: args{
-				ctx: context.Background(),
+				ctx: testCtx,
 				d: model.Delivery{
 					UserID: 1,
 					Address: model.Address{
@@ -109,12 +115,7 @@ func TestDeliveryStorage_Create(t *testing.T) {
 			},
 		},
 	}
-	conn := newTestConnection()
-	db, err := NewDB(&conn)
-	if err != nil {
-		t.Fatalf("failed to NewDB: %s", err)
-	}
-	ds := NewDeliveryStorage(db, "")
+	ds := NewDeliveryStorage(newTestDB(t), "")
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got, err := ds.Create(tt.args.ctx, &tt.args.d)
@@ -192,22 +193,26 @@ func TestDeliveryStorage_GetByID(t *testing.T) {
 		{
 			name: "ok user 1",
 			want: address[0],
-			args: args{ctx: context.Background(), uID: 1, aID: address[0].ID.Hex()},
+			args: args{ctx: testCtx, uID: 1, aID: address[0].ID.Hex()},
 		}, {
 			name:    "forbidden, user 2 get user 1",
-			args:    args{ctx: context.Background(), uID: 2, aID: address[0].ID.Hex()},
+			args:    args{ctx: testCtx, uID: 2, aID: address[0].ID.Hex()},
 			wantErr: true,
 		}, {
 			name:    "aID not found",
-			args:    args{ctx: context.Background(), uID: 1, aID: primitive.NewObjectID().Hex()},
+			args:    args{ctx: testCtx, uID: 1, aID: primitive.NewObjectID().Hex()},
+			wantErr: true,
+		}, {
+			name:    "other tenant",
+			args:    args{ctx: tenant.NewContext(context.Background(), "other"), uID: 1, aID: address[0].ID.Hex()},
+			wantErr: true,
+		}, {
+			name:    "without tenant",
+			args:    args{ctx: context.Background(), uID: 1, aID: address[0].ID.Hex()},
 			wantErr: true,
 		},
 	}
-	conn := newTestConnection()
-	db, err := NewDB(&conn)
-	if err != nil {
-		t.Errorf("newDB: %s", err)
-	}
+	db := newTestDB(t)
 	initDB(t, db)
 	t.Cleanup(func() { dropTest(t, db) })
 	ds := NewDeliveryStorage(db, "")
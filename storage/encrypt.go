@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/modular-project/address-service/encryption"
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// piiFields are the encrypted fields of the delivery addresses, the
+// deterministic ones can be looked up by equality.
+var piiFields = []struct {
+	name          string
+	deterministic bool
+	value         func(*model.Address) *string
+}{
+	{"street", false, func(a *model.Address) *string { return &a.Street }},
+	{"suburb", false, func(a *model.Address) *string { return &a.Suburb }},
+	{"pc", true, func(a *model.Address) *string { return &a.PostalCode }},
+}
+
+type DeliveryOption func(*DeliveryStorage)
+
+// WithEncryption encrypts the personal data of the delivery addresses with
+// e, the ones stored before are still read.
+func WithEncryption(e encryption.Encryptor) DeliveryOption {
+	return func(ds *DeliveryStorage) {
+		ds.enc = &e
+	}
+}
+
+// seal encrypts the personal data of a plaintext address.
+func (ds DeliveryStorage) seal(a *model.Address) error {
+	if ds.enc == nil {
+		return nil
+	}
+	for _, f := range piiFields {
+		v := f.value(a)
+		var err error
+		if f.deterministic {
+			*v, err = ds.enc.EncryptDeterministic(f.name, *v)
+		} else {
+			*v, err = ds.enc.Encrypt(f.name, *v)
+		}
+		if err != nil {
+			return fmt.Errorf("encrypt %s: %w", f.name, err)
+		}
+	}
+	a.EncryptionKey = ds.enc.CurrentKeyID()
+	return nil
+}
+
+// open decrypts the personal data of a stored address.
+func (ds DeliveryStorage) open(a *model.Address) error {
+	if ds.enc == nil {
+		return nil
+	}
+	for _, f := range piiFields {
+		v := f.value(a)
+		var err error
+		if *v, err = ds.enc.Decrypt(f.name, *v); err != nil {
+			return fmt.Errorf("decrypt %s of %s: %w", f.name, a.ID.Hex(), err)
+		}
+	}
+	a.EncryptionKey = ""
+	return nil
+}
+
+// opening decrypts the addresses before fn.
+func (ds DeliveryStorage) opening(fn func(model.Delivery) error) func(model.Delivery) error {
+	return func(d model.Delivery) error {
+		if err := ds.open(&d.Address); err != nil {
+			return err
+		}
+		return fn(d)
+	}
+}
+
+// lookup returns the filter of the plaintext v of the deterministic field.
+func (ds DeliveryStorage) lookup(field, v string) (bson.M, error) {
+	vs, err := ds.enc.Lookup(field, v)
+	if err != nil {
+		return nil, fmt.Errorf("lookup %s: %w", field, err)
+	}
+	// the plaintext matches the addresses stored before the encryption
+	return bson.M{"$in": append(vs, v)}, nil
+}
+
+// Reencrypt encrypts with the current key up to limit delivery addresses of
+// any tenant encrypted with another key or not encrypted, it returns how
+// many. After a rotation, it runs until it returns 0.
+func (ds DeliveryStorage) Reencrypt(ctx context.Context, limit int64) (int, error) {
+	if ds.enc == nil {
+		return 0, fmt.Errorf("reencrypt: encryption is disabled")
+	}
+	cur, err := ds.c.Find(ctx, bson.M{"enc_key": bson.M{"$ne": ds.enc.CurrentKeyID()}}, options.Find().SetLimit(limit))
+	if err != nil {
+		return 0, fmt.Errorf("find: %w", err)
+	}
+	var ads []model.Address
+	if err := cur.All(ctx, &ads); err != nil {
+		return 0, fmt.Errorf("decode all: %w", err)
+	}
+	n := 0
+	for i := range ads {
+		a := &ads[i]
+		// a concurrent rotation of the same address wins
+		q := bson.M{"_id": a.ID, "enc_key": a.EncryptionKey}
+		if a.EncryptionKey == "" {
+			q["enc_key"] = bson.M{"$exists": false}
+		}
+		if err := ds.open(a); err != nil {
+			return n, err
+		}
+		if err := ds.seal(a); err != nil {
+			return n, err
+		}
+		set := bson.M{"enc_key": a.EncryptionKey}
+		for _, f := range piiFields {
+			if v := *f.value(a); v != "" {
+				set[f.name] = v
+			}
+		}
+		r, err := ds.c.UpdateOne(ctx, q, bson.M{"$set": set})
+		if err != nil {
+			return n, fmt.Errorf("updateOne: %w", err)
+		}
+		n += int(r.ModifiedCount)
+	}
+	return n, nil
+}
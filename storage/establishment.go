@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// openAt is the expression matching the establishments open at t in the
+// time zone of their hours, as model.OpeningHours.OpenAt.
+func openAt(t time.Time) bson.M {
+	local := func(op string) bson.M {
+		return bson.M{op: bson.M{"date": t, "timezone": "$$tz"}}
+	}
+	within := func(ranges interface{}, extra ...bson.M) bson.M {
+		return bson.M{"$anyElementTrue": bson.A{bson.M{"$map": bson.M{
+			"input": bson.M{"$ifNull": bson.A{ranges, bson.A{}}},
+			"in": bson.M{"$and": append([]bson.M{
+				{"$lte": bson.A{"$$this.open", "$$m"}},
+				{"$gt": bson.A{"$$this.close", "$$m"}},
+			}, extra...)},
+		}}}}
+	}
+	return bson.M{"$let": bson.M{
+		"vars": bson.M{"tz": "$hours.time_zone"},
+		"in": bson.M{"$let": bson.M{
+			"vars": bson.M{
+				"date": bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": t, "timezone": "$$tz"}},
+				// $dayOfWeek starts at 1 on Sunday
+				"day": bson.M{"$subtract": bson.A{local("$dayOfWeek"), 1}},
+				"m":   bson.M{"$add": bson.A{bson.M{"$multiply": bson.A{local("$hour"), 60}}, local("$minute")}},
+			},
+			"in": bson.M{"$let": bson.M{
+				"vars": bson.M{"ex": bson.M{"$filter": bson.M{
+					"input": bson.M{"$ifNull": bson.A{"$hours.exceptions", bson.A{}}},
+					"cond":  bson.M{"$eq": bson.A{"$$this.date", "$$date"}},
+				}}},
+				"in": bson.M{"$cond": bson.A{
+					bson.M{"$gt": bson.A{bson.M{"$size": "$$ex"}, 0}},
+					within(bson.M{"$arrayElemAt": bson.A{"$$ex.ranges", 0}}),
+					within("$hours.weekly", bson.M{"$eq": bson.A{"$$this.day", "$$day"}}),
+				}},
+			}},
+		}},
+	}}
+}
+
+// available adds to q the conditions of av.
+func available(q bson.M, av model.Availability) bson.M {
+	if !av.IncludeSuspended {
+		q["suspended"] = bson.M{"$ne": true}
+	}
+	if !av.OpenAt.IsZero() {
+		// the establishments without hours are always open
+		q["$or"] = bson.A{
+			bson.M{"hours.time_zone": bson.M{"$exists": false}},
+			bson.M{"$expr": openAt(av.OpenAt)},
+		}
+	}
+	return q
+}
+
+// SetInfo replaces the description of the establishment aID, it returns the
+// establishment before.
+func (as AddressStorage) SetInfo(ctx context.Context, aID string, info model.EstablishmentInfo) (model.Address, error) {
+	id, err := primitive.ObjectIDFromHex(aID)
+	if err != nil {
+		return model.Address{}, fmt.Errorf("ObjectIDFromHex: %w", err)
+	}
+	q, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return model.Address{}, err
+	}
+	set, unset := bson.M{}, bson.M{}
+	for _, f := range []struct {
+		name  string
+		value interface{}
+		empty bool
+	}{
+		{"name", info.Name, info.Name == ""},
+		{"phone", info.Phone, info.Phone == ""},
+		{"hours", info.Hours, info.Hours == nil},
+		{"suspended", true, !info.Suspended},
+		{"priority", info.Priority, info.Priority == 0},
+		{"capacity", info.Capacity, info.Capacity == 0},
+	} {
+		if f.empty {
+			unset[f.name] = ""
+		} else {
+			set[f.name] = f.value
+		}
+	}
+	u := bson.M{}
+	if len(set) != 0 {
+		u["$set"] = set
+	}
+	if len(unset) != 0 {
+		u["$unset"] = unset
+	}
+	var before model.Address
+	r := as.c.FindOneAndUpdate(ctx, q, u, options.FindOneAndUpdate().SetReturnDocument(options.Before))
+	if err := r.Decode(&before); err != nil {
+		return model.Address{}, fmt.Errorf("findOneAndUpdate: %w", err)
+	}
+	return before, nil
+}
+
+// ReportLoad stores the active orders of the establishment aID at at, it
+// returns 0 when there is none. The load is kept by Candidates.
+func (as AddressStorage) ReportLoad(ctx context.Context, aID string, active int, at time.Time) (int64, error) {
+	id, err := primitive.ObjectIDFromHex(aID)
+	if err != nil {
+		return 0, fmt.Errorf("ObjectIDFromHex: %w", err)
+	}
+	q, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return 0, err
+	}
+	n, err := as.c.CountDocuments(ctx, q)
+	if err != nil {
+		return 0, fmt.Errorf("countDocuments: %w", err)
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	// the tenant is matched before, the ids are unique across them
+	l := model.Load{Active: active, ReportedAt: at}
+	_, err = as.loads.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": l}, options.Update().SetUpsert(true))
+	if err != nil {
+		return 0, fmt.Errorf("updateOne: %w", err)
+	}
+	return n, nil
+}
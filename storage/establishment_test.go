@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/modular-project/address-service/model"
+	"github.com/modular-project/address-service/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// newTestEstablishments returns the establishments of the test database,
+// dropped at the end of t.
+func newTestEstablishments(t *testing.T) AddressStorage {
+	as := NewAddressStorage(newTestDB(t), 0, "establishment_test")
+	t.Cleanup(func() {
+		if err := as.c.Drop(context.Background()); err != nil {
+			t.Errorf("drop: %s", err)
+		}
+	})
+	return as
+}
+
+func insertEstablishment(t *testing.T, c *mongo.Collection, a model.Address) {
+	if _, err := c.InsertOne(context.Background(), a); err != nil {
+		t.Fatalf("insertOne: %s", err)
+	}
+}
+
+func TestOpenAt(t *testing.T) {
+	as := newTestEstablishments(t)
+	a := model.Address{
+		ID:       primitive.NewObjectID(),
+		TenantID: "test",
+		Hours: &model.OpeningHours{
+			TimeZone: "America/Mexico_City",
+			Weekly: []model.Period{
+				{Day: time.Monday, TimeRange: model.TimeRange{Open: 9 * 60, Close: 14 * 60}},
+				{Day: time.Monday, TimeRange: model.TimeRange{Open: 16 * 60, Close: 22 * 60}},
+				{Day: time.Saturday, TimeRange: model.TimeRange{Open: 10 * 60, Close: 24 * 60}},
+			},
+			Exceptions: []model.HoursException{
+				{Date: "2022-09-16"},
+				{Date: "2022-09-19", Ranges: []model.TimeRange{{Open: 12 * 60, Close: 18 * 60}}},
+			},
+		},
+	}
+	insertEstablishment(t, as.c, a)
+	tests := []struct {
+		name string
+		at   string
+		want bool
+	}{
+		{name: "monday morning", at: "2022-09-12T10:00:00-05:00", want: true},
+		{name: "monday break", at: "2022-09-12T15:00:00-05:00"},
+		{name: "close excluded", at: "2022-09-12T22:00:00-05:00"},
+		{name: "local time of another zone", at: "2022-09-12T15:30:00Z", want: true},
+		{name: "sunday", at: "2022-09-11T12:00:00-05:00"},
+		{name: "until midnight", at: "2022-09-10T23:59:00-05:00", want: true},
+		{name: "closed holiday", at: "2022-09-16T12:00:00-05:00"},
+		{name: "exception replaces the week", at: "2022-09-19T10:00:00-05:00"},
+		{name: "exception hours", at: "2022-09-19T17:00:00-05:00", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, err := time.Parse(time.RFC3339, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, err := as.c.CountDocuments(context.Background(), bson.M{"_id": a.ID, "$expr": openAt(at)})
+			if err != nil {
+				t.Fatalf("countDocuments: %s", err)
+			}
+			if got := n == 1; got != tt.want {
+				t.Errorf("openAt(%s) = %t, want %t", tt.at, got, tt.want)
+			}
+			if got := a.Hours.OpenAt(at); got != tt.want {
+				t.Errorf("OpeningHours.OpenAt(%s) = %t, the expression differs", tt.at, got)
+			}
+		})
+	}
+}
+
+func TestAvailable(t *testing.T) {
+	at := time.Date(2022, 9, 12, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		av            model.Availability
+		wantSuspended bool
+		wantOpen      bool
+	}{
+		{name: "any", av: model.Availability{IncludeSuspended: true}},
+		{name: "not suspended", av: model.Availability{}, wantSuspended: true},
+		{name: "open", av: model.Availability{IncludeSuspended: true, OpenAt: at}, wantOpen: true},
+		{name: "open and not suspended", av: model.Availability{OpenAt: at}, wantSuspended: true, wantOpen: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := available(bson.M{"tenant_id": "test"}, tt.av)
+			if got["tenant_id"] != "test" {
+				t.Errorf("available() = %v, the query is replaced", got)
+			}
+			if _, ok := got["suspended"]; ok != tt.wantSuspended {
+				t.Errorf("available() = %v, want suspended excluded %t", got, tt.wantSuspended)
+			}
+			or, ok := got["$or"].(bson.A)
+			if ok != tt.wantOpen {
+				t.Fatalf("available() = %v, want open at %t", got, tt.wantOpen)
+			}
+			if ok && len(or) != 2 {
+				t.Errorf("available() $or = %v, want the establishments without hours or open", or)
+			}
+		})
+	}
+}
+
+func TestAddressStorage_SetInfo(t *testing.T) {
+	as := newTestEstablishments(t)
+	a := model.Address{
+		ID:        primitive.NewObjectID(),
+		TenantID:  "test",
+		Street:    "Av. Vallarta 1",
+		Name:      "Vallarta",
+		Phone:     "3312345678",
+		Suspended: true,
+		Priority:  2,
+	}
+	insertEstablishment(t, as.c, a)
+	hours := &model.OpeningHours{TimeZone: "UTC", Weekly: []model.Period{{Day: time.Friday, TimeRange: model.TimeRange{Open: 0, Close: 24 * 60}}}}
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		aID     string
+		info    model.EstablishmentInfo
+		want    model.Address
+		wantErr bool
+	}{
+		{name: "invalid id", ctx: testCtx, aID: "vallarta", wantErr: true},
+		{name: "another tenant", ctx: tenant.NewContext(context.Background(), "other"), aID: a.ID.Hex(), wantErr: true},
+		{
+			name: "replaced",
+			ctx:  testCtx,
+			aID:  a.ID.Hex(),
+			info: model.EstablishmentInfo{Name: "Vallarta Centro", Hours: hours, Capacity: 20},
+			want: model.Address{ID: a.ID, TenantID: "test", Street: a.Street, Name: "Vallarta Centro", Hours: hours, Capacity: 20},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := as.SetInfo(tt.ctx, tt.aID, tt.info)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AddressStorage.SetInfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if before.Name != a.Name || before.Phone != a.Phone || !before.Suspended {
+				t.Errorf("AddressStorage.SetInfo() = %+v, want the establishment before", before)
+			}
+			var got model.Address
+			if err := as.c.FindOne(context.Background(), bson.M{"_id": a.ID}).Decode(&got); err != nil {
+				t.Fatalf("findOne: %s", err)
+			}
+			if got.Name != tt.want.Name || got.Phone != "" || got.Suspended || got.Priority != 0 || got.Capacity != tt.want.Capacity {
+				t.Errorf("AddressStorage.SetInfo() stored %+v, want %+v", got, tt.want)
+			}
+			if got.Street != a.Street || got.Hours == nil || got.Hours.TimeZone != hours.TimeZone || len(got.Hours.Weekly) != 1 {
+				t.Errorf("AddressStorage.SetInfo() stored %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// exportBatch is the number of documents fetched per round trip.
+const exportBatch = 500
+
+// equalFold matches v ignoring case.
+func equalFold(v string) primitive.Regex {
+	return primitive.Regex{Pattern: "^" + regexp.QuoteMeta(v) + "$", Options: "i"}
+}
+
+func filterQuery(f model.Filter) bson.M {
+	q := bson.M{}
+	for k, v := range map[string]string{"city": f.City, "state": f.State, "country": f.Country, "pc": f.PostalCode} {
+		if v != "" {
+			q[k] = equalFold(v)
+		}
+	}
+	switch f.GeocodeStatus {
+	case "":
+	case model.GeocodeDone:
+		// documents older than the status are geocoded
+		q["geocode_status"] = bson.M{"$in": bson.A{model.GeocodeDone, nil}}
+	default:
+		q["geocode_status"] = f.GeocodeStatus
+	}
+	id := bson.M{}
+	if !f.Since.IsZero() {
+		id["$gte"] = primitive.NewObjectIDFromTimestamp(f.Since)
+	}
+	if !f.Until.IsZero() {
+		id["$lt"] = primitive.NewObjectIDFromTimestamp(f.Until)
+	}
+	if len(id) != 0 {
+		q["_id"] = id
+	}
+	if !f.IncludeDeleted {
+		q["is_deleted"] = bson.M{"$ne": true}
+	}
+	return q
+}
+
+// each calls fn with every document of c matching q in creation order.
+func each(ctx context.Context, c *mongo.Collection, q bson.M, fn func(model.Delivery) error) error {
+	opts := options.Find().SetSort(bson.M{"_id": 1}).SetBatchSize(exportBatch)
+	cur, err := c.Find(ctx, q, opts)
+	if err != nil {
+		return fmt.Errorf("find: %w", err)
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var d model.Delivery
+		if err := cur.Decode(&d); err != nil {
+			return fmt.Errorf("decode: %w", err)
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return fmt.Errorf("cursor: %w", err)
+	}
+	return nil
+}
+
+// Each calls fn with every establishment matching f, its UserID is ignored.
+func (as AddressStorage) Each(ctx context.Context, f model.Filter, fn func(model.Delivery) error) error {
+	q, err := scoped(ctx, filterQuery(f))
+	if err != nil {
+		return err
+	}
+	return each(ctx, as.c, q, fn)
+}
+
+// Each calls fn with every delivery address matching f.
+func (ds DeliveryStorage) Each(ctx context.Context, f model.Filter, fn func(model.Delivery) error) error {
+	q, err := scoped(ctx, filterQuery(f))
+	if err != nil {
+		return err
+	}
+	if f.UserID != 0 {
+		q["user_id"] = f.UserID
+	}
+	if ds.enc != nil && f.PostalCode != "" {
+		// the encrypted postal codes only match exactly
+		if q["pc"], err = ds.lookup("pc", f.PostalCode); err != nil {
+			return err
+		}
+	}
+	return each(ctx, ds.c, q, ds.opening(fn))
+}
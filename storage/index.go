@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type index struct {
+	Name string `bson:"name"`
+	Key  bson.D `bson:"key"`
+}
+
+func sameKey(a, b bson.D) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		// values are compared as text, the server may answer 1 as int32 or double
+		if a[i].Key != b[i].Key || fmt.Sprint(a[i].Value) != fmt.Sprint(b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func keyString(k bson.D) string {
+	s := make([]string, len(k))
+	for i := range k {
+		s[i] = fmt.Sprintf("%s_%v", k[i].Key, k[i].Value)
+	}
+	return strings.Join(s, "_")
+}
+
+// verifyIndexes returns an error listing every key of want without an index on c.
+func verifyIndexes(ctx context.Context, c *mongo.Collection, want ...bson.D) error {
+	cur, err := c.Indexes().List(ctx)
+	if err != nil {
+		return fmt.Errorf("list indexes: %w", err)
+	}
+	var got []index
+	if err := cur.All(ctx, &got); err != nil {
+		return fmt.Errorf("decode indexes: %w", err)
+	}
+	var missing []string
+	for _, w := range want {
+		found := false
+		for _, g := range got {
+			if sameKey(g.Key, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, keyString(w))
+		}
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("missing indexes on %s: %s", c.Name(), strings.Join(missing, ", "))
+	}
+	return nil
+}
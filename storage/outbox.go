@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/modular-project/address-service/model"
+	"github.com/modular-project/address-service/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Outbox stores the domain events in the same transaction as the changes,
+// transactions need a replica set.
+type Outbox struct {
+	client *mongo.Client
+	events *mongo.Collection
+	leases *mongo.Collection
+}
+
+func NewOutbox(db *mongo.Database) Outbox {
+	return Outbox{
+		client: db.Client(),
+		events: db.Collection("outbox"),
+		leases: db.Collection("outbox_lease"),
+	}
+}
+
+// EnsureIndexes creates the index of the unpublished events and the TTL
+// index removing the published ones after retention.
+func (o Outbox) EnsureIndexes(ctx context.Context, retention time.Duration) error {
+	_, err := o.events.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "occurred_at", Value: 1}, {Key: "_id", Value: 1}},
+			Options: options.Index().SetName("unpublished").
+				SetPartialFilterExpression(bson.M{"published_at": bson.M{"$exists": false}}),
+		},
+		{
+			Keys:    bson.D{{Key: "published_at", Value: 1}},
+			Options: options.Index().SetName("published_ttl").SetExpireAfterSeconds(int32(retention.Seconds())),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("create indexes: %w", err)
+	}
+	return nil
+}
+
+// Atomically runs fn in a transaction and stores the events it returns in
+// the same transaction, fn must use the context it receives. Its error is
+// returned as is.
+func (o Outbox) Atomically(ctx context.Context, fn func(context.Context) ([]model.Event, error)) error {
+	sess, err := o.client.StartSession()
+	if err != nil {
+		return fmt.Errorf("startSession: %w", err)
+	}
+	defer sess.EndSession(ctx)
+	_, err = sess.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		evs, err := fn(sc)
+		if err != nil || len(evs) == 0 {
+			return nil, err
+		}
+		t, _ := tenant.FromContext(ctx)
+		docs := make([]interface{}, len(evs))
+		for i := range evs {
+			if evs[i].TenantID == "" {
+				evs[i].TenantID = t
+			}
+			docs[i] = evs[i]
+		}
+		if _, err := o.events.InsertMany(sc, docs); err != nil {
+			return nil, fmt.Errorf("insert events: %w", err)
+		}
+		return nil, nil
+	})
+	return err
+}
+
+// Unpublished returns up to limit events not published yet nor dead, in
+// the order they occurred.
+func (o Outbox) Unpublished(ctx context.Context, limit int) ([]model.Event, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "occurred_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit))
+	cur, err := o.events.Find(ctx, bson.M{"published_at": bson.M{"$exists": false}, "dead_at": bson.M{"$exists": false}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("find: %w", err)
+	}
+	var evs []model.Event
+	if err := cur.All(ctx, &evs); err != nil {
+		return nil, fmt.Errorf("decode all: %w", err)
+	}
+	return evs, nil
+}
+
+// MarkPublished records the delivery of the event id.
+func (o Outbox) MarkPublished(ctx context.Context, id primitive.ObjectID) error {
+	_, err := o.events.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"published_at": time.Now()}})
+	if err != nil {
+		return fmt.Errorf("updateOne: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed counts a failed delivery of the event id.
+func (o Outbox) MarkFailed(ctx context.Context, id primitive.ObjectID) error {
+	_, err := o.events.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$inc": bson.M{"attempts": 1}})
+	if err != nil {
+		return fmt.Errorf("updateOne: %w", err)
+	}
+	return nil
+}
+
+// MarkDead stops relaying the event id, it is kept for inspection.
+func (o Outbox) MarkDead(ctx context.Context, id primitive.ObjectID) error {
+	_, err := o.events.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$inc": bson.M{"attempts": 1},
+		"$set": bson.M{"dead_at": time.Now()},
+	})
+	if err != nil {
+		return fmt.Errorf("updateOne: %w", err)
+	}
+	return nil
+}
+
+// AcquireLease takes or renews the lease name for owner until ttl, only one
+// replica relays the events so that they keep their order.
+func (o Outbox) AcquireLease(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	_, err := o.leases.UpdateOne(ctx,
+		bson.M{"_id": name, "$or": bson.A{bson.M{"owner": owner}, bson.M{"expire_at": bson.M{"$lt": now}}}},
+		bson.M{"$set": bson.M{"owner": owner, "expire_at": now.Add(ttl)}},
+		options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// held by another owner, the upsert tried to insert it again
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("updateOne: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// pending uses the addresses waiting for a location of a collection as the
+// geocoding queue, a claimed address is hidden from other workers until its
+// lease ends.
+type pending struct {
+	c *mongo.Collection
+}
+
+// EnsurePendingIndex creates the partial index used to claim the pending
+// addresses.
+func (p pending) EnsurePendingIndex(ctx context.Context) error {
+	_, err := p.c.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "geocode_retry_at", Value: 1}},
+		Options: options.Index().SetName("geocode_pending").
+			SetPartialFilterExpression(bson.M{"geocode_status": model.GeocodePending}),
+	})
+	if err != nil {
+		return fmt.Errorf("create index: %w", err)
+	}
+	return nil
+}
+
+// ClaimPending returns the pending address due the longest time ago and
+// postpones it by lease, ok is false when there is none.
+func (p pending) ClaimPending(ctx context.Context, lease time.Duration) (model.Address, bool, error) {
+	now := time.Now()
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.M{"geocode_retry_at": 1}).
+		SetReturnDocument(options.After)
+	r := p.c.FindOneAndUpdate(ctx,
+		bson.M{"geocode_status": model.GeocodePending, "geocode_retry_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"geocode_retry_at": now.Add(lease)}}, opts)
+	var a model.Address
+	if err := r.Decode(&a); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Address{}, false, nil
+		}
+		return model.Address{}, false, fmt.Errorf("findOneAndUpdate: %w", err)
+	}
+	return a, true, nil
+}
+
+// Geocoded stores the location of id, also used to replace the location of
+// a geocoded address.
+func (p pending) Geocoded(ctx context.Context, id primitive.ObjectID, loc model.Location) error {
+	_, err := p.c.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{
+			"location":         loc,
+			"geocode_status":   model.GeocodeDone,
+			"geocoded_at":      time.Now(),
+			"geocode_provider": loc.Provider,
+			"geocode_quality":  loc.Precision,
+		},
+		"$unset": bson.M{"geocode_retry_at": ""},
+	})
+	if err != nil {
+		return fmt.Errorf("updateOne: %w", err)
+	}
+	return nil
+}
+
+// RetryGeocode schedules the next attempt of id at at.
+func (p pending) RetryGeocode(ctx context.Context, id primitive.ObjectID, attempts int, at time.Time) error {
+	_, err := p.c.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{"geocode_attempts": attempts, "geocode_retry_at": at},
+	})
+	if err != nil {
+		return fmt.Errorf("updateOne: %w", err)
+	}
+	return nil
+}
+
+// GeocodeFailed gives up on id.
+func (p pending) GeocodeFailed(ctx context.Context, id primitive.ObjectID, attempts int) error {
+	_, err := p.c.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set":   bson.M{"geocode_attempts": attempts, "geocode_status": model.GeocodeFailed},
+		"$unset": bson.M{"geocode_retry_at": ""},
+	})
+	if err != nil {
+		return fmt.Errorf("updateOne: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// expired matches the deleted delivery addresses of every tenant deleted
+// before, the anonymized ones are kept.
+func expired(before time.Time) bson.M {
+	return bson.M{
+		"is_deleted": true,
+		"anonymized": bson.M{"$ne": true},
+		"deleted_at": bson.M{"$lt": before},
+	}
+}
+
+// EnsureRetentionIndex creates the partial index used to find the expired
+// delivery addresses.
+func (ds DeliveryStorage) EnsureRetentionIndex(ctx context.Context) error {
+	_, err := ds.c.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "deleted_at", Value: 1}},
+		Options: options.Index().SetName("deleted_retention").
+			SetPartialFilterExpression(bson.M{"is_deleted": true}),
+	})
+	if err != nil {
+		return fmt.Errorf("create index: %w", err)
+	}
+	return nil
+}
+
+// StampDeleted sets the deletion time of the addresses deleted before it
+// was stored to at, their retention starts then.
+func (ds DeliveryStorage) StampDeleted(ctx context.Context, at time.Time) (int64, error) {
+	r, err := ds.c.UpdateMany(ctx,
+		bson.M{"is_deleted": true, "deleted_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"deleted_at": at}})
+	if err != nil {
+		return 0, fmt.Errorf("updateMany: %w", err)
+	}
+	return r.ModifiedCount, nil
+}
+
+// Expired returns the ids of up to limit delivery addresses deleted before,
+// the oldest first.
+func (ds DeliveryStorage) Expired(ctx context.Context, before time.Time, limit int64) ([]primitive.ObjectID, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "deleted_at", Value: 1}}).
+		SetLimit(limit).
+		SetProjection(bson.M{"_id": 1})
+	cur, err := ds.c.Find(ctx, expired(before), opts)
+	if err != nil {
+		return nil, fmt.Errorf("find: %w", err)
+	}
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("decode all: %w", err)
+	}
+	ids := make([]primitive.ObjectID, len(docs))
+	for i, d := range docs {
+		ids[i] = d.ID
+	}
+	return ids, nil
+}
+
+// Purge removes the addresses ids still deleted before, the ones restored
+// in the meantime are kept.
+func (ds DeliveryStorage) Purge(ctx context.Context, ids []primitive.ObjectID, before time.Time) (int64, error) {
+	q := expired(before)
+	q["_id"] = bson.M{"$in": ids}
+	r, err := ds.c.DeleteMany(ctx, q)
+	if err != nil {
+		return 0, fmt.Errorf("deleteMany: %w", err)
+	}
+	return r.DeletedCount, nil
+}
+
+// AnonymizeDeleted anonymizes the addresses ids still deleted before, as
+// EraseUser does.
+func (ds DeliveryStorage) AnonymizeDeleted(ctx context.Context, ids []primitive.ObjectID, before time.Time, precision int) (int64, error) {
+	q := expired(before)
+	q["_id"] = bson.M{"$in": ids}
+	r, err := ds.c.UpdateMany(ctx, q, anonymized(precision))
+	if err != nil {
+		return 0, fmt.Errorf("updateMany: %w", err)
+	}
+	return r.ModifiedCount, nil
+}
+
+// ReferenceStorage finds the delivery addresses referenced by the documents
+// of a collection of another service, e.g. the orders, in the field holding
+// their id as an ObjectID or its hex.
+type ReferenceStorage struct {
+	c     *mongo.Collection
+	field string
+}
+
+func NewReferenceStorage(db *mongo.Database, coll, field string) ReferenceStorage {
+	return ReferenceStorage{c: db.Collection(coll), field: field}
+}
+
+// Referenced returns which ids are referenced.
+func (rs ReferenceStorage) Referenced(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	in := make(bson.A, 0, 2*len(ids))
+	for _, id := range ids {
+		in = append(in, id, id.Hex())
+	}
+	vs, err := rs.c.Distinct(ctx, rs.field, bson.M{rs.field: bson.M{"$in": in}})
+	if err != nil {
+		return nil, fmt.Errorf("distinct: %w", err)
+	}
+	refs := make(map[primitive.ObjectID]bool, len(vs))
+	for _, v := range vs {
+		switch v := v.(type) {
+		case primitive.ObjectID:
+			refs[v] = true
+		case string:
+			if id, err := primitive.ObjectIDFromHex(v); err == nil {
+				refs[id] = true
+			}
+		}
+	}
+	return refs, nil
+}
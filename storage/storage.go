@@ -2,45 +2,233 @@ package storage
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net/url"
+	"os"
+	"strconv"
 	"sync"
 	"time"
 
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
-var (
-	once sync.Once
-)
+// maxBackoff caps the wait between connection attempts
+const maxBackoff = 30 * time.Second
 
 type DBConnection struct {
+	// URI is a full connection string, when set Host, Cluster, User and
+	// Password are ignored
+	URI      string
 	User     string
 	Password string
 	NameDB   string
 	Cluster  string
-	Host     string
-}
-
-func NewDB(conn *DBConnection) (*mongo.Database, error) {
-	var err error
-	var client *mongo.Client
-	once.Do(func() {
-		dns := fmt.Sprintf("%s://%s:%s@%s?retryWrites=true&w=majority",
-			conn.Host, conn.User, conn.Password, conn.Cluster)
-		serverAPIOptions := options.ServerAPI(options.ServerAPIVersion1)
-		clientOptions := options.Client().
-			ApplyURI(dns).
-			SetServerAPIOptions(serverAPIOptions)
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		client, err = mongo.Connect(ctx, clientOptions)
-	})
+	// Host is the URI scheme, mongodb or mongodb+srv
+	Host          string
+	AuthSource    string
+	AuthMechanism string
+	// TLSCAFile is a PEM file with the CA used to verify the server
+	TLSCAFile              string
+	MinPoolSize            uint64
+	MaxPoolSize            uint64
+	ConnectTimeout         time.Duration
+	ServerSelectionTimeout time.Duration
+	// ReadPreference mode: primary, primaryPreferred, secondary,
+	// secondaryPreferred or nearest
+	ReadPreference string
+	// WriteConcern is "majority" or the number of acknowledging nodes
+	WriteConcern string
+	// ConnectRetries is the number of extra attempts to reach the server
+	// before giving up, waiting RetryBackoff doubled after each failure
+	ConnectRetries int
+	RetryBackoff   time.Duration
+	// Monitors receive every command sent to the server, in order
+	Monitors []*event.CommandMonitor
+}
+
+// chainMonitors returns a monitor forwarding each event to every ms.
+func chainMonitors(ms []*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range ms {
+				if m.Started != nil {
+					m.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range ms {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range ms {
+				if m.Failed != nil {
+					m.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
+
+func (conn *DBConnection) uri() string {
+	if conn.URI != "" {
+		return conn.URI
+	}
+	u := url.URL{Scheme: conn.Host, Host: conn.Cluster, Path: "/", RawQuery: "retryWrites=true&w=majority"}
+	if conn.User != "" {
+		u.User = url.UserPassword(conn.User, conn.Password)
+	}
+	return u.String()
+}
+
+func (conn *DBConnection) clientOptions() (*options.ClientOptions, error) {
+	opts := options.Client().
+		ApplyURI(conn.uri()).
+		SetServerAPIOptions(options.ServerAPI(options.ServerAPIVersion1))
+	if conn.AuthSource != "" || conn.AuthMechanism != "" {
+		cred := options.Credential{AuthSource: conn.AuthSource, AuthMechanism: conn.AuthMechanism}
+		if opts.Auth != nil {
+			cred.Username, cred.Password, cred.PasswordSet = opts.Auth.Username, opts.Auth.Password, opts.Auth.PasswordSet
+		}
+		opts.SetAuth(cred)
+	}
+	if conn.TLSCAFile != "" {
+		pem, err := os.ReadFile(conn.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read TLS CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("TLS CA %s: no certificates found", conn.TLSCAFile)
+		}
+		opts.SetTLSConfig(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12})
+	}
+	if conn.MinPoolSize != 0 {
+		opts.SetMinPoolSize(conn.MinPoolSize)
+	}
+	if conn.MaxPoolSize != 0 {
+		opts.SetMaxPoolSize(conn.MaxPoolSize)
+	}
+	if conn.ConnectTimeout != 0 {
+		opts.SetConnectTimeout(conn.ConnectTimeout)
+	}
+	if conn.ServerSelectionTimeout != 0 {
+		opts.SetServerSelectionTimeout(conn.ServerSelectionTimeout)
+	}
+	if conn.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(conn.ReadPreference)
+		if err != nil {
+			return nil, fmt.Errorf("read preference: %w", err)
+		}
+		rp, err := readpref.New(mode)
+		if err != nil {
+			return nil, fmt.Errorf("read preference: %w", err)
+		}
+		opts.SetReadPreference(rp)
+	}
+	if conn.WriteConcern != "" {
+		if conn.WriteConcern == "majority" {
+			opts.SetWriteConcern(writeconcern.New(writeconcern.WMajority()))
+		} else {
+			w, err := strconv.Atoi(conn.WriteConcern)
+			if err != nil {
+				return nil, fmt.Errorf("write concern %q: must be majority or a number", conn.WriteConcern)
+			}
+			opts.SetWriteConcern(writeconcern.New(writeconcern.W(w)))
+		}
+	}
+	if len(conn.Monitors) != 0 {
+		opts.SetMonitor(chainMonitors(conn.Monitors))
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("client options: %w", err)
+	}
+	return opts, nil
+}
+
+// Manager owns a Mongo client and the databases opened through it.
+type Manager struct {
+	client *mongo.Client
+	name   string
+	mu     sync.Mutex
+	dbs    map[string]*mongo.Database
+}
+
+// Connect creates a client and waits until the server answers a ping,
+// retrying conn.ConnectRetries times with exponential backoff.
+func Connect(ctx context.Context, conn *DBConnection) (*Manager, error) {
+	opts, err := conn.clientOptions()
+	if err != nil {
+		return nil, err
+	}
+	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("connect: %w", err)
 	}
-	if client == nil {
-		return nil, fmt.Errorf("nil client")
+	backoff := conn.RetryBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	for i := 0; ; i++ {
+		pctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err = client.Ping(pctx, readpref.Primary())
+		cancel()
+		if err == nil {
+			break
+		}
+		if i >= conn.ConnectRetries {
+			_ = client.Disconnect(context.Background())
+			return nil, fmt.Errorf("ping after %d attempts: %w", i+1, err)
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			_ = client.Disconnect(context.Background())
+			return nil, fmt.Errorf("ping: %w", ctx.Err())
+		case <-t.C:
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	return &Manager{client: client, name: conn.NameDB, dbs: make(map[string]*mongo.Database)}, nil
+}
+
+// Database returns the named database, an empty name returns the default
+// database of the connection.
+func (m *Manager) Database(name string) *mongo.Database {
+	if name == "" {
+		name = m.name
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	db, ok := m.dbs[name]
+	if !ok {
+		db = m.client.Database(name)
+		m.dbs[name] = db
+	}
+	return db
+}
+
+func (m *Manager) Client() *mongo.Client {
+	return m.client
+}
+
+// Close disconnects the client, waiting for in use connections until ctx
+// is done.
+func (m *Manager) Close(ctx context.Context) error {
+	if err := m.client.Disconnect(ctx); err != nil {
+		return fmt.Errorf("disconnect: %w", err)
 	}
-	return client.Database(conn.NameDB), nil
+	return nil
 }
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestDBConnection_clientOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		conn    DBConnection
+		wantErr bool
+	}{
+		{
+			name: "password is escaped",
+			conn: DBConnection{Host: "mongodb", User: "user", Password: "p@ss:w/rd?#", Cluster: "localhost:27017"},
+		}, {
+			name: "full uri",
+			conn: DBConnection{URI: "mongodb://localhost:27017/?replicaSet=rs0", ReadPreference: "secondaryPreferred", WriteConcern: "2"},
+		}, {
+			name:    "unknown read preference",
+			conn:    DBConnection{URI: "mongodb://localhost:27017", ReadPreference: "closest"},
+			wantErr: true,
+		}, {
+			name:    "invalid write concern",
+			conn:    DBConnection{URI: "mongodb://localhost:27017", WriteConcern: "all"},
+			wantErr: true,
+		}, {
+			name:    "missing CA file",
+			conn:    DBConnection{URI: "mongodb://localhost:27017", TLSCAFile: "/does/not/exist.pem"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := tt.conn.clientOptions()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DBConnection.clientOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil || tt.conn.User == "" {
+				return
+			}
+			if opts.Auth == nil || opts.Auth.Password != tt.conn.Password {
+				t.Errorf("DBConnection.clientOptions() password was not preserved: %+v", opts.Auth)
+			}
+		})
+	}
+}
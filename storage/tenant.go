@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/modular-project/address-service/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// codeIndexNotFound is the server error code of dropping a missing index.
+const codeIndexNotFound = 27
+
+// tenantOf returns the tenant of ctx, the queries of a request never cross
+// tenants.
+func tenantOf(ctx context.Context) (string, error) {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return "", tenant.ErrMissing
+	}
+	return t, nil
+}
+
+// scoped limits q to the tenant of ctx.
+func scoped(ctx context.Context, q bson.M) (bson.M, error) {
+	t, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	q["tenant_id"] = t
+	return q, nil
+}
+
+// assignTenant stamps t on the documents of c written before the tenants.
+func assignTenant(ctx context.Context, c *mongo.Collection, t string) (int64, error) {
+	r, err := c.UpdateMany(ctx, bson.M{"tenant_id": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"tenant_id": t}})
+	if err != nil {
+		return 0, fmt.Errorf("updateMany: %w", err)
+	}
+	return r.ModifiedCount, nil
+}
+
+// AssignTenant stamps t on the establishments without tenant.
+func (as AddressStorage) AssignTenant(ctx context.Context, t string) (int64, error) {
+	return assignTenant(ctx, as.c, t)
+}
+
+// AssignTenant stamps t on the delivery addresses without tenant.
+func (ds DeliveryStorage) AssignTenant(ctx context.Context, t string) (int64, error) {
+	return assignTenant(ctx, ds.c, t)
+}
+
+// EnsureTenantIndexes creates the indexes of the establishments prefixed by
+// the tenant and drops the ones they replace. The documents must have a
+// tenant already.
+func (as AddressStorage) EnsureTenantIndexes(ctx context.Context) error {
+	_, err := as.c.Indexes().CreateMany(ctx, []mongo.IndexModel{{
+		Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "location", Value: "2dsphere"}},
+		Options: options.Index().SetName("tenant_id_location"),
+	}, {
+		Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "external_key", Value: 1}},
+		Options: options.Index().SetName("tenant_id_external_key").SetUnique(true).
+			SetPartialFilterExpression(bson.M{"external_key": bson.M{"$exists": true}}),
+	}})
+	if err != nil {
+		return fmt.Errorf("create indexes: %w", err)
+	}
+	// the external keys are only unique within a tenant now
+	if _, err := as.c.Indexes().DropOne(ctx, "external_key"); err != nil && !hasCode(err, codeIndexNotFound) {
+		return fmt.Errorf("drop index: %w", err)
+	}
+	return nil
+}
+
+// EnsureTenantIndexes creates the index of the delivery addresses of a user.
+func (ds DeliveryStorage) EnsureTenantIndexes(ctx context.Context) error {
+	_, err := ds.c.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetName("tenant_id_user_id"),
+	})
+	if err != nil {
+		return fmt.Errorf("create index: %w", err)
+	}
+	return nil
+}
+
+func hasCode(err error, code int) bool {
+	var se mongo.ServerError
+	return errors.As(err, &se) && se.HasErrorCode(code)
+}
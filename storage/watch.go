@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/modular-project/address-service/controller"
+	"github.com/modular-project/address-service/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Server error codes of a resume token that can not be used.
+const (
+	codeInvalidResumeToken      = 260
+	codeChangeStreamHistoryLost = 286
+)
+
+var watchOps = map[string]string{
+	"insert":  model.ChangeInsert,
+	"update":  model.ChangeUpdate,
+	"replace": model.ChangeUpdate,
+	"delete":  model.ChangeDelete,
+}
+
+type changeEvent struct {
+	OperationType string              `bson:"operationType"`
+	ClusterTime   primitive.Timestamp `bson:"clusterTime"`
+	DocumentKey   struct {
+		ID primitive.ObjectID `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument *model.Address `bson:"fullDocument"`
+}
+
+func watchError(err error) error {
+	if hasCode(err, codeInvalidResumeToken) || hasCode(err, codeChangeStreamHistoryLost) {
+		return fmt.Errorf("%w: %s", controller.ErrResumeToken, err)
+	}
+	return err
+}
+
+// Watch calls fn with the changes of the establishments of the tenant of
+// ctx after token, or after now when it is empty, until ctx is done or fn
+// fails. The first change is a ChangeStart with the token where the watch
+// begins. It needs a replica set, and the pre-images enabled by
+// EnablePreImages: without them the deletes are not sent, it must not be
+// served then.
+func (as AddressStorage) Watch(ctx context.Context, token []byte, fn func(model.EstablishmentChange) error) error {
+	t, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	// the deletes only carry the tenant in their pre-image, without
+	// pre-images they are not sent
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{
+		"operationType": bson.M{"$in": bson.A{"insert", "update", "replace", "delete"}},
+		"$or": bson.A{
+			bson.M{"fullDocument.tenant_id": t},
+			bson.M{"fullDocumentBeforeChange.tenant_id": t},
+		},
+	}}}}
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup).
+		SetFullDocumentBeforeChange(options.WhenAvailable)
+	if len(token) != 0 {
+		if err := bson.Raw(token).Validate(); err != nil {
+			return fmt.Errorf("%w: %s", controller.ErrResumeToken, err)
+		}
+		opts.SetResumeAfter(bson.Raw(token))
+	}
+	cs, err := as.c.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return fmt.Errorf("watch: %w", watchError(err))
+	}
+	defer cs.Close(context.Background())
+	if err := fn(model.EstablishmentChange{Op: model.ChangeStart, Token: append([]byte(nil), cs.ResumeToken()...), At: time.Now()}); err != nil {
+		return err
+	}
+	for cs.Next(ctx) {
+		var ev changeEvent
+		if err := cs.Decode(&ev); err != nil {
+			return fmt.Errorf("decode: %w", err)
+		}
+		c := model.EstablishmentChange{
+			Op:    watchOps[ev.OperationType],
+			ID:    ev.DocumentKey.ID.Hex(),
+			Token: append([]byte(nil), cs.ResumeToken()...),
+			At:    time.Unix(int64(ev.ClusterTime.T), 0),
+		}
+		if c.Op != model.ChangeDelete {
+			// the establishment was deleted before the lookup, its delete
+			// follows
+			if ev.FullDocument == nil {
+				continue
+			}
+			c.Address = *ev.FullDocument
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	if err := cs.Err(); err != nil {
+		return fmt.Errorf("next: %w", watchError(err))
+	}
+	return ctx.Err()
+}
+
+// EnablePreImages keeps the establishments before their changes, Watch
+// needs them to know the tenant of a delete. It needs MongoDB 6.0.
+func (as AddressStorage) EnablePreImages(ctx context.Context) error {
+	err := as.c.Database().RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: as.c.Name()},
+		{Key: "changeStreamPreAndPostImages", Value: bson.M{"enabled": true}},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("collMod: %w", err)
+	}
+	return nil
+}
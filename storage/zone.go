@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ZoneStorage holds the areas of each tenant where nothing is delivered,
+// e.g. for safety. A zone is a document with a name and a GeoJSON Polygon
+// or MultiPolygon area, created by the operators.
+type ZoneStorage struct {
+	c *mongo.Collection
+}
+
+func NewZoneStorage(db *mongo.Database) ZoneStorage {
+	return ZoneStorage{c: db.Collection("excluded_zone")}
+}
+
+// EnsureIndexes creates the index used to find the zones of a point.
+func (zs ZoneStorage) EnsureIndexes(ctx context.Context) error {
+	_, err := zs.c.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "area", Value: "2dsphere"}},
+		Options: options.Index().SetName("tenant_area"),
+	})
+	if err != nil {
+		return fmt.Errorf("create index: %w", err)
+	}
+	return nil
+}
+
+// ExcludedZone returns the name of a zone of the tenant containing the
+// [lng, lat] point loc, ok is false when there is none.
+func (zs ZoneStorage) ExcludedZone(ctx context.Context, loc []float64) (string, bool, error) {
+	q, err := scoped(ctx, bson.M{"area": bson.M{"$geoIntersects": bson.M{
+		"$geometry": bson.M{"type": "Point", "coordinates": loc},
+	}}})
+	if err != nil {
+		return "", false, err
+	}
+	var z struct {
+		Name string `bson:"name"`
+	}
+	err = zs.c.FindOne(ctx, q, options.FindOne().SetProjection(bson.M{"name": 1})).Decode(&z)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("findOne: %w", err)
+	}
+	return z.Name, true, nil
+}
@@ -0,0 +1,34 @@
+// Package tenant carries the brand a request belongs to, every document of
+// the service is stamped with it and every query of a request is limited to
+// it.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"regexp"
+)
+
+// ErrMissing is returned when a request without tenant reaches the storage.
+var ErrMissing = errors.New("missing tenant")
+
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+type key struct{}
+
+// Valid reports whether id is a tenant ID: up to 63 lowercase letters,
+// digits or dashes, not starting with a dash.
+func Valid(id string) bool {
+	return validID.MatchString(id)
+}
+
+// NewContext returns a copy of ctx that belongs to the tenant id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, key{}, id)
+}
+
+// FromContext returns the tenant of ctx.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(key{}).(string)
+	return id, ok && id != ""
+}